@@ -17,10 +17,11 @@
 package main
 
 // SCANNER
-// 	Scanner connects to the given target IP and port and creates a TCP connection.
-// 	It then waits for a response from server (or times out in the event the server doesn't send anything, e.g. a web server).
+// 	Scanner connects to the given target IP and port using the prober selected for the requested protocol.
+// 	Read-first protocols (for example MySQL) wait for the server to speak first (or time out in the event the server
+// 	doesn't send anything), while request-first protocols (for example HTTP) send a probe and decode the response.
 //
-//	If the server responds, the message is decoded and is attempted to be parsed as a well known type (for example a MySQL packet).
+//	The decoded response is reported as a well known type (for example a MySQL handshake).
 //  The results are then printed as JSON to the STDOUT.
 
 import (
@@ -31,21 +32,50 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
-	"github.com/seglberg/protoscan/pkg/mysql"
+	"github.com/seglberg/protoscan/pkg/oracle"
 )
 
 var args = struct {
-	target      **net.TCPAddr
+	target      *string
+	protocol    *string
 	initTimeout *time.Duration
 	readTimeout *time.Duration
+
+	udpRetransmits *uint
+
+	tls *bool
+
+	httpMethod       *string
+	httpPath         *string
+	httpHeaders      *[]string
+	httpMaxRedirects *int
+
+	tnsCommand *string
+
+	ftpAnonymous *bool
+
+	dnsRecursionName *string
+
+	snmpCommunities *[]string
+
+	ntpAmplification *bool
+
+	clickhouseHTTPPort *uint16
+
+	icsDelay *time.Duration
+
+	modbusUnitID *uint8
 }{
 	kingpin.Arg("target", "Target host and port to scan").
 		Default("localhost:3306").
-		TCP(),
+		String(),
+
+	nil, // registered in init, see below.
 
 	kingpin.Flag("init-timeout", "Maximum amount of time to wait for a connection to be made").
 		Default("10s").
@@ -54,42 +84,88 @@ var args = struct {
 	kingpin.Flag("read-timeout", "Maximum amount of time to wait for server to respond once a connection is made. Set to 0 to wait indefinitely.").
 		Default("5s").
 		Duration(),
+
+	kingpin.Flag("udp-retransmits", "Number of times UDP probers resend an unanswered probe. The read timeout is split evenly between every attempt.").
+		Default("2").
+		Uint(),
+
+	kingpin.Flag("tls", "Use HTTPS with probers for HTTP APIs (elasticsearch, etcd, docker, kubernetes, kubelet) and the clickhouse HTTP interface").
+		Bool(),
+
+	kingpin.Flag("http-method", "HTTP method used by the http and https probers").
+		Default("GET").
+		String(),
+
+	kingpin.Flag("http-path", "HTTP request path used by the http and https probers").
+		Default("/").
+		String(),
+
+	kingpin.Flag("http-header", "Additional HTTP request header in 'Name: Value' form. May be repeated.").
+		Strings(),
+
+	kingpin.Flag("http-max-redirects", "Maximum number of same-host redirects followed by the http and https probers").
+		Default("5").
+		Int(),
+
+	kingpin.Flag("tns-command", "Listener command sent by the oracle prober").
+		Default(oracle.CommandVersion).
+		Enum(oracle.CommandVersion, oracle.CommandStatus),
+
+	kingpin.Flag("ftp-anonymous", "Attempt an anonymous login with the ftp prober").
+		Bool(),
+
+	kingpin.Flag("dns-recursion-name", "Name queried by the dns prober to test whether the server answers recursive queries").
+		Default("example.com").
+		String(),
+
+	kingpin.Flag("snmp-community", "Community string tried by the snmp prober. May be repeated.").
+		Default("public", "private").
+		Strings(),
+
+	kingpin.Flag("ntp-amplification", "Test the ntp prober's target for amplification with mode 6 READVAR and mode 7 MONLIST requests").
+		Bool(),
+
+	kingpin.Flag("clickhouse-http-port", "Port of the HTTP interface probed by the clickhouse prober on the target host. Set to 0 to skip it.").
+		Default("8123").
+		Uint16(),
+
+	kingpin.Flag("ics-delay", "Delay between consecutive requests sent by the industrial protocol probers (modbus, s7, bacnet), as fragile devices can fail under load").
+		Default("500ms").
+		Duration(),
+
+	kingpin.Flag("modbus-unit-id", "Unit identifier addressed by the modbus prober").
+		Default("0").
+		Uint8(),
 }
 
 func init() {
+	// The protocol flag is registered here rather than with the other arguments,
+	// since the probers themselves refer to the parsed arguments.
+	args.protocol = kingpin.Flag("protocol", "Protocol to probe the target for: "+strings.Join(protocols(), ", ")).
+		Short('p').
+		Default("mysql").
+		Enum(protocols()...)
+
 	kingpin.Parse()
+
+	if _, _, err := net.SplitHostPort(*args.target); err != nil {
+		kingpin.Fatalf("invalid target %q: %v", *args.target, err)
+	}
 }
 
 func main() {
 	ctx := context.Background()
 
-	// (1) Make the Initial TCP Connection
-
-	target := (*args.target).String()
-
-	dialer := &net.Dialer{
-		Timeout: *args.initTimeout,
-	}
-
-	conn, err := dialer.DialContext(ctx, "tcp", target)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// Best effort close of connection.
-	defer func() {
-		_ = conn.Close()
-	}()
+	// (1) Select the Prober
 
-	// (2) Read First Packet
+	target := *args.target
+	probe := probers[*args.protocol]
 
-	err = conn.SetReadDeadline(time.Now().Add(*args.readTimeout))
-	if err != nil {
-		log.Fatal(err)
-	}
+	// (2) Probe the Target
 
 	when := time.Now()
 
-	packet, err := mysql.ReadPacket(conn)
+	report, err := probe(ctx, target)
 	if err != nil {
 		if errors.Is(err, os.ErrDeadlineExceeded) {
 			log.Fatal("timed out waiting for server")
@@ -98,27 +174,36 @@ func main() {
 		}
 	}
 
-	// (3) Decode Packet Payload
+	// (3) Print Results
+	//	The mysql report predates the other probers and keeps its original top level shape, for existing consumers.
 
-	hs, err := mysql.DecodeHandshake(packet.Payload)
-	if err != nil {
-		log.Fatal(err)
+	var result interface{} = struct {
+		Target   string      `json:"target"`
+		When     time.Time   `json:"when"`
+		Protocol string      `json:"protocol"`
+		Report   interface{} `json:"report"`
+	}{
+		Target:   target,
+		When:     when,
+		Protocol: *args.protocol,
+		Report:   report,
 	}
 
-	// (4) Print Results
-
-	result := struct {
-		Target       string          `json:"target"`
-		When         time.Time       `json:"when"`
-		ProtoVersion int             `json:"proto_version"`
-		Handshake    mysql.Handshake `json:"handshake"`
-	}{
-		Target:       target,
-		When:         when,
-		ProtoVersion: int(hs.GetProtoVersion()),
-		Handshake:    hs,
+	if r, ok := report.(*mysqlReport); ok {
+		result = struct {
+			Target string    `json:"target"`
+			When   time.Time `json:"when"`
+			*mysqlReport
+		}{
+			Target:      target,
+			When:        when,
+			mysqlReport: r,
+		}
 	}
 
-	serialized, _ := json.MarshalIndent(result, "", "  ")
+	serialized, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println(string(serialized))
 }
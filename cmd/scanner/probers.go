@@ -0,0 +1,345 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	stdhttp "net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/amqp"
+	"github.com/seglberg/protoscan/pkg/bacnet"
+	"github.com/seglberg/protoscan/pkg/bolt"
+	"github.com/seglberg/protoscan/pkg/cassandra"
+	"github.com/seglberg/protoscan/pkg/clickhouse"
+	"github.com/seglberg/protoscan/pkg/dns"
+	"github.com/seglberg/protoscan/pkg/docker"
+	"github.com/seglberg/protoscan/pkg/elasticsearch"
+	"github.com/seglberg/protoscan/pkg/etcd"
+	"github.com/seglberg/protoscan/pkg/ftp"
+	"github.com/seglberg/protoscan/pkg/grpc"
+	"github.com/seglberg/protoscan/pkg/http"
+	"github.com/seglberg/protoscan/pkg/kafka"
+	"github.com/seglberg/protoscan/pkg/kubernetes"
+	"github.com/seglberg/protoscan/pkg/ldap"
+	"github.com/seglberg/protoscan/pkg/mail"
+	"github.com/seglberg/protoscan/pkg/memcached"
+	"github.com/seglberg/protoscan/pkg/modbus"
+	"github.com/seglberg/protoscan/pkg/mqtt"
+	"github.com/seglberg/protoscan/pkg/mssql"
+	"github.com/seglberg/protoscan/pkg/mysql"
+	"github.com/seglberg/protoscan/pkg/nats"
+	"github.com/seglberg/protoscan/pkg/ntp"
+	"github.com/seglberg/protoscan/pkg/oracle"
+	"github.com/seglberg/protoscan/pkg/rdp"
+	"github.com/seglberg/protoscan/pkg/s7"
+	"github.com/seglberg/protoscan/pkg/smb"
+	"github.com/seglberg/protoscan/pkg/snmp"
+	"github.com/seglberg/protoscan/pkg/udp"
+	"github.com/seglberg/protoscan/pkg/vnc"
+	"github.com/seglberg/protoscan/pkg/zookeeper"
+)
+
+// A prober scans the target for a single protocol and returns a JSON serializable report
+// describing what was learned about the service.
+type prober func(ctx context.Context, target string) (interface{}, error)
+
+// probers contains every supported prober keyed by its protocol name.
+var probers = map[string]prober{
+	"mysql": probeMySQL,
+	"http":  probeHTTP(false),
+	"https": probeHTTP(true),
+
+	"mssql":         probeConn("tcp", func(conn net.Conn) (interface{}, error) { return mssql.Probe(conn) }),
+	"mssql-browser": probeUDP(func(t *udp.Transport) (interface{}, error) { return mssql.ProbeBrowser(t) }),
+	"oracle":        probeConn("tcp", func(conn net.Conn) (interface{}, error) { return oracle.Probe(conn, *args.tnsCommand) }),
+	"cassandra":     probeDial("tcp", func(dial dialFunc) (interface{}, error) { return cassandra.Probe(dial) }),
+	"memcached":     probeDial("tcp", func(dial dialFunc) (interface{}, error) { return memcached.Probe(dial) }),
+	"smtp":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return mail.ProbeSMTP(conn, targetHost()) }),
+	"pop3":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return mail.ProbePOP3(conn, targetHost()) }),
+	"imap":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return mail.ProbeIMAP(conn, targetHost()) }),
+	"ftp":           probeFTP,
+	"kafka":         probeConn("tcp", func(conn net.Conn) (interface{}, error) { return kafka.Probe(conn) }),
+	"amqp":          probeDial("tcp", func(dial dialFunc) (interface{}, error) { return amqp.Probe(dial) }),
+	"mqtt":          probeDial("tcp", func(dial dialFunc) (interface{}, error) { return mqtt.Probe(dial) }),
+	"ldap":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return ldap.Probe(conn) }),
+	"smb":           probeDial("tcp", func(dial dialFunc) (interface{}, error) { return smb.Probe(dial) }),
+	"rdp":           probeDial("tcp", func(dial dialFunc) (interface{}, error) { return rdp.Probe(dial) }),
+	"vnc":           probeConn("tcp", func(conn net.Conn) (interface{}, error) { return vnc.Probe(conn) }),
+	"dns":           probeDNS,
+	"snmp":          probeUDP(func(t *udp.Transport) (interface{}, error) { return snmp.Probe(t, *args.snmpCommunities) }),
+	"ntp":           probeUDP(func(t *udp.Transport) (interface{}, error) { return ntp.Probe(t, *args.ntpAmplification) }),
+	"zookeeper":     probeDial("tcp", func(dial dialFunc) (interface{}, error) { return zookeeper.Probe(dial) }),
+	"nats":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return nats.Probe(conn) }),
+	"clickhouse":    probeClickHouse,
+	"bolt":          probeConn("tcp", func(conn net.Conn) (interface{}, error) { return bolt.Probe(conn) }),
+	"grpc":          probeDial("tcp", func(dial dialFunc) (interface{}, error) { return grpc.Probe(dial, *args.target) }),
+	"modbus":        probeConn("tcp", probeModbus),
+	"s7":            probeDial("tcp", func(dial dialFunc) (interface{}, error) { return s7.Probe(dial, *args.icsDelay, *args.readTimeout) }),
+	"bacnet":        probeUDP(func(t *udp.Transport) (interface{}, error) { return bacnet.Probe(t, *args.icsDelay) }),
+
+	"elasticsearch": probeAPI(func(ctx context.Context, c *http.Client) (interface{}, error) { return elasticsearch.Probe(ctx, c) }),
+	"etcd":          probeAPI(func(ctx context.Context, c *http.Client) (interface{}, error) { return etcd.Probe(ctx, c) }),
+	"docker":        probeAPI(func(ctx context.Context, c *http.Client) (interface{}, error) { return docker.Probe(ctx, c) }),
+	"kubernetes":    probeAPI(func(ctx context.Context, c *http.Client) (interface{}, error) { return kubernetes.Probe(ctx, c) }),
+	"kubelet":       probeAPI(func(ctx context.Context, c *http.Client) (interface{}, error) { return kubernetes.ProbeKubelet(ctx, c) }),
+}
+
+// protocols returns the sorted names of all supported probers.
+func protocols() []string {
+	names := make([]string, 0, len(probers))
+	for name := range probers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// targetHost returns the host portion of the target, used as the server name for TLS.
+func targetHost() string {
+	host, _, _ := net.SplitHostPort(*args.target)
+	return host
+}
+
+// dial makes the initial connection to the target.
+// Once connected, the connection deadline is set according to the read timeout.
+func dial(ctx context.Context, network, target string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: *args.initTimeout,
+	}
+
+	conn, err := dialer.DialContext(ctx, network, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if *args.readTimeout > 0 {
+		err = conn.SetDeadline(time.Now().Add(*args.readTimeout))
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// probeConn creates a prober which dials the target over the given network and hands
+// the connection to the probe function.
+func probeConn(network string, probe func(conn net.Conn) (interface{}, error)) prober {
+	return func(ctx context.Context, target string) (interface{}, error) {
+		conn, err := dial(ctx, network, target)
+		if err != nil {
+			return nil, err
+		}
+		// Best effort close of connection.
+		defer func() {
+			_ = conn.Close()
+		}()
+
+		return probe(conn)
+	}
+}
+
+// A dialFunc makes a new connection to the target.
+type dialFunc = func() (net.Conn, error)
+
+// probeDial creates a prober which hands the probe function a dialFunc for the target,
+// for probers which need more than a single connection. The probe function is responsible
+// for closing every connection it makes.
+func probeDial(network string, probe func(dial dialFunc) (interface{}, error)) prober {
+	return func(ctx context.Context, target string) (interface{}, error) {
+		return probe(func() (net.Conn, error) {
+			return dial(ctx, network, target)
+		})
+	}
+}
+
+// dialUDP makes a UDP transport to the target.
+// The read timeout is split evenly between every attempt, so a UDP prober waits no longer for a response
+// than a TCP prober would, however many times the probe is resent.
+func dialUDP(ctx context.Context, target string) (*udp.Transport, error) {
+	conn, err := dial(ctx, "udp", target)
+	if err != nil {
+		return nil, err
+	}
+
+	retransmits := int(*args.udpRetransmits)
+	wait := *args.readTimeout / time.Duration(retransmits+1)
+
+	return udp.NewTransport(conn, wait, retransmits), nil
+}
+
+// probeUDP creates a prober which hands the probe function a UDP transport to the target.
+func probeUDP(probe func(t *udp.Transport) (interface{}, error)) prober {
+	return func(ctx context.Context, target string) (interface{}, error) {
+		t, err := dialUDP(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		// Best effort close of transport.
+		defer func() {
+			_ = t.Close()
+		}()
+
+		return probe(t)
+	}
+}
+
+// MySQL
+//	The server speaks first, so the prober only needs to read and decode the initial handshake.
+
+type mysqlReport struct {
+	ProtoVersion int             `json:"proto_version"`
+	Handshake    mysql.Handshake `json:"handshake"`
+}
+
+func probeMySQL(ctx context.Context, target string) (interface{}, error) {
+	conn, err := dial(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	packet, err := mysql.ReadPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	hs, err := mysql.DecodeHandshake(packet.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mysqlReport{
+		ProtoVersion: int(hs.GetProtoVersion()),
+		Handshake:    hs,
+	}, nil
+}
+
+// HTTP / HTTPS
+//	The client speaks first, sending the configured request.
+
+func probeHTTP(secure bool) prober {
+	return func(ctx context.Context, target string) (interface{}, error) {
+		header, err := parseHeaders(*args.httpHeaders)
+		if err != nil {
+			return nil, err
+		}
+
+		return http.Probe(ctx, newHTTPClient(target, secure), &http.ProbeOptions{
+			Method:       *args.httpMethod,
+			Path:         *args.httpPath,
+			Header:       header,
+			MaxRedirects: *args.httpMaxRedirects,
+		})
+	}
+}
+
+// probeAPI creates a prober which hands the probe function an HTTP client for the target,
+// for services exposing an HTTP API. Requests are made over HTTPS if --tls is set.
+func probeAPI(probe func(ctx context.Context, c *http.Client) (interface{}, error)) prober {
+	return func(ctx context.Context, target string) (interface{}, error) {
+		return probe(ctx, newHTTPClient(target, *args.tls))
+	}
+}
+
+// newHTTPClient creates an HTTP client for the target using the configured timeouts.
+func newHTTPClient(target string, secure bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: *args.initTimeout,
+	}
+	return http.NewClient(target, secure, dialer, *args.readTimeout)
+}
+
+// parseHeaders parses a list of 'Name: Value' strings into an HTTP header.
+func parseHeaders(values []string) (stdhttp.Header, error) {
+	header := stdhttp.Header{}
+	for _, v := range values {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid http header %q, expected 'Name: Value'", v)
+		}
+		header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return header, nil
+}
+
+// FTP
+//	The server speaks first. Anonymous logins list the working directory over a passive mode
+//	data connection, which is always made to the target host regardless of the address the server sends.
+
+func probeFTP(ctx context.Context, target string) (interface{}, error) {
+	return ftp.Probe(
+		func() (net.Conn, error) {
+			return dial(ctx, "tcp", target)
+		},
+		&ftp.Options{
+			ServerName: targetHost(),
+			Anonymous:  *args.ftpAnonymous,
+			DialData: func(port int) (net.Conn, error) {
+				return dial(ctx, "tcp", net.JoinHostPort(targetHost(), strconv.Itoa(port)))
+			},
+		},
+	)
+}
+
+// DNS
+//	Queries are sent over both UDP and TCP, each transport dialing the same target address.
+
+func probeDNS(ctx context.Context, target string) (interface{}, error) {
+	return dns.Probe(&dns.Options{
+		DialTCP: func() (net.Conn, error) {
+			return dial(ctx, "tcp", target)
+		},
+		DialUDP: func() (*udp.Transport, error) {
+			return dialUDP(ctx, target)
+		},
+		RecursionName: *args.dnsRecursionName,
+	})
+}
+
+// ClickHouse
+//	The native interface is probed on the target, and the HTTP interface on the configured port of the same host,
+//	even when the native interface can't be reached.
+
+func probeClickHouse(ctx context.Context, target string) (interface{}, error) {
+	var c *http.Client
+	if port := *args.clickhouseHTTPPort; port != 0 {
+		c = newHTTPClient(net.JoinHostPort(targetHost(), strconv.Itoa(int(port))), *args.tls)
+	}
+
+	return clickhouse.Probe(ctx, func() (net.Conn, error) {
+		return dial(ctx, "tcp", target)
+	}, c)
+}
+
+// Modbus
+//	Requests are addressed to the configured unit and paced by the industrial protocol delay, the read timeout
+//	applies to each request rather than the whole connection.
+
+func probeModbus(conn net.Conn) (interface{}, error) {
+	return modbus.Probe(conn, *args.modbusUnitID, *args.icsDelay, *args.readTimeout)
+}
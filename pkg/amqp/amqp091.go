@@ -0,0 +1,327 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package amqp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrStartDecode = fmt.Errorf("connection.start decode")
+var ErrStartTruncated = fmt.Errorf("%w: truncated payload or not an amqp connection.start", ErrStartDecode)
+
+// ProtocolHeader091 is the protocol header sent by AMQP 0-9-1 clients.
+var ProtocolHeader091 = []byte{'A', 'M', 'Q', 'P', 0, 0, 9, 1}
+
+// Frame Types + Markers
+const (
+	frameMethod = 1
+	frameEnd    = 0xCE
+)
+
+// Connection.Start Method
+const (
+	classConnection   = 10
+	methodStart       = 10
+	frameHeaderSize   = 7
+	maxFramePayload   = 1 << 20
+	protocolHeaderLen = 8
+)
+
+// Start represents the AMQP 0-9-1 Connection.Start method, sent by the server once the client
+// has sent its protocol header.
+//
+// See https://www.rabbitmq.com/resources/specs/amqp0-9-1.pdf
+type Start struct {
+	// VersionMajor is the protocol major version.
+	VersionMajor uint8 `json:"version_major"`
+
+	// VersionMinor is the protocol minor version.
+	VersionMinor uint8 `json:"version_minor"`
+
+	// ServerProperties contains the server properties table.
+	ServerProperties map[string]interface{} `json:"server_properties"`
+
+	// Mechanisms lists the offered SASL mechanisms.
+	Mechanisms []string `json:"mechanisms"`
+
+	// Locales lists the offered message locales.
+	Locales []string `json:"locales"`
+}
+
+// ReadStart reads the server's response to the protocol header.
+// If the server doesn't support the requested protocol version, it responds with the protocol header
+// it does support, which is returned as a *HeaderMismatchError.
+func ReadStart(r io.Reader) (*Start, error) {
+	// (1) Frame Header
+	//	1 Byte: Type
+	//	2 Bytes: Channel
+	//	4 Bytes: Payload Size
+
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrStartTruncated, ErrStartDecode)
+	}
+
+	if string(header[:4]) == "AMQP" {
+		rest := make([]byte, protocolHeaderLen-frameHeaderSize)
+		if _, err := io.ReadFull(r, rest); err != nil {
+			return nil, wire.ReadError(err, ErrStartTruncated, ErrStartDecode)
+		}
+		return nil, &HeaderMismatchError{Header: append(header, rest...)}
+	}
+
+	if header[0] != frameMethod {
+		return nil, fmt.Errorf("%w: unexpected frame type %d", ErrStartDecode, header[0])
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > maxFramePayload {
+		return nil, fmt.Errorf("%w: frame too large, connection is not amqp", ErrStartDecode)
+	}
+
+	// (2) Payload + Frame End
+
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, wire.ReadError(err, ErrStartTruncated, ErrStartDecode)
+	}
+	if payload[size] != frameEnd {
+		return nil, fmt.Errorf("%w: missing frame end, connection is not amqp", ErrStartDecode)
+	}
+
+	return DecodeStart(payload[:size])
+}
+
+// DecodeStart attempts to decode the given method frame payload as a Connection.Start method.
+func DecodeStart(payload []byte) (*Start, error) {
+	d := &decoder091{buf: payload}
+
+	// 2 Bytes: Class ID, 2 Bytes: Method ID
+
+	class, method := d.uint16(), d.uint16()
+	if d.err == nil && (class != classConnection || method != methodStart) {
+		return nil, fmt.Errorf("%w: unexpected method %d.%d", ErrStartDecode, class, method)
+	}
+
+	start := &Start{
+		VersionMajor:     d.uint8(),
+		VersionMinor:     d.uint8(),
+		ServerProperties: d.table(),
+		Mechanisms:       strings.Fields(d.longString()),
+		Locales:          strings.Fields(d.longString()),
+	}
+
+	if d.err != nil {
+		return nil, ErrStartTruncated
+	}
+	return start, nil
+}
+
+// HeaderMismatchError is returned when the server responds with a protocol header instead of
+// Connection.Start, indicating the protocol version it supports.
+type HeaderMismatchError struct {
+	// Header is the protocol header sent by the server.
+	Header []byte
+}
+
+func (e *HeaderMismatchError) Error() string {
+	return fmt.Sprintf("server responded with protocol header %s", FormatHeader(e.Header))
+}
+
+// FormatHeader formats a protocol header for display, for example "AMQP 0-9-1" or "AMQP 1.0.0 (SASL)".
+func FormatHeader(h []byte) string {
+	if len(h) != protocolHeaderLen || string(h[:4]) != "AMQP" {
+		return fmt.Sprintf("%q", h)
+	}
+	if h[4] == 0 && h[5] == 0 {
+		return fmt.Sprintf("AMQP %d-%d-%d", h[5], h[6], h[7])
+	}
+	switch h[4] {
+	case protocolIDAMQP:
+		return fmt.Sprintf("AMQP %d.%d.%d", h[5], h[6], h[7])
+	case protocolIDTLS:
+		return fmt.Sprintf("AMQP %d.%d.%d (TLS)", h[5], h[6], h[7])
+	case protocolIDSASL:
+		return fmt.Sprintf("AMQP %d.%d.%d (SASL)", h[5], h[6], h[7])
+	default:
+		return fmt.Sprintf("AMQP %d %d.%d.%d", h[4], h[5], h[6], h[7])
+	}
+}
+
+// decoder091 reads AMQP 0-9-1 field values.
+// The first error encountered is kept and all following reads return zero values.
+type decoder091 struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder091) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = ErrStartTruncated
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder091) uint8() uint8 {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder091) uint16() uint16 {
+	if b := d.read(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder091) uint32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder091) uint64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder091) shortString() string {
+	return string(d.read(int(d.uint8())))
+}
+
+func (d *decoder091) longString() string {
+	return string(d.read(int(d.uint32())))
+}
+
+// Reads a field table: a long size followed by name/value pairs.
+func (d *decoder091) table() map[string]interface{} {
+	size := int(d.uint32())
+	sub := &decoder091{buf: d.read(size)}
+	if d.err != nil {
+		return nil
+	}
+
+	table := map[string]interface{}{}
+	for sub.pos < len(sub.buf) && sub.err == nil {
+		name := sub.shortString()
+		table[name] = sub.value()
+	}
+
+	if sub.err != nil {
+		d.err = sub.err
+	}
+	return table
+}
+
+// Reads a field array: a long size followed by values.
+func (d *decoder091) array() []interface{} {
+	size := int(d.uint32())
+	sub := &decoder091{buf: d.read(size)}
+	if d.err != nil {
+		return nil
+	}
+
+	array := []interface{}{}
+	for sub.pos < len(sub.buf) && sub.err == nil {
+		array = append(array, sub.value())
+	}
+
+	if sub.err != nil {
+		d.err = sub.err
+	}
+	return array
+}
+
+// Returns the string form of a float which isn't finite ("NaN", "+Inf" or "-Inf"), since JSON can't represent it.
+func nonFinite(f float64) (string, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// Reads a single typed field value.
+// Types follow the RabbitMQ / Qpid interpretation of the specification.
+func (d *decoder091) value() interface{} {
+	switch t := d.uint8(); t {
+	case 't':
+		return d.uint8() != 0
+	case 'b':
+		return int8(d.uint8())
+	case 'B':
+		return d.uint8()
+	case 's':
+		return int16(d.uint16())
+	case 'u':
+		return d.uint16()
+	case 'I':
+		return int32(d.uint32())
+	case 'i':
+		return d.uint32()
+	case 'l':
+		return int64(d.uint64())
+	case 'f':
+		f := math.Float32frombits(d.uint32())
+		if s, ok := nonFinite(float64(f)); ok {
+			return s
+		}
+		return f
+	case 'd':
+		f := math.Float64frombits(d.uint64())
+		if s, ok := nonFinite(f); ok {
+			return s
+		}
+		return f
+	case 'D':
+		scale := d.uint8()
+		value := int32(d.uint32())
+		return float64(value) / math.Pow10(int(scale))
+	case 'S', 'x':
+		return d.longString()
+	case 'A':
+		return d.array()
+	case 'T':
+		return d.uint64()
+	case 'F':
+		return d.table()
+	case 'V':
+		return nil
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: unknown field type %q", ErrStartDecode, t)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package amqp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrSASLDecode = fmt.Errorf("sasl-mechanisms decode")
+var ErrSASLTruncated = fmt.Errorf("%w: truncated frame or not an amqp sasl-mechanisms frame", ErrSASLDecode)
+
+// AMQP 1.0 Protocol IDs
+const (
+	protocolIDAMQP = 0
+	protocolIDTLS  = 2
+	protocolIDSASL = 3
+)
+
+// ProtocolHeaderSASL is the protocol header sent by AMQP 1.0 clients to start the SASL layer.
+var ProtocolHeaderSASL = []byte{'A', 'M', 'Q', 'P', protocolIDSASL, 1, 0, 0}
+
+// AMQP 1.0 Frame + Type Constants
+const (
+	frameTypeSASL           = 0x01
+	descriptorSASLMechanism = 0x40
+	maxFrameSize            = 1 << 20
+)
+
+// AMQP 1.0 Type Format Codes
+const (
+	codeDescribed  = 0x00
+	codeNull       = 0x40
+	codeSmallULong = 0x53
+	codeULong      = 0x80
+	codeSym8       = 0xa3
+	codeSym32      = 0xb3
+	codeList0      = 0x45
+	codeList8      = 0xc0
+	codeList32     = 0xd0
+	codeArray8     = 0xe0
+	codeArray32    = 0xf0
+)
+
+// ReadProtocolHeader reads the 8 byte protocol header the server responds with.
+func ReadProtocolHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, protocolHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrSASLTruncated, ErrSASLDecode)
+	}
+	if string(header[:4]) != "AMQP" {
+		return nil, fmt.Errorf("%w: invalid protocol header, connection is not amqp", ErrSASLDecode)
+	}
+	return header, nil
+}
+
+// ReadSASLMechanisms reads a SASL frame and decodes it as a sasl-mechanisms performative,
+// returning the offered mechanisms.
+//
+// See http://docs.oasis-open.org/amqp/core/v1.0/os/amqp-core-security-v1.0-os.html#type-sasl-mechanisms
+func ReadSASLMechanisms(r io.Reader) ([]string, error) {
+	// Frame Header
+	//	4 Bytes: Size (including header)
+	//	1 Byte: Data Offset (in 4 byte words)
+	//	1 Byte: Type
+	//	2 Bytes: Type Specific (ignored for SASL)
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrSASLTruncated, ErrSASLDecode)
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	doff := int(header[4]) * 4
+	if size > maxFrameSize || int(size) < doff || doff < 8 {
+		return nil, fmt.Errorf("%w: invalid frame size, connection is not amqp", ErrSASLDecode)
+	}
+	if header[5] != frameTypeSASL {
+		return nil, fmt.Errorf("%w: unexpected frame type %d", ErrSASLDecode, header[5])
+	}
+
+	frame := make([]byte, size-8)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, wire.ReadError(err, ErrSASLTruncated, ErrSASLDecode)
+	}
+
+	return DecodeSASLMechanisms(frame[doff-8:])
+}
+
+// DecodeSASLMechanisms decodes a sasl-mechanisms performative (the body of a SASL frame).
+func DecodeSASLMechanisms(body []byte) ([]string, error) {
+	d := &decoder10{buf: body}
+
+	// Descriptor: 0x00 followed by the descriptor code (ulong 0x40).
+
+	if d.uint8() != codeDescribed {
+		return nil, fmt.Errorf("%w: performative is not a described type", ErrSASLDecode)
+	}
+	var descriptor uint64
+	switch d.uint8() {
+	case codeSmallULong:
+		descriptor = uint64(d.uint8())
+	case codeULong:
+		descriptor = d.uint64()
+	default:
+		return nil, fmt.Errorf("%w: unexpected descriptor type", ErrSASLDecode)
+	}
+	if d.err == nil && descriptor != descriptorSASLMechanism {
+		return nil, fmt.Errorf("%w: unexpected performative 0x%02x", ErrSASLDecode, descriptor)
+	}
+
+	// Fields: a list whose first field is sasl-server-mechanisms (a multiple symbol).
+
+	var count int
+	switch d.uint8() {
+	case codeList0:
+		count = 0
+	case codeList8:
+		_ = d.uint8() // Size
+		count = int(d.uint8())
+	case codeList32:
+		_ = d.uint32() // Size
+		count = int(d.uint32())
+	default:
+		return nil, fmt.Errorf("%w: performative fields are not a list", ErrSASLDecode)
+	}
+
+	mechanisms := []string{}
+	if count > 0 {
+		mechanisms = d.symbols()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return mechanisms, nil
+}
+
+// decoder10 reads AMQP 1.0 encoded values.
+// The first error encountered is kept and all following reads return zero values.
+type decoder10 struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder10) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = ErrSASLTruncated
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder10) uint8() uint8 {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder10) uint32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder10) uint64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// Reads a "multiple" symbol field: either null, a single symbol or an array of symbols.
+func (d *decoder10) symbols() []string {
+	switch code := d.uint8(); code {
+	case codeNull:
+		return []string{}
+	case codeSym8, codeSym32:
+		return []string{d.symbol(code)}
+	case codeArray8, codeArray32:
+		var count int
+		if code == codeArray8 {
+			_ = d.uint8() // Size
+			count = int(d.uint8())
+		} else {
+			_ = d.uint32() // Size
+			count = int(d.uint32())
+		}
+
+		// Arrays share a single element constructor.
+		elem := d.uint8()
+		if elem != codeSym8 && elem != codeSym32 {
+			d.err = fmt.Errorf("%w: mechanisms array is not of symbols", ErrSASLDecode)
+			return nil
+		}
+
+		symbols := []string{}
+		for i := 0; i < count && d.err == nil; i++ {
+			symbols = append(symbols, d.symbol(elem))
+		}
+		return symbols
+	default:
+		d.err = fmt.Errorf("%w: mechanisms are not symbols", ErrSASLDecode)
+		return nil
+	}
+}
+
+// Reads a symbol value (without its constructor).
+func (d *decoder10) symbol(code uint8) string {
+	if code == codeSym8 {
+		return string(d.read(int(d.uint8())))
+	}
+	return string(d.read(int(d.uint32())))
+}
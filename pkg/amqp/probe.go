@@ -0,0 +1,191 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package amqp provides facilities for probing and inspecting AMQP 0-9-1 and AMQP 1.0 brokers.
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// Report contains the information gathered from an AMQP broker.
+type Report struct {
+	// AMQP091 contains the AMQP 0-9-1 results, if the broker speaks it.
+	AMQP091 *Report091 `json:"amqp_0_9_1,omitempty"`
+
+	// AMQP091Error contains the reason the AMQP 0-9-1 probe failed, if it did.
+	AMQP091Error string `json:"amqp_0_9_1_error,omitempty"`
+
+	// AMQP10 contains the AMQP 1.0 results, if the broker speaks it.
+	AMQP10 *Report10 `json:"amqp_1_0,omitempty"`
+
+	// AMQP10Error contains the reason the AMQP 1.0 probe failed, if it did.
+	AMQP10Error string `json:"amqp_1_0_error,omitempty"`
+}
+
+// Report091 contains the information gathered from an AMQP 0-9-1 Connection.Start.
+type Report091 struct {
+	// Version is the protocol version, for example "0-9".
+	Version string `json:"version"`
+
+	// Product is the server product name, for example "RabbitMQ".
+	Product string `json:"product,omitempty"`
+
+	// ProductVersion is the server product version.
+	ProductVersion string `json:"product_version,omitempty"`
+
+	// Platform is the server platform, for example "Erlang/OTP 23.1".
+	Platform string `json:"platform,omitempty"`
+
+	// ClusterName is the RabbitMQ cluster name.
+	ClusterName string `json:"cluster_name,omitempty"`
+
+	// Capabilities contains the server capabilities table.
+	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+
+	// Mechanisms lists the offered SASL mechanisms.
+	Mechanisms []string `json:"mechanisms"`
+
+	// Locales lists the offered message locales.
+	Locales []string `json:"locales"`
+
+	// ServerProperties contains the complete server properties table.
+	ServerProperties map[string]interface{} `json:"server_properties"`
+}
+
+// Report10 contains the information gathered from the AMQP 1.0 SASL layer.
+type Report10 struct {
+	// Header is the protocol header the server responded with.
+	Header string `json:"header"`
+
+	// SASL indicates if the server accepted the SASL layer.
+	SASL bool `json:"sasl"`
+
+	// Mechanisms lists the offered SASL mechanisms.
+	Mechanisms []string `json:"mechanisms,omitempty"`
+}
+
+// Probe sends the AMQP 0-9-1 and AMQP 1.0 SASL protocol headers, each on its own connection since
+// brokers close the connection after a protocol header they don't support.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{}
+
+	// (1) AMQP 0-9-1
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	report.AMQP091, err = probe091(conn)
+	_ = conn.Close()
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, err
+		}
+		report.AMQP091Error = err.Error()
+	}
+
+	// (2) AMQP 1.0
+
+	conn, err = dial()
+	if err != nil {
+		return nil, err
+	}
+	report.AMQP10, err = probe10(conn)
+	_ = conn.Close()
+	if err != nil {
+		report.AMQP10Error = err.Error()
+	}
+
+	if report.AMQP091 == nil && report.AMQP10 == nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Sends the AMQP 0-9-1 protocol header and decodes Connection.Start.
+func probe091(rw io.ReadWriter) (*Report091, error) {
+	if _, err := rw.Write(ProtocolHeader091); err != nil {
+		return nil, err
+	}
+
+	start, err := ReadStart(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report091{
+		Version:          fmt.Sprintf("%d-%d", start.VersionMajor, start.VersionMinor),
+		Product:          stringProperty(start.ServerProperties, "product"),
+		ProductVersion:   stringProperty(start.ServerProperties, "version"),
+		Platform:         stringProperty(start.ServerProperties, "platform"),
+		ClusterName:      stringProperty(start.ServerProperties, "cluster_name"),
+		Mechanisms:       start.Mechanisms,
+		Locales:          start.Locales,
+		ServerProperties: start.ServerProperties,
+	}
+	if capabilities, ok := start.ServerProperties["capabilities"].(map[string]interface{}); ok {
+		report.Capabilities = capabilities
+	}
+
+	return report, nil
+}
+
+// Sends the AMQP 1.0 SASL protocol header and decodes the sasl-mechanisms frame.
+func probe10(rw io.ReadWriter) (*Report10, error) {
+	if _, err := rw.Write(ProtocolHeaderSASL); err != nil {
+		return nil, err
+	}
+
+	header, err := ReadProtocolHeader(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Brokers not supporting AMQP 1.0 respond with the header of the version they do support.
+
+	if header[5] != 1 {
+		return nil, &HeaderMismatchError{Header: header}
+	}
+
+	report := &Report10{
+		Header: FormatHeader(header),
+	}
+
+	// Brokers not requiring SASL respond with a different AMQP 1.0 header.
+
+	if string(header) != string(ProtocolHeaderSASL) {
+		return report, nil
+	}
+	report.SASL = true
+
+	report.Mechanisms, err = ReadSASLMechanisms(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Returns the string value of a server property, or an empty string if it isn't set or isn't a string.
+func stringProperty(properties map[string]interface{}, name string) string {
+	v, _ := properties[name].(string)
+	return v
+}
@@ -0,0 +1,462 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bacnet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDU Types
+const (
+	pduConfirmedRequest   = 0x0
+	pduUnconfirmedRequest = 0x1
+	pduSimpleAck          = 0x2
+	pduComplexAck         = 0x3
+	pduError              = 0x5
+	pduReject             = 0x6
+	pduAbort              = 0x7
+)
+
+// Service Choices
+const (
+	serviceIAm          = 0x00
+	serviceWhoIs        = 0x08
+	serviceReadProperty = 0x0C
+)
+
+// Flag set on the first byte of a segmented complex acknowledgement.
+const segmentedFlag = 0x08
+
+// Object Types
+const ObjectDevice = 8
+
+// WildcardInstance addresses the device object of whichever device receives the request.
+const WildcardInstance = 4194303
+
+// Property Identifiers
+const (
+	PropertyApplicationSoftwareVersion = 12
+	PropertyDescription                = 28
+	PropertyFirmwareRevision           = 44
+	PropertyLocation                   = 58
+	PropertyModelName                  = 70
+	PropertyObjectIdentifier           = 75
+	PropertyObjectName                 = 77
+	PropertyVendorIdentifier           = 120
+	PropertyVendorName                 = 121
+)
+
+// Application Tags
+const (
+	tagNull            = 0
+	tagBoolean         = 1
+	tagUnsigned        = 2
+	tagSigned          = 3
+	tagReal            = 4
+	tagDouble          = 5
+	tagCharacterString = 7
+	tagEnumerated      = 9
+	tagObjectID        = 12
+)
+
+// ObjectID identifies an object of a device.
+type ObjectID struct {
+	Type     uint16
+	Instance uint32
+}
+
+// IAm is a decoded I-Am request, announcing a device.
+type IAm struct {
+	Device       ObjectID
+	MaxAPDU      uint64
+	Segmentation uint64
+	VendorID     uint64
+}
+
+// ErrorPDU is returned when the device responds to a confirmed request with an error.
+type ErrorPDU struct {
+	Class uint64
+	Code  uint64
+}
+
+func (e *ErrorPDU) Error() string {
+	classes := []string{"device", "object", "property", "resources", "security", "services", "vt", "communication"}
+	class := fmt.Sprintf("UNKNOWN(%d)", e.Class)
+	if e.Class < uint64(len(classes)) {
+		class = classes[e.Class]
+	}
+
+	var code string
+	switch e.Code {
+	case 0:
+		code = "other"
+	case 27:
+		code = "read-access-denied"
+	case 31:
+		code = "unknown-object"
+	case 32:
+		code = "unknown-property"
+	default:
+		code = fmt.Sprintf("UNKNOWN(%d)", e.Code)
+	}
+
+	return fmt.Sprintf("error class %s code %s", class, code)
+}
+
+// RejectError is returned when the device rejects or aborts a confirmed request.
+type RejectError struct {
+	Abort  bool
+	Reason uint8
+}
+
+func (e *RejectError) Error() string {
+	if e.Abort {
+		return fmt.Sprintf("request aborted with reason %d", e.Reason)
+	}
+	return fmt.Sprintf("request rejected with reason %d", e.Reason)
+}
+
+// EncodeWhoIs encodes an unbounded Who-Is request, asking every device to announce itself.
+func EncodeWhoIs() []byte {
+	return []byte{pduUnconfirmedRequest << 4, serviceWhoIs}
+}
+
+// DecodeIAm decodes an I-Am request.
+func DecodeIAm(apdu []byte) (*IAm, error) {
+	// I-Am Request
+	//	1 Byte: PDU Type
+	//	1 Byte: Service Choice
+	//	Application Tagged: Device Object Identifier
+	//	Application Tagged: Max APDU Length Accepted (Unsigned)
+	//	Application Tagged: Segmentation Supported (Enumerated)
+	//	Application Tagged: Vendor ID (Unsigned)
+
+	if len(apdu) < 2 {
+		return nil, ErrPacketTruncated
+	}
+	if apdu[0]>>4 != pduUnconfirmedRequest || apdu[1] != serviceIAm {
+		return nil, fmt.Errorf("%w: not an i-am request", ErrPacketDecode)
+	}
+
+	values := make([]interface{}, 0, 4)
+	for pos := 2; len(values) < 4; {
+		t, next, err := readTag(apdu, pos)
+		if err != nil {
+			return nil, err
+		}
+		if t.context {
+			return nil, fmt.Errorf("%w: unexpected context tag in i-am request", ErrPacketDecode)
+		}
+		values = append(values, decodeValue(t))
+		pos = next
+	}
+
+	iam := &IAm{}
+	var ok [4]bool
+	iam.Device, ok[0] = values[0].(ObjectID)
+	iam.MaxAPDU, ok[1] = values[1].(uint64)
+	iam.Segmentation, ok[2] = values[2].(uint64)
+	iam.VendorID, ok[3] = values[3].(uint64)
+	if !ok[0] || !ok[1] || !ok[2] || !ok[3] {
+		return nil, fmt.Errorf("%w: unexpected value types in i-am request", ErrPacketDecode)
+	}
+	return iam, nil
+}
+
+// EncodeReadProperty encodes a ReadProperty confirmed request for the property of the object.
+func EncodeReadProperty(invokeID uint8, object ObjectID, property uint32) []byte {
+	// Confirmed Request
+	//	1 Byte: PDU Type and Flags (Segmented Response Not Accepted)
+	//	1 Byte: Max Segments and Max APDU Length Accepted (1476 Bytes)
+	//	1 Byte: Invoke ID
+	//	1 Byte: Service Choice
+	//	Context Tag 0: Object Identifier
+	//	Context Tag 1: Property Identifier
+
+	buf := []byte{pduConfirmedRequest << 4, 0x05, invokeID, serviceReadProperty}
+
+	buf = append(buf, 0x0C, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(object.Type)<<22|object.Instance&0x3FFFFF)
+
+	switch {
+	case property <= 0xFF:
+		buf = append(buf, 0x19, byte(property))
+	case property <= 0xFFFF:
+		buf = append(buf, 0x1A, byte(property>>8), byte(property))
+	default:
+		buf = append(buf, 0x1C, byte(property>>24), byte(property>>16), byte(property>>8), byte(property))
+	}
+	return buf
+}
+
+// InvokeID returns the invoke ID of a response to a confirmed request, and false if the APDU isn't one.
+func InvokeID(apdu []byte) (uint8, bool) {
+	if len(apdu) < 2 {
+		return 0, false
+	}
+	switch apdu[0] >> 4 {
+	case pduSimpleAck, pduComplexAck, pduError, pduReject, pduAbort:
+		return apdu[1], true
+	default:
+		return 0, false
+	}
+}
+
+// DecodeReadPropertyResponse decodes the response to a ReadProperty request, returning the property values.
+// An ErrorPDU or RejectError is returned if the device refused the request.
+func DecodeReadPropertyResponse(apdu []byte) ([]interface{}, error) {
+	if len(apdu) < 3 {
+		return nil, ErrPacketTruncated
+	}
+
+	switch apdu[0] >> 4 {
+	case pduComplexAck:
+	case pduError:
+		// Error
+		//	1 Byte: PDU Type
+		//	1 Byte: Invoke ID
+		//	1 Byte: Service Choice
+		//	Application Tagged: Error Class (Enumerated)
+		//	Application Tagged: Error Code (Enumerated)
+
+		class, pos, err := readTag(apdu, 3)
+		if err != nil {
+			return nil, err
+		}
+		code, _, err := readTag(apdu, pos)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ErrorPDU{Class: decodeUnsigned(class.data), Code: decodeUnsigned(code.data)}
+	case pduReject, pduAbort:
+		return nil, &RejectError{Abort: apdu[0]>>4 == pduAbort, Reason: apdu[2]}
+	default:
+		return nil, fmt.Errorf("%w: unexpected pdu type 0x%x", ErrPacketDecode, apdu[0]>>4)
+	}
+
+	// Complex Acknowledgement
+	//	1 Byte: PDU Type and Flags
+	//	1 Byte: Invoke ID
+	//	1 Byte: Service Choice
+	//	Context Tag 0: Object Identifier
+	//	Context Tag 1: Property Identifier
+	//	Context Tag 2: Property Array Index (Optional)
+	//	Opening Tag 3
+	//	Application Tagged: Values
+	//	Closing Tag 3
+
+	if apdu[0]&segmentedFlag != 0 {
+		return nil, fmt.Errorf("%w: segmented responses are not supported", ErrPacketDecode)
+	}
+	if apdu[2] != serviceReadProperty {
+		return nil, fmt.Errorf("%w: unexpected service choice 0x%02x", ErrPacketDecode, apdu[2])
+	}
+
+	pos := 3
+	for {
+		t, next, err := readTag(apdu, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos = next
+		if t.context && t.opening && t.number == 3 {
+			break
+		}
+	}
+
+	values := []interface{}{}
+	for {
+		t, next, err := readTag(apdu, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos = next
+		if t.context && t.closing && t.number == 3 {
+			return values, nil
+		}
+		if t.context {
+			return nil, fmt.Errorf("%w: unexpected context tag in property value", ErrPacketDecode)
+		}
+		values = append(values, decodeValue(t))
+	}
+}
+
+// SegmentationName returns the name of the segmentation supported, for example "no-segmentation".
+func SegmentationName(segmentation uint64) string {
+	switch segmentation {
+	case 0:
+		return "segmented-both"
+	case 1:
+		return "segmented-transmit"
+	case 2:
+		return "segmented-receive"
+	case 3:
+		return "no-segmentation"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", segmentation)
+	}
+}
+
+// A tag and its content.
+type tag struct {
+	number  uint8
+	context bool
+	opening bool
+	closing bool
+	data    []byte
+}
+
+// Reads the tag at the given position, returning the position following its content.
+func readTag(b []byte, pos int) (tag, int, error) {
+	// Tag
+	//	4 Bits: Tag Number (15, Extended)
+	//	1 Bit: Class (Context Specific)
+	//	3 Bits: Length / Value / Type (5, Extended Length; 6, Opening; 7, Closing)
+	//	1 Byte: Extended Tag Number (Optional)
+	//	1, 3 or 5 Bytes: Extended Length (Optional)
+	//	Variable: Content
+
+	if pos >= len(b) {
+		return tag{}, 0, ErrPacketTruncated
+	}
+	t := tag{number: b[pos] >> 4, context: b[pos]&0x08 != 0}
+	lvt := int(b[pos] & 0x07)
+	pos++
+
+	if t.number == 15 {
+		if pos >= len(b) {
+			return tag{}, 0, ErrPacketTruncated
+		}
+		t.number = b[pos]
+		pos++
+	}
+
+	switch {
+	case t.context && lvt == 6:
+		t.opening = true
+		return t, pos, nil
+	case t.context && lvt == 7:
+		t.closing = true
+		return t, pos, nil
+	case !t.context && t.number == tagBoolean:
+		// The value of an application tagged boolean is held in the tag itself.
+		t.data = []byte{byte(lvt)}
+		return t, pos, nil
+	}
+
+	length := lvt
+	if lvt == 5 {
+		if pos >= len(b) {
+			return tag{}, 0, ErrPacketTruncated
+		}
+		length = int(b[pos])
+		pos++
+
+		switch length {
+		case 254:
+			if pos+2 > len(b) {
+				return tag{}, 0, ErrPacketTruncated
+			}
+			length = int(binary.BigEndian.Uint16(b[pos:]))
+			pos += 2
+		case 255:
+			if pos+4 > len(b) {
+				return tag{}, 0, ErrPacketTruncated
+			}
+			length = int(binary.BigEndian.Uint32(b[pos:]))
+			pos += 4
+		}
+	}
+
+	if length < 0 || pos+length > len(b) {
+		return tag{}, 0, ErrPacketTruncated
+	}
+	t.data = b[pos : pos+length]
+	return t, pos + length, nil
+}
+
+// Decodes the value of an application tag. Values of unsupported types are returned hex encoded.
+func decodeValue(t tag) interface{} {
+	switch t.number {
+	case tagNull:
+		return nil
+	case tagBoolean:
+		return t.data[0] != 0
+	case tagUnsigned, tagEnumerated:
+		return decodeUnsigned(t.data)
+	case tagSigned:
+		v := int64(decodeUnsigned(t.data))
+		if n := uint(len(t.data)); n > 0 && n < 8 {
+			v = v << (64 - 8*n) >> (64 - 8*n)
+		}
+		return v
+	case tagReal:
+		if len(t.data) == 4 {
+			return math.Float32frombits(binary.BigEndian.Uint32(t.data))
+		}
+	case tagDouble:
+		if len(t.data) == 8 {
+			return math.Float64frombits(binary.BigEndian.Uint64(t.data))
+		}
+	case tagCharacterString:
+		return decodeCharacterString(t.data)
+	case tagObjectID:
+		if len(t.data) == 4 {
+			v := binary.BigEndian.Uint32(t.data)
+			return ObjectID{Type: uint16(v >> 22), Instance: v & 0x3FFFFF}
+		}
+	}
+	return hex.EncodeToString(t.data)
+}
+
+// Decodes a big endian unsigned integer of up to 8 bytes.
+func decodeUnsigned(b []byte) uint64 {
+	var v uint64
+	for i := 0; i < len(b) && i < 8; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// Decodes a character string, the first byte being its character set.
+func decodeCharacterString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	switch b[0] {
+	case 4: // UCS-2
+		units := make([]uint16, len(b[1:])/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[1+2*i:])
+		}
+		return string(utf16.Decode(units))
+	case 5: // ISO 8859-1
+		var s strings.Builder
+		for _, c := range b[1:] {
+			s.WriteRune(rune(c))
+		}
+		return s.String()
+	default: // UTF-8, formerly ANSI X3.4
+		return string(b[1:])
+	}
+}
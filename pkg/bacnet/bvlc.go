@@ -0,0 +1,143 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bacnet provides facilities for probing BACnet/IP devices and reading their device object properties.
+package bacnet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or not a bacnet device", ErrPacketDecode)
+
+// BVLC Type of BACnet/IP.
+const bvlcType = 0x81
+
+// BVLC Functions
+const (
+	bvlcResult                = 0x00
+	bvlcForwardedNPDU         = 0x04
+	bvlcOriginalUnicastNPDU   = 0x0A
+	bvlcOriginalBroadcastNPDU = 0x0B
+)
+
+// Size of the BVLC header.
+const bvlcHeaderSize = 4
+
+// NPDU Protocol Version
+const npduVersion = 0x01
+
+// NPDU Control Flags
+const (
+	npduNetworkMessage = 0x80
+	npduDestination    = 0x20
+	npduSource         = 0x08
+	npduExpectingReply = 0x04
+)
+
+// EncodePacket encodes the APDU in an NPDU addressed to the local network, in an Original-Unicast-NPDU.
+// Confirmed requests are expecting a reply.
+//
+// See ANSI/ASHRAE Standard 135 Annex J and clause 6
+func EncodePacket(apdu []byte, expectingReply bool) []byte {
+	// BVLC Header
+	//	1 Byte: Type (0x81)
+	//	1 Byte: Function
+	//	2 Bytes: Length (including header)
+	// NPDU Header
+	//	1 Byte: Version (0x01)
+	//	1 Byte: Control
+
+	control := uint8(0)
+	if expectingReply {
+		control |= npduExpectingReply
+	}
+
+	buf := make([]byte, bvlcHeaderSize, bvlcHeaderSize+2+len(apdu))
+	buf[0] = bvlcType
+	buf[1] = bvlcOriginalUnicastNPDU
+	binary.BigEndian.PutUint16(buf[2:], uint16(bvlcHeaderSize+2+len(apdu)))
+	buf = append(buf, npduVersion, control)
+	return append(buf, apdu...)
+}
+
+// DecodePacket decodes a BACnet/IP datagram, returning the APDU it carries.
+func DecodePacket(datagram []byte) ([]byte, error) {
+	if len(datagram) < bvlcHeaderSize {
+		return nil, ErrPacketTruncated
+	}
+	if datagram[0] != bvlcType {
+		return nil, fmt.Errorf("%w: unexpected bvlc type 0x%02x, device is not bacnet/ip", ErrPacketDecode, datagram[0])
+	}
+	if int(binary.BigEndian.Uint16(datagram[2:])) != len(datagram) {
+		return nil, fmt.Errorf("%w: bvlc length mismatch", ErrPacketDecode)
+	}
+
+	pos := bvlcHeaderSize
+	switch datagram[1] {
+	case bvlcOriginalUnicastNPDU, bvlcOriginalBroadcastNPDU:
+	case bvlcForwardedNPDU:
+		// 6 Bytes: Original Source Address and Port
+		pos += 6
+	case bvlcResult:
+		return nil, fmt.Errorf("%w: bvlc result", ErrPacketDecode)
+	default:
+		return nil, fmt.Errorf("%w: unexpected bvlc function 0x%02x", ErrPacketDecode, datagram[1])
+	}
+
+	// NPDU Header
+	//	1 Byte: Version
+	//	1 Byte: Control
+	//	2 Bytes: Destination Network (Optional)
+	//	1 Byte: Destination Address Length (Optional)
+	//	n Bytes: Destination Address (Optional)
+	//	2 Bytes: Source Network (Optional)
+	//	1 Byte: Source Address Length (Optional)
+	//	n Bytes: Source Address (Optional)
+	//	1 Byte: Hop Count (Optional, with Destination)
+
+	if pos+2 > len(datagram) {
+		return nil, ErrPacketTruncated
+	}
+	if datagram[pos] != npduVersion {
+		return nil, fmt.Errorf("%w: unexpected npdu version 0x%02x", ErrPacketDecode, datagram[pos])
+	}
+	control := datagram[pos+1]
+	pos += 2
+
+	for _, flag := range []uint8{npduDestination, npduSource} {
+		if control&flag == 0 {
+			continue
+		}
+		if pos+3 > len(datagram) {
+			return nil, ErrPacketTruncated
+		}
+		pos += 3 + int(datagram[pos+2])
+	}
+	if control&npduDestination != 0 {
+		pos++
+	}
+	if pos > len(datagram) {
+		return nil, ErrPacketTruncated
+	}
+	if control&npduNetworkMessage != 0 {
+		return nil, fmt.Errorf("%w: network layer message", ErrPacketDecode)
+	}
+
+	return datagram[pos:], nil
+}
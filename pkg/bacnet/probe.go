@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/udp"
+)
+
+// Property describes a device object property read by the prober.
+type Property struct {
+	ID   uint32
+	Name string
+}
+
+// DeviceProperties lists the device object properties read, in order.
+var DeviceProperties = []Property{
+	{PropertyObjectIdentifier, "object-identifier"},
+	{PropertyObjectName, "object-name"},
+	{PropertyVendorName, "vendor-name"},
+	{PropertyVendorIdentifier, "vendor-identifier"},
+	{PropertyModelName, "model-name"},
+	{PropertyFirmwareRevision, "firmware-revision"},
+	{PropertyApplicationSoftwareVersion, "application-software-version"},
+	{PropertyDescription, "description"},
+	{PropertyLocation, "location"},
+}
+
+// Report contains the information gathered from a BACnet/IP device.
+type Report struct {
+	// Instance is the instance number of the device object, identifying the device on the BACnet internetwork.
+	Instance *uint32 `json:"instance,omitempty"`
+
+	// VendorID is the vendor identifier assigned by ASHRAE.
+	VendorID *uint64 `json:"vendor_id,omitempty"`
+
+	// MaxAPDU is the largest APDU the device accepts, as announced by I-Am.
+	MaxAPDU *uint64 `json:"max_apdu,omitempty"`
+
+	// Segmentation is the segmentation the device supports, as announced by I-Am.
+	Segmentation string `json:"segmentation,omitempty"`
+
+	// WhoIsError contains the reason the Who-Is request went unanswered, if it did.
+	// Devices commonly broadcast their I-Am, which doesn't reach the prober.
+	WhoIsError string `json:"who_is_error,omitempty"`
+
+	// ObjectName, VendorName, ModelName, FirmwareRevision, ApplicationSoftwareVersion, Description and
+	// Location are the values of the device object properties.
+	ObjectName                 string `json:"object_name,omitempty"`
+	VendorName                 string `json:"vendor_name,omitempty"`
+	ModelName                  string `json:"model_name,omitempty"`
+	FirmwareRevision           string `json:"firmware_revision,omitempty"`
+	ApplicationSoftwareVersion string `json:"application_software_version,omitempty"`
+	Description                string `json:"description,omitempty"`
+	Location                   string `json:"location,omitempty"`
+
+	// PropertyErrors contains the reason each property that couldn't be read failed.
+	PropertyErrors map[string]string `json:"property_errors,omitempty"`
+}
+
+// Probe sends a Who-Is request, then reads the device object properties with ReadProperty requests, addressing
+// the device announced by I-Am, or the wildcard device instance. The delay is waited between each request,
+// to avoid overloading devices which are often unable to handle much traffic.
+func Probe(t *udp.Transport, delay time.Duration) (*Report, error) {
+	report := &Report{
+		PropertyErrors: map[string]string{},
+	}
+
+	// (1) Who-Is
+
+	var iam *IAm
+	_, err := t.Exchange(EncodePacket(EncodeWhoIs(), false), func(datagram []byte) bool {
+		apdu, err := DecodePacket(datagram)
+		if err != nil {
+			return false
+		}
+		iam, err = DecodeIAm(apdu)
+		return err == nil
+	})
+	if err != nil {
+		if errors.Is(err, udp.ErrPortUnreachable) {
+			return nil, err
+		}
+		report.WhoIsError = err.Error()
+	}
+
+	device := ObjectID{Type: ObjectDevice, Instance: WildcardInstance}
+	if iam != nil {
+		device = iam.Device
+		report.Instance = &iam.Device.Instance
+		report.VendorID = &iam.VendorID
+		report.MaxAPDU = &iam.MaxAPDU
+		report.Segmentation = SegmentationName(iam.Segmentation)
+	}
+
+	// (2) ReadProperty for each Device Property
+
+	answered := iam != nil
+	for i, property := range DeviceProperties {
+		if property.ID == PropertyObjectIdentifier && iam != nil {
+			continue
+		}
+
+		time.Sleep(delay)
+		values, err := readProperty(t, uint8(i+1), device, property.ID)
+		if err != nil {
+			if errors.Is(err, udp.ErrPortUnreachable) {
+				return nil, err
+			}
+
+			// Devices which don't speak BACnet ignore the request, don't wait for every other property in turn.
+			if !answered && errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, err
+			}
+
+			answered = answered || !errors.Is(err, os.ErrDeadlineExceeded)
+			report.PropertyErrors[property.Name] = err.Error()
+			continue
+		}
+		answered = true
+		if len(values) == 0 {
+			continue
+		}
+
+		switch property.ID {
+		case PropertyObjectIdentifier:
+			if id, ok := values[0].(ObjectID); ok {
+				report.Instance = &id.Instance
+			}
+		case PropertyVendorIdentifier:
+			if id, ok := values[0].(uint64); ok {
+				report.VendorID = &id
+			}
+		case PropertyObjectName:
+			report.ObjectName = fmt.Sprint(values[0])
+		case PropertyVendorName:
+			report.VendorName = fmt.Sprint(values[0])
+		case PropertyModelName:
+			report.ModelName = fmt.Sprint(values[0])
+		case PropertyFirmwareRevision:
+			report.FirmwareRevision = fmt.Sprint(values[0])
+		case PropertyApplicationSoftwareVersion:
+			report.ApplicationSoftwareVersion = fmt.Sprint(values[0])
+		case PropertyDescription:
+			report.Description = fmt.Sprint(values[0])
+		case PropertyLocation:
+			report.Location = fmt.Sprint(values[0])
+		}
+	}
+
+	return report, nil
+}
+
+// Sends a ReadProperty request for the property of the object, returning its values.
+func readProperty(t *udp.Transport, invokeID uint8, object ObjectID, property uint32) ([]interface{}, error) {
+	var apdu []byte
+	_, err := t.Exchange(EncodePacket(EncodeReadProperty(invokeID, object, property), true), func(datagram []byte) bool {
+		b, err := DecodePacket(datagram)
+		if err != nil {
+			return false
+		}
+		id, ok := InvokeID(b)
+		if !ok || id != invokeID {
+			return false
+		}
+		apdu = b
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeReadPropertyResponse(apdu)
+}
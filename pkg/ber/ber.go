@@ -0,0 +1,292 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ber provides a minimal ASN.1 Basic Encoding Rules (BER) encoder and decoder,
+// covering the subset of types used by protocols such as LDAP and SNMP.
+//
+// See https://www.itu.int/rec/T-REC-X.690
+package ber
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrDecode = fmt.Errorf("ber decode")
+var ErrTruncated = fmt.Errorf("%w: truncated element", ErrDecode)
+
+// Tag Classes
+const (
+	ClassUniversal   = 0x00
+	ClassApplication = 0x40
+	ClassContext     = 0x80
+	ClassPrivate     = 0xC0
+)
+
+// Universal Tags
+const (
+	TagBoolean     = 0x01
+	TagInteger     = 0x02
+	TagOctetString = 0x04
+	TagNull        = 0x05
+	TagOID         = 0x06
+	TagEnumerated  = 0x0A
+	TagSequence    = 0x10
+	TagSet         = 0x11
+)
+
+// Identifier Octet Masks
+const (
+	maskClass       = 0xC0
+	maskConstructed = 0x20
+	maskTag         = 0x1F
+)
+
+// Element is a single decoded BER element.
+type Element struct {
+	// Class is the tag class, one of the Class constants.
+	Class uint8
+
+	// Constructed indicates if the element contains further elements.
+	Constructed bool
+
+	// Tag is the tag number within its class.
+	Tag uint8
+
+	// Value contains the contents octets.
+	Value []byte
+}
+
+// Is determines if the element has the given class and tag number.
+func (e *Element) Is(class, tag uint8) bool {
+	return e.Class == class && e.Tag == tag
+}
+
+// Children decodes the contents of a constructed element.
+func (e *Element) Children() ([]*Element, error) {
+	if !e.Constructed {
+		return nil, fmt.Errorf("%w: element is not constructed", ErrDecode)
+	}
+
+	var children []*Element
+	rest := e.Value
+	for len(rest) > 0 {
+		child, next, err := Decode(rest)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		rest = next
+	}
+	return children, nil
+}
+
+// Int decodes the contents of an INTEGER or ENUMERATED element as a two's complement integer.
+func (e *Element) Int() (int64, error) {
+	if len(e.Value) == 0 || len(e.Value) > 8 {
+		return 0, fmt.Errorf("%w: invalid integer length %d", ErrDecode, len(e.Value))
+	}
+
+	v := int64(int8(e.Value[0]))
+	for _, b := range e.Value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// Uint decodes the contents of an element as an unsigned integer, as used by the SNMP application types
+// (Counter32, Gauge32, TimeTicks and Counter64). Agents commonly omit the leading zero octet these require.
+func (e *Element) Uint() (uint64, error) {
+	v := e.Value
+	if len(v) > 1 && v[0] == 0 {
+		v = v[1:]
+	}
+	if len(v) == 0 || len(v) > 8 {
+		return 0, fmt.Errorf("%w: invalid unsigned integer length %d", ErrDecode, len(e.Value))
+	}
+
+	var u uint64
+	for _, b := range v {
+		u = u<<8 | uint64(b)
+	}
+	return u, nil
+}
+
+// OID decodes the contents of an OBJECT IDENTIFIER element in dotted notation, for example "1.3.6.1.2.1.1.1.0".
+func (e *Element) OID() (string, error) {
+	if len(e.Value) == 0 {
+		return "", fmt.Errorf("%w: empty object identifier", ErrDecode)
+	}
+
+	// Each arc is encoded in base 128, the high bit set on every octet but the last.
+	// The first two arcs are combined into one, 40 * first + second.
+
+	var arcs []string
+	var arc uint64
+	for i, b := range e.Value {
+		if arc > math.MaxUint64>>7 {
+			return "", fmt.Errorf("%w: object identifier arc overflows", ErrDecode)
+		}
+		arc = arc<<7 | uint64(b&0x7F)
+		if b&0x80 != 0 {
+			if i == len(e.Value)-1 {
+				return "", ErrTruncated
+			}
+			continue
+		}
+
+		if arcs == nil {
+			first := arc / 40
+			if first > 2 {
+				first = 2
+			}
+			arcs = append(arcs, strconv.FormatUint(first, 10), strconv.FormatUint(arc-first*40, 10))
+		} else {
+			arcs = append(arcs, strconv.FormatUint(arc, 10))
+		}
+		arc = 0
+	}
+
+	return strings.Join(arcs, "."), nil
+}
+
+// Bool decodes the contents of a BOOLEAN element.
+func (e *Element) Bool() (bool, error) {
+	if len(e.Value) != 1 {
+		return false, fmt.Errorf("%w: invalid boolean length %d", ErrDecode, len(e.Value))
+	}
+	return e.Value[0] != 0, nil
+}
+
+// Decode decodes a single element from the start of the buffer, returning the element and the remaining bytes.
+func Decode(b []byte) (*Element, []byte, error) {
+	// Identifier Octet
+	//	2 Bits: Class
+	//	1 Bit: Constructed
+	//	5 Bits: Tag Number (high tag numbers are not supported)
+
+	if len(b) < 2 {
+		return nil, nil, ErrTruncated
+	}
+	if b[0]&maskTag == maskTag {
+		return nil, nil, fmt.Errorf("%w: high tag numbers are not supported", ErrDecode)
+	}
+
+	e := &Element{
+		Class:       b[0] & maskClass,
+		Constructed: b[0]&maskConstructed != 0,
+		Tag:         b[0] & maskTag,
+	}
+
+	// Length Octets
+
+	length, n, err := decodeLength(b[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	pos := 1 + n
+
+	// Contents Octets
+
+	if length > len(b)-pos {
+		return nil, nil, ErrTruncated
+	}
+	e.Value = b[pos : pos+length]
+
+	return e, b[pos+length:], nil
+}
+
+// Read reads a single element from the reader.
+// Elements with contents larger than maxLength are rejected before they are read.
+func Read(r io.Reader, maxLength int) (*Element, error) {
+	// Identifier Octet + First Length Octet
+
+	header := make([]byte, 2, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrTruncated, ErrDecode)
+	}
+
+	// Long Form Length Octets
+
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7F)
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("%w: unsupported length encoding", ErrDecode)
+		}
+		header = header[:2+n]
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return nil, wire.ReadError(err, ErrTruncated, ErrDecode)
+		}
+	}
+
+	length, _, err := decodeLength(header[1:])
+	if err != nil {
+		return nil, err
+	}
+	if length > maxLength {
+		return nil, fmt.Errorf("%w: element length %d exceeds maximum %d", ErrDecode, length, maxLength)
+	}
+
+	buf := make([]byte, len(header)+length)
+	copy(buf, header)
+	if _, err := io.ReadFull(r, buf[len(header):]); err != nil {
+		return nil, wire.ReadError(err, ErrTruncated, ErrDecode)
+	}
+
+	e, _, err := Decode(buf)
+	return e, err
+}
+
+// Decodes the length octets, returning the length and the number of octets used.
+// The indefinite form is not supported.
+func decodeLength(b []byte) (int, int, error) {
+	if len(b) < 1 {
+		return 0, 0, ErrTruncated
+	}
+
+	// Short Form: a single octet, the high bit unset.
+
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1, nil
+	}
+
+	// Long Form: the low bits give the number of following length octets.
+
+	n := int(b[0] & 0x7F)
+	if n == 0 {
+		return 0, 0, fmt.Errorf("%w: indefinite length is not supported", ErrDecode)
+	}
+	if n > 4 {
+		return 0, 0, fmt.Errorf("%w: unsupported length encoding", ErrDecode)
+	}
+	if len(b) < 1+n {
+		return 0, 0, ErrTruncated
+	}
+
+	length := 0
+	for _, o := range b[1 : 1+n] {
+		length = length<<8 | int(o)
+	}
+	if length < 0 {
+		return 0, 0, fmt.Errorf("%w: invalid length", ErrDecode)
+	}
+	return length, 1 + n, nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ber
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Encode encodes an element with the given identifier and contents, using definite length encoding.
+func Encode(class uint8, constructed bool, tag uint8, value []byte) []byte {
+	id := class | tag&maskTag
+	if constructed {
+		id |= maskConstructed
+	}
+
+	buf := append([]byte{id}, encodeLength(len(value))...)
+	return append(buf, value...)
+}
+
+// Sequence encodes a SEQUENCE of already encoded elements.
+func Sequence(elements ...[]byte) []byte {
+	return Encode(ClassUniversal, true, TagSequence, concat(elements))
+}
+
+// Set encodes a SET of already encoded elements.
+func Set(elements ...[]byte) []byte {
+	return Encode(ClassUniversal, true, TagSet, concat(elements))
+}
+
+// Integer encodes an INTEGER.
+func Integer(v int64) []byte {
+	return Encode(ClassUniversal, false, TagInteger, encodeInt(v))
+}
+
+// Enumerated encodes an ENUMERATED.
+func Enumerated(v int64) []byte {
+	return Encode(ClassUniversal, false, TagEnumerated, encodeInt(v))
+}
+
+// Boolean encodes a BOOLEAN.
+func Boolean(v bool) []byte {
+	if v {
+		return Encode(ClassUniversal, false, TagBoolean, []byte{0xFF})
+	}
+	return Encode(ClassUniversal, false, TagBoolean, []byte{0x00})
+}
+
+// OctetString encodes an OCTET STRING.
+func OctetString(s string) []byte {
+	return Encode(ClassUniversal, false, TagOctetString, []byte(s))
+}
+
+// Null encodes a NULL.
+func Null() []byte {
+	return Encode(ClassUniversal, false, TagNull, nil)
+}
+
+// ObjectIdentifier encodes an OBJECT IDENTIFIER given in dotted notation, for example "1.3.6.1.2.1.1.1.0".
+func ObjectIdentifier(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid object identifier %q", oid)
+	}
+
+	arcs := make([]uint64, len(parts))
+	for i, p := range parts {
+		arc, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object identifier %q", oid)
+		}
+		arcs[i] = arc
+	}
+	if arcs[0] > 2 || (arcs[0] < 2 && arcs[1] > 39) {
+		return nil, fmt.Errorf("invalid object identifier %q", oid)
+	}
+
+	// The first two arcs are combined into one, each arc is then encoded in base 128.
+
+	var value []byte
+	for _, arc := range append([]uint64{arcs[0]*40 + arcs[1]}, arcs[2:]...) {
+		octets := []byte{byte(arc & 0x7F)}
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			octets = append([]byte{byte(arc&0x7F) | 0x80}, octets...)
+		}
+		value = append(value, octets...)
+	}
+
+	return Encode(ClassUniversal, false, TagOID, value), nil
+}
+
+// MustObjectIdentifier encodes an OBJECT IDENTIFIER, panicking if it is invalid.
+// It is intended for well known identifiers defined as constants.
+func MustObjectIdentifier(oid string) []byte {
+	b, err := ObjectIdentifier(oid)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Encodes the length octets, using the short form where possible.
+func encodeLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+
+	var octets []byte
+	for l := length; l > 0; l >>= 8 {
+		octets = append([]byte{byte(l)}, octets...)
+	}
+	return append([]byte{0x80 | byte(len(octets))}, octets...)
+}
+
+// Encodes an integer as the minimum number of two's complement octets.
+func encodeInt(v int64) []byte {
+	n := 1
+	for i := v; i > 127 || i < -128; i >>= 8 {
+		n++
+	}
+
+	octets := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		octets[i] = byte(v)
+		v >>= 8
+	}
+	return octets
+}
+
+func concat(elements [][]byte) []byte {
+	var buf []byte
+	for _, e := range elements {
+		buf = append(buf, e...)
+	}
+	return buf
+}
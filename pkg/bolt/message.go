@@ -0,0 +1,209 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bolt provides facilities for probing and inspecting Neo4j servers over the Bolt protocol.
+package bolt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated message or connection is not bolt", ErrMessageDecode)
+
+// Magic preamble sent at the start of each connection.
+var magic = []byte{0x60, 0x60, 0xB0, 0x17}
+
+// Request Message Signatures
+const (
+	MessageHello   = 0x01
+	MessageGoodbye = 0x02
+	MessageLogon   = 0x6A
+)
+
+// Response Message Signatures
+const (
+	MessageSuccess = 0x70
+	MessageIgnored = 0x7E
+	MessageFailure = 0x7F
+)
+
+// Maximum size of a response message accepted.
+const maxMessageSize = 1 << 20
+
+// Version is a Bolt protocol version.
+type Version struct {
+	Major uint8
+	Minor uint8
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Determines if the version is at least the given version.
+func (v Version) atLeast(major, minor uint8) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// Proposal is a version proposed in the handshake, accepting any minor version from Version.Minor down
+// to Version.Minor - Range (supported by servers since Bolt 4.3).
+type Proposal struct {
+	Version
+	Range uint8
+}
+
+// Proposals lists the four versions proposed in the handshake, most preferred first.
+var Proposals = []Proposal{
+	{Version: Version{Major: 5, Minor: 4}, Range: 4},
+	{Version: Version{Major: 4, Minor: 4}, Range: 2},
+	{Version: Version{Major: 4, Minor: 1}},
+	{Version: Version{Major: 3, Minor: 0}},
+}
+
+// EncodeHandshake encodes the magic preamble followed by the four version proposals.
+func EncodeHandshake(proposals []Proposal) []byte {
+	// Handshake
+	//	4 Bytes: Magic Preamble
+	//	4 x 4 Bytes: Version Proposals
+	//		1 Byte: Reserved
+	//		1 Byte: Range
+	//		1 Byte: Minor Version
+	//		1 Byte: Major Version
+
+	buf := append([]byte(nil), magic...)
+	for i := 0; i < 4; i++ {
+		if i < len(proposals) {
+			p := proposals[i]
+			buf = append(buf, 0, p.Range, p.Minor, p.Major)
+		} else {
+			buf = append(buf, 0, 0, 0, 0)
+		}
+	}
+	return buf
+}
+
+// ReadVersion reads the version the server agreed to in response to the handshake.
+// The zero Version is returned if the server supports none of the proposals.
+func ReadVersion(r io.Reader) (Version, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Version{}, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+	}
+	if buf[0] == 'H' && buf[1] == 'T' && buf[2] == 'T' && buf[3] == 'P' {
+		return Version{}, fmt.Errorf("%w: server responded over http, connection is not bolt", ErrMessageDecode)
+	}
+	if buf[0] != 0 || buf[1] != 0 {
+		return Version{}, fmt.Errorf("%w: unexpected version response %x", ErrMessageDecode, buf)
+	}
+	return Version{Major: buf[3], Minor: buf[2]}, nil
+}
+
+// WriteMessage writes a message, split into chunks and terminated by an empty chunk.
+func WriteMessage(w io.Writer, msg []byte) error {
+	// Chunk
+	//	2 Bytes: Chunk Size
+	//	n Bytes: Chunk Data
+
+	var buf []byte
+	for len(msg) > 0 {
+		n := len(msg)
+		if n > 0xFFFF {
+			n = 0xFFFF
+		}
+		buf = append(buf, byte(n>>8), byte(n))
+		buf = append(buf, msg[:n]...)
+		msg = msg[n:]
+	}
+	buf = append(buf, 0, 0)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadMessage reads a chunked message and decodes it as a structure.
+func ReadMessage(r io.Reader) (*Structure, error) {
+	var msg []byte
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+		}
+		size := int(binary.BigEndian.Uint16(header))
+		if size == 0 {
+			if len(msg) == 0 {
+				// Empty chunks between messages are NOOPs, sent as keep-alives.
+				continue
+			}
+			break
+		}
+		if len(msg)+size > maxMessageSize {
+			return nil, fmt.Errorf("%w: message exceeds maximum size", ErrMessageDecode)
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+		}
+		msg = append(msg, chunk...)
+	}
+
+	v, _, err := DecodeValue(msg)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(*Structure)
+	if !ok {
+		return nil, fmt.Errorf("%w: message is not a structure", ErrMessageDecode)
+	}
+	return s, nil
+}
+
+// Metadata returns the metadata map of a SUCCESS or FAILURE message, the message's only field.
+func (s *Structure) Metadata() map[string]interface{} {
+	if len(s.Fields) > 0 {
+		if m, ok := s.Fields[0].(map[string]interface{}); ok {
+			return m
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// Failure is a FAILURE response.
+type Failure struct {
+	// Code is the Neo4j status code, for example "Neo.ClientError.Security.Unauthorized".
+	Code string `json:"code"`
+
+	// Message describes the failure.
+	Message string `json:"message"`
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("bolt failure %s: %s", f.Code, f.Message)
+}
+
+// NewFailure creates a Failure from a FAILURE message's metadata.
+func NewFailure(s *Structure) *Failure {
+	m := s.Metadata()
+	f := &Failure{}
+	f.Code, _ = m["code"].(string)
+	f.Message, _ = m["message"].(string)
+	return f
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrPackStreamDecode = fmt.Errorf("packstream decode")
+var ErrPackStreamTruncated = fmt.Errorf("%w: truncated value", ErrPackStreamDecode)
+
+// Maximum nesting depth of lists, maps and structures decoded.
+const maxDepth = 16
+
+// Structure is a PackStream structure, a tagged list of fields. Bolt messages are structures.
+type Structure struct {
+	Signature byte
+	Fields    []interface{}
+}
+
+// EncodeStructure encodes a structure with the given signature and fields.
+// Only the types needed for requests are supported: strings, maps of them, and nil.
+func EncodeStructure(signature byte, fields ...interface{}) []byte {
+	// Structure Marker
+	//	1 Byte: 0xB0 + Number of Fields
+	//	1 Byte: Signature
+
+	buf := []byte{0xB0 + byte(len(fields)), signature}
+	for _, f := range fields {
+		buf = appendValue(buf, f)
+	}
+	return buf
+}
+
+// Appends a PackStream encoded value.
+func appendValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xC0)
+	case string:
+		return appendString(buf, v)
+	case map[string]interface{}:
+		buf = appendHeader(buf, 0xA0, 0xD8, len(v))
+		// Keys are sorted so requests are encoded deterministically.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf = appendString(buf, k)
+			buf = appendValue(buf, v[k])
+		}
+		return buf
+	default:
+		panic(fmt.Sprintf("packstream: unsupported type %T", v))
+	}
+}
+
+// Appends a PackStream encoded string.
+func appendString(buf []byte, s string) []byte {
+	buf = appendHeader(buf, 0x80, 0xD0, len(s))
+	return append(buf, s...)
+}
+
+// Appends the marker of a string, list or map with the given size: the tiny marker for sizes below 16,
+// otherwise the 8, 16 or 32-bit marker followed by the size.
+func appendHeader(buf []byte, tiny byte, marker byte, size int) []byte {
+	switch {
+	case size < 16:
+		return append(buf, tiny+byte(size))
+	case size <= math.MaxUint8:
+		return append(buf, marker, byte(size))
+	case size <= math.MaxUint16:
+		buf = append(buf, marker+1, 0, 0)
+		binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(size))
+		return buf
+	default:
+		buf = append(buf, marker+2, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(size))
+		return buf
+	}
+}
+
+// DecodeValue decodes a single PackStream value, returning it and the remaining bytes.
+// Values are decoded as nil, bool, int64, float64, string, []byte, []interface{}, map[string]interface{}
+// or *Structure.
+//
+// See https://neo4j.com/docs/bolt/current/packstream/
+func DecodeValue(b []byte) (interface{}, []byte, error) {
+	return decodeValue(b, 0)
+}
+
+func decodeValue(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, fmt.Errorf("%w: nesting too deep", ErrPackStreamDecode)
+	}
+	if len(b) < 1 {
+		return nil, nil, ErrPackStreamTruncated
+	}
+	marker, b := b[0], b[1:]
+
+	switch {
+	// Tiny Int (-16 to 127)
+	case marker < 0x80:
+		return int64(marker), b, nil
+	case marker >= 0xF0:
+		return int64(int8(marker)), b, nil
+
+	// Tiny String, List, Map and Structure
+	case marker < 0x90:
+		return decodeString(b, int(marker&0x0F))
+	case marker < 0xA0:
+		return decodeList(b, int(marker&0x0F), depth)
+	case marker < 0xB0:
+		return decodeMap(b, int(marker&0x0F), depth)
+	case marker < 0xC0:
+		return decodeStructure(b, int(marker&0x0F), depth)
+	}
+
+	switch marker {
+	case 0xC0:
+		return nil, b, nil
+	case 0xC1:
+		if len(b) < 8 {
+			return nil, nil, ErrPackStreamTruncated
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 0xC2:
+		return false, b, nil
+	case 0xC3:
+		return true, b, nil
+
+	case 0xC8, 0xC9, 0xCA, 0xCB:
+		size := 1 << (marker - 0xC8)
+		if len(b) < size {
+			return nil, nil, ErrPackStreamTruncated
+		}
+		var v int64
+		switch size {
+		case 1:
+			v = int64(int8(b[0]))
+		case 2:
+			v = int64(int16(binary.BigEndian.Uint16(b)))
+		case 4:
+			v = int64(int32(binary.BigEndian.Uint32(b)))
+		case 8:
+			v = int64(binary.BigEndian.Uint64(b))
+		}
+		return v, b[size:], nil
+
+	case 0xCC, 0xCD, 0xCE:
+		n, b, err := decodeSize(b, marker-0xCC)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(b) < n {
+			return nil, nil, ErrPackStreamTruncated
+		}
+		return append([]byte(nil), b[:n]...), b[n:], nil
+
+	case 0xD0, 0xD1, 0xD2:
+		n, b, err := decodeSize(b, marker-0xD0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeString(b, n)
+
+	case 0xD4, 0xD5, 0xD6:
+		n, b, err := decodeSize(b, marker-0xD4)
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeList(b, n, depth)
+
+	case 0xD8, 0xD9, 0xDA:
+		n, b, err := decodeSize(b, marker-0xD8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeMap(b, n, depth)
+
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown marker 0x%02x", ErrPackStreamDecode, marker)
+	}
+}
+
+// Decodes the 8, 16 or 32-bit size following a marker, selected by the marker's offset (0, 1 or 2).
+func decodeSize(b []byte, offset byte) (int, []byte, error) {
+	size := 1 << offset
+	if len(b) < size {
+		return 0, nil, ErrPackStreamTruncated
+	}
+	switch size {
+	case 1:
+		return int(b[0]), b[1:], nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), b[2:], nil
+	default:
+		return int(binary.BigEndian.Uint32(b)), b[4:], nil
+	}
+}
+
+func decodeString(b []byte, n int) (interface{}, []byte, error) {
+	if n < 0 || len(b) < n {
+		return nil, nil, ErrPackStreamTruncated
+	}
+	return string(b[:n]), b[n:], nil
+}
+
+func decodeList(b []byte, n int, depth int) (interface{}, []byte, error) {
+	// Every item is at least one byte, which bounds the allocation.
+	if n < 0 || len(b) < n {
+		return nil, nil, ErrPackStreamTruncated
+	}
+	list := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		var v interface{}
+		var err error
+		if v, b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		list = append(list, v)
+	}
+	return list, b, nil
+}
+
+func decodeMap(b []byte, n int, depth int) (interface{}, []byte, error) {
+	if n < 0 || len(b) < n*2 {
+		return nil, nil, ErrPackStreamTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		var k, v interface{}
+		var err error
+		if k, b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: map key is not a string", ErrPackStreamDecode)
+		}
+		if v, b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		m[key] = v
+	}
+	return m, b, nil
+}
+
+func decodeStructure(b []byte, n int, depth int) (interface{}, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, ErrPackStreamTruncated
+	}
+	s := &Structure{Signature: b[0]}
+	fields, b, err := decodeList(b[1:], n, depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Fields = fields.([]interface{})
+	return s, b, nil
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// User agent sent in the HELLO message.
+const userAgent = "protoscan"
+
+// Report contains the information gathered from a Bolt server.
+type Report struct {
+	// Version is the protocol version agreed in the handshake, for example "4.4".
+	// It is empty if the server supports none of the proposed versions.
+	Version string `json:"version"`
+
+	// Agent is the server agent string, for example "Neo4j/4.4.12".
+	Agent string `json:"agent,omitempty"`
+
+	// ConnectionID is the server assigned ID of the connection, for example "bolt-12".
+	ConnectionID string `json:"connection_id,omitempty"`
+
+	// AuthRequired indicates if the server refused to authenticate without credentials.
+	AuthRequired bool `json:"auth_required"`
+
+	// Failure contains the FAILURE response to the unauthenticated HELLO (or LOGON), if the server refused it.
+	Failure *Failure `json:"failure,omitempty"`
+}
+
+// Probe performs the handshake, then sends a HELLO without credentials to identify the server and determine
+// whether it requires authentication. Since Bolt 5.1 authentication is a separate LOGON message, sent after the
+// HELLO succeeds.
+func Probe(rw io.ReadWriter) (*Report, error) {
+	// (1) Handshake
+
+	if _, err := rw.Write(EncodeHandshake(Proposals)); err != nil {
+		return nil, err
+	}
+	version, err := ReadVersion(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	if version == (Version{}) {
+		return report, nil
+	}
+	report.Version = version.String()
+
+	// (2) HELLO
+	//	Up to Bolt 5.0 the HELLO carries the authentication scheme, "none" to authenticate without credentials.
+	//	Since Bolt 5.3 the client must also identify itself with a bolt_agent.
+
+	extra := map[string]interface{}{
+		"user_agent": userAgent,
+	}
+	if !version.atLeast(5, 1) {
+		extra["scheme"] = "none"
+	}
+	if version.atLeast(5, 3) {
+		extra["bolt_agent"] = map[string]interface{}{"product": userAgent}
+	}
+
+	resp, err := request(rw, EncodeStructure(MessageHello, extra))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Signature == MessageFailure {
+		return refused(report, resp)
+	}
+
+	metadata := resp.Metadata()
+	report.Agent, _ = metadata["server"].(string)
+	report.ConnectionID, _ = metadata["connection_id"].(string)
+
+	// (3) LOGON (Bolt 5.1 and later)
+
+	if version.atLeast(5, 1) {
+		resp, err := request(rw, EncodeStructure(MessageLogon, map[string]interface{}{"scheme": "none"}))
+		if err != nil {
+			return nil, err
+		}
+		if resp.Signature == MessageFailure {
+			return refused(report, resp)
+		}
+	}
+
+	// Best effort end of the session.
+	_ = WriteMessage(rw, EncodeStructure(MessageGoodbye))
+
+	return report, nil
+}
+
+// Sends a request message and reads the SUCCESS or FAILURE response.
+func request(rw io.ReadWriter, msg []byte) (*Structure, error) {
+	if err := WriteMessage(rw, msg); err != nil {
+		return nil, err
+	}
+	resp, err := ReadMessage(rw)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Signature != MessageSuccess && resp.Signature != MessageFailure {
+		return nil, fmt.Errorf("%w: unexpected response message 0x%02x", ErrMessageDecode, resp.Signature)
+	}
+	return resp, nil
+}
+
+// Records a FAILURE response to the unauthenticated HELLO or LOGON.
+func refused(report *Report, resp *Structure) (*Report, error) {
+	report.Failure = NewFailure(resp)
+	report.AuthRequired = strings.HasPrefix(report.Failure.Code, "Neo.ClientError.Security.")
+	return report, nil
+}
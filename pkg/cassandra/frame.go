@@ -0,0 +1,137 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cassandra provides facilities for decoding and inspecting Cassandra CQL native protocol information.
+package cassandra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrFrameDecode = fmt.Errorf("frame decode")
+var ErrFrameTruncated = fmt.Errorf("%w: truncated frame or connection is not cql", ErrFrameDecode)
+
+// Opcode identifies the kind of message carried by a frame.
+type Opcode uint8
+
+// Opcodes
+const (
+	OpcodeError         Opcode = 0x00
+	OpcodeStartup       Opcode = 0x01
+	OpcodeReady         Opcode = 0x02
+	OpcodeAuthenticate  Opcode = 0x03
+	OpcodeOptions       Opcode = 0x05
+	OpcodeSupported     Opcode = 0x06
+	OpcodeAuthChallenge Opcode = 0x0E
+	OpcodeAuthSuccess   Opcode = 0x10
+)
+
+// Header Flags
+const (
+	flagBeta = 0x10
+)
+
+// Direction bit of the version byte, set on responses.
+const versionResponse = 0x80
+
+// Size of the frame header for protocol versions 3 and later.
+const headerSize = 9
+
+// Maximum frame body length accepted, as defined by the protocol (256MB).
+const maxBodyLength = 256 << 20
+
+// Frame represents a CQL native protocol frame (v3 and later header format).
+//
+// See https://github.com/apache/cassandra/blob/trunk/doc/native_protocol_v4.spec
+type Frame struct {
+	// Version is the protocol version, without the direction bit.
+	Version uint8
+
+	// Response indicates if the frame was sent by the server.
+	Response bool
+
+	// Flags contains the frame flags.
+	Flags uint8
+
+	// Stream is the stream ID used to match requests and responses.
+	Stream int16
+
+	// Opcode identifies the frame's message.
+	Opcode Opcode
+
+	// Body of the frame.
+	Body []byte
+}
+
+// Encode encodes the frame, ready to be written to a connection.
+func (f *Frame) Encode() []byte {
+	// Frame Format
+	//	1 Byte: Version (High bit set on responses)
+	//	1 Byte: Flags
+	//	2 Bytes: Stream (Big Endian)
+	//	1 Byte: Opcode
+	//	4 Bytes: Body Length (Big Endian)
+	//	BODY
+
+	version := f.Version
+	if f.Response {
+		version |= versionResponse
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(f.Body))
+	buf[0] = version
+	buf[1] = f.Flags
+	binary.BigEndian.PutUint16(buf[2:], uint16(f.Stream))
+	buf[4] = byte(f.Opcode)
+	binary.BigEndian.PutUint32(buf[5:], uint32(len(f.Body)))
+
+	return append(buf, f.Body...)
+}
+
+// ReadFrame attempts to read a CQL frame from the given reader.
+func ReadFrame(r io.Reader) (*Frame, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	if header[0]&versionResponse == 0 {
+		return nil, fmt.Errorf("%w: not a response frame, connection is not cql", ErrFrameDecode)
+	}
+
+	length := binary.BigEndian.Uint32(header[5:])
+	if length > maxBodyLength {
+		return nil, fmt.Errorf("%w: frame body too large, connection is not cql", ErrFrameDecode)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	return &Frame{
+		Version:  header[0] &^ versionResponse,
+		Response: true,
+		Flags:    header[1],
+		Stream:   int16(binary.BigEndian.Uint16(header[2:])),
+		Opcode:   Opcode(header[4]),
+		Body:     body,
+	}, nil
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated body or not a cql message", ErrMessageDecode)
+
+// Error represents the body of an ERROR message.
+type Error struct {
+	// Code is the error code, for example 0x000A for a protocol error.
+	Code int32 `json:"code"`
+
+	// Message is the error message.
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cql error 0x%04x: %s", e.Code, e.Message)
+}
+
+// EncodeOptions encodes an OPTIONS request for the given protocol version.
+// The beta flag must be set to use a protocol version the server only supports as a beta, such as v5 on
+// Cassandra 3.x and 4.0.
+func EncodeOptions(version uint8, beta bool) *Frame {
+	f := &Frame{
+		Version: version,
+		Opcode:  OpcodeOptions,
+	}
+	if beta {
+		f.Flags |= flagBeta
+	}
+	return f
+}
+
+// EncodeStartup encodes a STARTUP request for the given protocol and CQL versions.
+func EncodeStartup(version uint8, cqlVersion string) *Frame {
+	body := encodeStringMap(map[string]string{
+		"CQL_VERSION": cqlVersion,
+	})
+
+	return &Frame{
+		Version: version,
+		Opcode:  OpcodeStartup,
+		Body:    body,
+	}
+}
+
+// DecodeSupported attempts to decode the body of a SUPPORTED message, a [string multimap].
+func DecodeSupported(body []byte) (map[string][]string, error) {
+	// 2 Bytes: Number of Entries
+
+	n, pos, err := readShort(body, 0)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+
+	options := make(map[string][]string, n)
+	for i := 0; i < int(n); i++ {
+		// [string]: Key
+
+		var key string
+		key, pos, err = readString(body, pos)
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+
+		// [string list]: Values
+
+		var count uint16
+		count, pos, err = readShort(body, pos)
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+
+		values := make([]string, 0, count)
+		for j := 0; j < int(count); j++ {
+			var v string
+			v, pos, err = readString(body, pos)
+			if err != nil {
+				return nil, ErrMessageTruncated
+			}
+			values = append(values, v)
+		}
+
+		options[key] = values
+	}
+
+	return options, nil
+}
+
+// DecodeAuthenticate attempts to decode the body of an AUTHENTICATE message, returning the authenticator class.
+func DecodeAuthenticate(body []byte) (string, error) {
+	class, _, err := readString(body, 0)
+	if err != nil {
+		return "", ErrMessageTruncated
+	}
+	return class, nil
+}
+
+// DecodeError attempts to decode the body of an ERROR message.
+func DecodeError(body []byte) (*Error, error) {
+	if len(body) < 4 {
+		return nil, ErrMessageTruncated
+	}
+
+	msg, _, err := readString(body, 4)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+
+	return &Error{
+		Code:    int32(binary.BigEndian.Uint32(body)),
+		Message: msg,
+	}, nil
+}
+
+// Encodes a [string map]: a [short] n, followed by n key/value [string] pairs.
+func encodeStringMap(m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, uint16(len(m)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = appendString(buf, m[k])
+	}
+	return buf
+}
+
+// Appends a [string]: a [short] length, followed by the string bytes.
+func appendString(buf []byte, s string) []byte {
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(s)))
+	return append(append(buf, l...), s...)
+}
+
+// Reads a [short] at the given position, returning the new cursor position.
+func readShort(b []byte, pos int) (uint16, int, error) {
+	sub, pos, err := wire.ReadBuffer(b, pos, 2)
+	if err != nil {
+		return 0, 0, ErrMessageTruncated
+	}
+	return binary.BigEndian.Uint16(sub), pos, nil
+}
+
+// Reads a [string] at the given position, returning the new cursor position.
+func readString(b []byte, pos int) (string, int, error) {
+	l, pos, err := readShort(b, pos)
+	if err != nil {
+		return "", 0, err
+	}
+	sub, pos, err := wire.ReadBuffer(b, pos, int(l))
+	if err != nil {
+		return "", 0, ErrMessageTruncated
+	}
+	return string(sub), pos, nil
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandra
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// Protocol versions attempted, from highest to lowest.
+const (
+	maxVersion = 5
+	minVersion = 3
+)
+
+// Protocol version offered again with the beta flag when refused, older servers only support it as a beta.
+const betaVersion = 5
+
+// Default CQL version sent in the STARTUP message if the server doesn't advertise any.
+const defaultCQLVersion = "3.0.0"
+
+// Report contains the information gathered from a Cassandra native protocol negotiation.
+type Report struct {
+	// ProtocolVersion is the highest stable protocol version the server accepted.
+	ProtocolVersion int `json:"protocol_version"`
+
+	// BetaProtocolVersion is the protocol version the server only accepted as a beta, if any.
+	BetaProtocolVersion int `json:"beta_protocol_version,omitempty"`
+
+	// Attempts lists every protocol version attempted and the outcome.
+	Attempts []Attempt `json:"attempts"`
+
+	// CQLVersions lists the supported CQL versions (CQL_VERSION).
+	CQLVersions []string `json:"cql_versions"`
+
+	// Compression lists the supported compression algorithms (COMPRESSION).
+	Compression []string `json:"compression"`
+
+	// ProtocolVersions lists the supported protocol versions (PROTOCOL_VERSIONS), only sent by newer servers.
+	ProtocolVersions []string `json:"protocol_versions,omitempty"`
+
+	// Supported contains every option from the SUPPORTED response.
+	Supported map[string][]string `json:"supported"`
+
+	// AuthRequired indicates if the server requires authentication after STARTUP.
+	AuthRequired bool `json:"auth_required"`
+
+	// Authenticator is the authenticator class required by the server, for example
+	// "org.apache.cassandra.auth.PasswordAuthenticator".
+	Authenticator string `json:"authenticator,omitempty"`
+}
+
+// Attempt is the outcome of offering a single protocol version.
+type Attempt struct {
+	// Version is the protocol version offered.
+	Version int `json:"version"`
+
+	// Beta indicates if the version was offered with the beta flag set.
+	Beta bool `json:"beta,omitempty"`
+
+	// Accepted indicates if the server accepted the version.
+	Accepted bool `json:"accepted"`
+
+	// Error is the server's error message if the version was refused, or the reason the exchange failed if the
+	// server closed the connection instead.
+	Error string `json:"error,omitempty"`
+}
+
+// Probe negotiates the highest stable protocol version supported by the server, from v5 down to v3, and then
+// sends a STARTUP message to determine if authentication is required. A refused v5 is offered again as a beta,
+// which is reported separately, before carrying on with v4.
+// Servers close the connection after refusing a protocol version, so a new connection is made for each attempt.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{}
+
+	// (1) Negotiate the Protocol Version
+
+	var offers []Attempt
+	for version := maxVersion; version >= minVersion; version-- {
+		offers = append(offers, Attempt{Version: version})
+		if version == betaVersion {
+			offers = append(offers, Attempt{Version: version, Beta: true})
+		}
+	}
+
+	var conn net.Conn
+	var supported *Frame
+
+	// Servers may close the connection instead of refusing a version with an ERROR, so only fail once no offer
+	// got a CQL response at all.
+	var exchangeErr error
+	responded := false
+
+	for i := 0; i < len(offers) && supported == nil; i++ {
+		attempt := offers[i]
+
+		c, err := dial()
+		if err != nil {
+			return nil, err
+		}
+
+		frame, err := exchange(c, EncodeOptions(uint8(attempt.Version), attempt.Beta))
+		if err != nil {
+			_ = c.Close()
+			exchangeErr = err
+			attempt.Error = err.Error()
+			report.Attempts = append(report.Attempts, attempt)
+			continue
+		}
+		responded = true
+
+		switch {
+		case frame.Opcode == OpcodeSupported && attempt.Beta:
+			// The beta is only reported, negotiation carries on for a stable version.
+			_ = c.Close()
+			attempt.Accepted = true
+			report.BetaProtocolVersion = attempt.Version
+		case frame.Opcode == OpcodeSupported:
+			attempt.Accepted = true
+			conn = c
+			supported = frame
+		case frame.Opcode == OpcodeError:
+			_ = c.Close()
+			cqlErr, err := DecodeError(frame.Body)
+			if err != nil {
+				return nil, err
+			}
+			attempt.Error = cqlErr.Message
+		default:
+			_ = c.Close()
+			return nil, fmt.Errorf("%w: unexpected opcode 0x%02x", ErrMessageDecode, uint8(frame.Opcode))
+		}
+
+		report.Attempts = append(report.Attempts, attempt)
+	}
+
+	if !responded {
+		return nil, exchangeErr
+	}
+	if supported == nil {
+		return nil, fmt.Errorf("%w: no supported protocol version between v%d and v%d", ErrMessageDecode, minVersion, maxVersion)
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// (2) Decode the SUPPORTED Options
+
+	options, err := DecodeSupported(supported.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ProtocolVersion = int(supported.Version)
+	report.Supported = options
+	report.CQLVersions = options["CQL_VERSION"]
+	report.Compression = options["COMPRESSION"]
+	report.ProtocolVersions = options["PROTOCOL_VERSIONS"]
+
+	// (3) Send STARTUP to Detect Authentication
+
+	cqlVersion := defaultCQLVersion
+	if len(report.CQLVersions) > 0 {
+		cqlVersion = report.CQLVersions[0]
+	}
+
+	frame, err := exchange(conn, EncodeStartup(supported.Version, cqlVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	switch frame.Opcode {
+	case OpcodeReady:
+		report.AuthRequired = false
+	case OpcodeAuthenticate:
+		report.AuthRequired = true
+		report.Authenticator, err = DecodeAuthenticate(frame.Body)
+		if err != nil {
+			return nil, err
+		}
+	case OpcodeError:
+		cqlErr, err := DecodeError(frame.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: startup refused: %v", ErrMessageDecode, cqlErr)
+	default:
+		return nil, fmt.Errorf("%w: unexpected opcode 0x%02x", ErrMessageDecode, uint8(frame.Opcode))
+	}
+
+	return report, nil
+}
+
+// Writes the request frame and reads the response frame.
+func exchange(rw io.ReadWriter, request *Frame) (*Frame, error) {
+	_, err := rw.Write(request.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return ReadFrame(rw)
+}
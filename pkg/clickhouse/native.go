@@ -0,0 +1,249 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package clickhouse provides facilities for probing and inspecting ClickHouse servers over the native and HTTP
+// interfaces.
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or connection is not clickhouse", ErrPacketDecode)
+
+// Client Packet Types
+const (
+	clientHello = 0
+)
+
+// Server Packet Types
+const (
+	ServerHello     = 0
+	ServerException = 2
+)
+
+// Client name and version sent in the Hello packet.
+const (
+	clientName         = "protoscan"
+	clientVersionMajor = 20
+	clientVersionMinor = 3
+)
+
+// Protocol revisions adding fields to the server Hello packet.
+const (
+	revisionWithServerTimezone    = 54058
+	revisionWithServerDisplayName = 54372
+	revisionWithVersionPatch      = 54401
+)
+
+// Protocol revision sent in the Hello packet. Later revisions expect further packets from the client
+// after the Hello, so this is the highest revision which fits a single Hello exchange.
+const clientRevision = revisionWithVersionPatch
+
+// Maximum length of a string accepted in a server packet.
+const maxStringLength = 64 * 1024
+
+// Hello is the server's Hello packet, sent once the client is authenticated.
+//
+// See https://github.com/ClickHouse/ClickHouse/blob/master/src/Server/TCPHandler.cpp (sendHello)
+type Hello struct {
+	Name         string
+	VersionMajor uint64
+	VersionMinor uint64
+	VersionPatch uint64
+	Revision     uint64
+	Timezone     string
+	DisplayName  string
+}
+
+// Exception is an exception sent by the server, for example when authentication fails.
+type Exception struct {
+	// Code is the error code, for example 516 (AUTHENTICATION_FAILED).
+	Code int32 `json:"code"`
+
+	// Name is the exception class name, for example "DB::Exception".
+	Name string `json:"name"`
+
+	// Message is the exception message.
+	Message string `json:"message"`
+}
+
+func (e *Exception) Error() string {
+	return fmt.Sprintf("clickhouse exception %d: %s", e.Code, e.Message)
+}
+
+// EncodeHello encodes a client Hello packet for the given database, user and password.
+func EncodeHello(database, user, password string) []byte {
+	// Client Hello
+	//	VarUInt: Packet Type
+	//	String: Client Name
+	//	VarUInt: Client Version Major
+	//	VarUInt: Client Version Minor
+	//	VarUInt: Protocol Revision
+	//	String: Default Database
+	//	String: User
+	//	String: Password
+
+	var buf []byte
+	buf = appendUvarint(buf, clientHello)
+	buf = appendString(buf, clientName)
+	buf = appendUvarint(buf, clientVersionMajor)
+	buf = appendUvarint(buf, clientVersionMinor)
+	buf = appendUvarint(buf, clientRevision)
+	buf = appendString(buf, database)
+	buf = appendString(buf, user)
+	buf = appendString(buf, password)
+	return buf
+}
+
+// ReadHello reads the server's response to a client Hello: either a server Hello, or an Exception which is
+// returned as the error.
+func ReadHello(r io.Reader) (*Hello, error) {
+	br := bufio.NewReader(r)
+
+	packetType, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+
+	switch packetType {
+	case ServerHello:
+		return readHello(br)
+	case ServerException:
+		e, err := readException(br)
+		if err != nil {
+			return nil, err
+		}
+		return nil, e
+	default:
+		return nil, fmt.Errorf("%w: unexpected packet type %d", ErrPacketDecode, packetType)
+	}
+}
+
+// Reads the fields of a server Hello packet.
+func readHello(r *bufio.Reader) (*Hello, error) {
+	// Server Hello
+	//	String: Server Name
+	//	VarUInt: Version Major
+	//	VarUInt: Version Minor
+	//	VarUInt: Protocol Revision
+	//	String: Timezone (revision >= 54058)
+	//	String: Display Name (revision >= 54372)
+	//	VarUInt: Version Patch (revision >= 54401)
+
+	h := &Hello{}
+	var err error
+
+	if h.Name, err = readString(r); err != nil {
+		return nil, err
+	}
+	for _, v := range []*uint64{&h.VersionMajor, &h.VersionMinor, &h.Revision} {
+		if *v, err = binary.ReadUvarint(r); err != nil {
+			return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+		}
+	}
+
+	// The server encodes the remaining fields according to the lower of its own and the client's revision.
+	revision := h.Revision
+	if revision > clientRevision {
+		revision = clientRevision
+	}
+
+	if revision >= revisionWithServerTimezone {
+		if h.Timezone, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	if revision >= revisionWithServerDisplayName {
+		if h.DisplayName, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	if revision >= revisionWithVersionPatch {
+		if h.VersionPatch, err = binary.ReadUvarint(r); err != nil {
+			return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+		}
+	} else {
+		// Older servers don't send the patch version, the revision is used in its place (as clickhouse-client does).
+		h.VersionPatch = h.Revision
+	}
+
+	return h, nil
+}
+
+// Reads the fields of an Exception packet. Nested exceptions are not read.
+func readException(r *bufio.Reader) (*Exception, error) {
+	// Exception
+	//	4 Bytes: Code (little endian)
+	//	String: Name
+	//	String: Message
+	//	String: Stack Trace
+	//	1 Byte: Has Nested
+
+	code := make([]byte, 4)
+	if _, err := io.ReadFull(r, code); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+
+	e := &Exception{
+		Code: int32(binary.LittleEndian.Uint32(code)),
+	}
+	var err error
+	if e.Name, err = readString(r); err != nil {
+		return nil, err
+	}
+	if e.Message, err = readString(r); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Reads a string, prefixed with its VarUInt length.
+func readString(r *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	if length > maxStringLength {
+		return "", fmt.Errorf("%w: string length %d exceeds maximum", ErrPacketDecode, length)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	return string(buf), nil
+}
+
+// Appends a VarUInt.
+func appendUvarint(buf []byte, v uint64) []byte {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(tmp, v)
+	return append(buf, tmp[:n]...)
+}
+
+// Appends a string, prefixed with its VarUInt length.
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+var ErrHTTPResponse = fmt.Errorf("http response")
+
+// Credentials sent to the native interface: the default user with an empty password, which is how ClickHouse
+// ships out of the box.
+const (
+	defaultDatabase = "default"
+	defaultUser     = "default"
+)
+
+// Query sent to the HTTP interface.
+const versionQuery = "SELECT version()"
+
+// Report contains the information gathered from a ClickHouse server.
+type Report struct {
+	// Server contains the server's Hello, if the native handshake succeeded.
+	Server *Server `json:"server,omitempty"`
+
+	// DefaultUser indicates if the native interface accepted the default user without a password.
+	DefaultUser bool `json:"default_user"`
+
+	// Exception contains the exception the native interface responded with, typically authentication failed.
+	Exception *Exception `json:"exception,omitempty"`
+
+	// NativeError contains the reason the native handshake failed, if it did.
+	NativeError string `json:"native_error,omitempty"`
+
+	// HTTP contains the results of the HTTP interface query, if it was probed.
+	HTTP *HTTP `json:"http,omitempty"`
+
+	// HTTPError contains the reason the HTTP interface query failed, if it did.
+	HTTPError string `json:"http_error,omitempty"`
+}
+
+// Server contains the decoded server Hello.
+type Server struct {
+	// Name is the server name, for example "ClickHouse".
+	Name string `json:"name"`
+
+	// Version is the server version, for example "20.11.4".
+	Version string `json:"version"`
+
+	// Revision is the protocol revision of the server.
+	Revision uint64 `json:"revision"`
+
+	// Timezone is the server's timezone, for example "UTC".
+	Timezone string `json:"timezone,omitempty"`
+
+	// DisplayName is the server's display name, the host name unless configured.
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// HTTP contains the result of querying the server version over the HTTP interface.
+type HTTP struct {
+	// StatusCode is the HTTP status code of the query.
+	StatusCode int `json:"status_code"`
+
+	// Unauthenticated indicates if the query succeeded without credentials.
+	Unauthenticated bool `json:"unauthenticated"`
+
+	// Version is the server version the query returned.
+	Version string `json:"version,omitempty"`
+
+	// Error is the error message returned, for example "Code: 516, e.displayText() = DB::Exception: ...".
+	Error string `json:"error,omitempty"`
+}
+
+// Probe sends a native Hello as the default user with an empty password, then (if a client is given) queries the
+// server version over the HTTP interface without credentials. The HTTP interface is still queried when the native
+// interface can't be reached.
+func Probe(ctx context.Context, dial func() (net.Conn, error), c *protohttp.Client) (*Report, error) {
+	report := &Report{}
+
+	// (1) Native Hello
+
+	nativeErr := hello(dial, report)
+	if nativeErr != nil {
+		report.NativeError = nativeErr.Error()
+	}
+
+	// (2) HTTP Query
+
+	if c != nil {
+		var err error
+		report.HTTP, err = query(ctx, c)
+		if err != nil {
+			report.HTTPError = err.Error()
+		}
+	}
+
+	if nativeErr != nil && report.HTTP == nil {
+		return nil, nativeErr
+	}
+	return report, nil
+}
+
+// Connects to the native interface, sends the Hello and records the server's Hello or Exception.
+func hello(dial func() (net.Conn, error), report *Report) error {
+	conn, err := dial()
+	if err != nil {
+		return err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.Write(EncodeHello(defaultDatabase, defaultUser, "")); err != nil {
+		return err
+	}
+
+	h, err := ReadHello(conn)
+	if err != nil {
+		var e *Exception
+		if errors.As(err, &e) {
+			report.Exception = e
+			return nil
+		}
+		return err
+	}
+
+	report.DefaultUser = true
+	report.Server = &Server{
+		Name:        h.Name,
+		Version:     fmt.Sprintf("%d.%d.%d", h.VersionMajor, h.VersionMinor, h.VersionPatch),
+		Revision:    h.Revision,
+		Timezone:    h.Timezone,
+		DisplayName: h.DisplayName,
+	}
+	return nil
+}
+
+// Queries the server version over the HTTP interface.
+func query(ctx context.Context, c *protohttp.Client) (*HTTP, error) {
+	resp, err := c.Get(ctx, "/?query="+url.QueryEscape(versionQuery))
+	if err != nil {
+		return nil, err
+	}
+
+	body := strings.TrimSpace(string(resp.Body))
+	result := &HTTP{
+		StatusCode: resp.StatusCode,
+	}
+
+	// Other web servers may answer the query too, so a successful response must carry a ClickHouse header
+	// or a version number.
+	clickhouse := resp.Header.Get("X-ClickHouse-Query-Id") != "" || isVersion(body)
+
+	switch {
+	case resp.StatusCode == http.StatusOK && clickhouse:
+		result.Unauthenticated = true
+		result.Version = body
+	case strings.HasPrefix(body, "Code: ") || resp.Header.Get("X-ClickHouse-Exception-Code") != "":
+		result.Error = body
+	default:
+		return nil, fmt.Errorf("%w: unexpected response %s, interface is not clickhouse", ErrHTTPResponse, resp.Status)
+	}
+
+	return result, nil
+}
+
+// Determines if the string is a dotted version number, for example "20.11.4.13".
+func isVersion(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != '.' {
+			return false
+		}
+	}
+	return strings.Contains(s, ".")
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dns provides facilities for probing and inspecting DNS servers.
+package dns
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated message or not a dns response", ErrMessageDecode)
+
+// Record Types
+const (
+	TypeA     = 1
+	TypeCNAME = 5
+	TypeTXT   = 16
+	TypeAAAA  = 28
+)
+
+// Classes
+const (
+	ClassIN = 1
+	ClassCH = 3
+)
+
+// Header Flags
+const (
+	flagQR = 0x8000
+	flagAA = 0x0400
+	flagTC = 0x0200
+	flagRD = 0x0100
+	flagRA = 0x0080
+)
+
+// Size of the message header.
+const headerSize = 12
+
+// Maximum number of compression pointers followed while decoding a single name.
+const maxPointers = 16
+
+// Question is a single DNS question.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// Record is a decoded resource record.
+type Record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+
+	// Data is the decoded record data: the address for A and AAAA, the target for CNAME,
+	// the strings for TXT (joined) and a placeholder for any other type.
+	Data string
+
+	// TXT contains the individual strings of a TXT record.
+	TXT []string
+}
+
+// Message is a decoded DNS response.
+type Message struct {
+	ID uint16
+
+	// RCode is the response code.
+	RCode uint8
+
+	// Authoritative indicates if the server is authoritative for the name.
+	Authoritative bool
+
+	// Truncated indicates if the response was truncated and should be retried over TCP.
+	Truncated bool
+
+	// RecursionAvailable indicates if the server offers recursion.
+	RecursionAvailable bool
+
+	// Answers contains the records of the answer section.
+	Answers []Record
+}
+
+// EncodeQuery encodes a query for the given question with a random ID, returning the message and the ID.
+//
+// See https://tools.ietf.org/html/rfc1035#section-4.1
+func EncodeQuery(q Question, recursionDesired bool) ([]byte, uint16) {
+	id := make([]byte, 2)
+	_, _ = rand.Read(id)
+
+	// Header
+	//	2 Bytes: ID
+	//	2 Bytes: Flags
+	//	2 Bytes: QDCOUNT
+	//	2 Bytes: ANCOUNT
+	//	2 Bytes: NSCOUNT
+	//	2 Bytes: ARCOUNT
+
+	msg := make([]byte, headerSize)
+	copy(msg, id)
+	if recursionDesired {
+		binary.BigEndian.PutUint16(msg[2:], flagRD)
+	}
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	// Question
+	//	Variable: QNAME (length prefixed labels, terminated by a zero length label)
+	//	2 Bytes: QTYPE
+	//	2 Bytes: QCLASS
+
+	for _, label := range strings.Split(strings.Trim(q.Name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, byte(q.Type>>8), byte(q.Type), byte(q.Class>>8), byte(q.Class))
+
+	return msg, binary.BigEndian.Uint16(id)
+}
+
+// DecodeMessage attempts to decode the given series of bytes as a DNS response.
+// Only the answer section is decoded, the authority and additional sections are ignored.
+func DecodeMessage(b []byte) (*Message, error) {
+	header, pos, err := wire.ReadBuffer(b, 0, headerSize)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+
+	flags := binary.BigEndian.Uint16(header[2:])
+	if flags&flagQR == 0 {
+		return nil, fmt.Errorf("%w: message is not a response", ErrMessageDecode)
+	}
+
+	msg := &Message{
+		ID:                 binary.BigEndian.Uint16(header),
+		RCode:              uint8(flags & 0x000F),
+		Authoritative:      flags&flagAA != 0,
+		Truncated:          flags&flagTC != 0,
+		RecursionAvailable: flags&flagRA != 0,
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(header[4:]))
+	ancount := int(binary.BigEndian.Uint16(header[6:]))
+
+	// Question Section (skipped)
+
+	for i := 0; i < qdcount; i++ {
+		_, next, err := readName(b, pos)
+		if err != nil {
+			return nil, err
+		}
+		if _, pos, err = wire.ReadBuffer(b, next, 4); err != nil {
+			return nil, ErrMessageTruncated
+		}
+	}
+
+	// Answer Section
+	//	Variable: NAME
+	//	2 Bytes: TYPE
+	//	2 Bytes: CLASS
+	//	4 Bytes: TTL
+	//	2 Bytes: RDLENGTH
+	//	Variable: RDATA
+
+	for i := 0; i < ancount; i++ {
+		name, next, err := readName(b, pos)
+		if err != nil {
+			return nil, err
+		}
+		sub, next, err := wire.ReadBuffer(b, next, 10)
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+
+		rr := Record{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(sub),
+			Class: binary.BigEndian.Uint16(sub[2:]),
+			TTL:   binary.BigEndian.Uint32(sub[4:]),
+		}
+
+		rdata, end, err := wire.ReadBuffer(b, next, int(binary.BigEndian.Uint16(sub[8:])))
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+
+		switch rr.Type {
+		case TypeA, TypeAAAA:
+			rr.Data = net.IP(rdata).String()
+		case TypeCNAME:
+			if rr.Data, _, err = readName(b, next); err != nil {
+				return nil, err
+			}
+		case TypeTXT:
+			if rr.TXT, err = decodeTXT(rdata); err != nil {
+				return nil, err
+			}
+			rr.Data = strings.Join(rr.TXT, "")
+		default:
+			rr.Data = fmt.Sprintf("TYPE%d (%d bytes)", rr.Type, len(rdata))
+		}
+
+		msg.Answers = append(msg.Answers, rr)
+		pos = end
+	}
+
+	return msg, nil
+}
+
+// RCodeName returns the name of a response code.
+func RCodeName(rcode uint8) string {
+	switch rcode {
+	case 0:
+		return "NOERROR"
+	case 1:
+		return "FORMERR"
+	case 2:
+		return "SERVFAIL"
+	case 3:
+		return "NXDOMAIN"
+	case 4:
+		return "NOTIMP"
+	case 5:
+		return "REFUSED"
+	case 6:
+		return "YXDOMAIN"
+	case 7:
+		return "YXRRSET"
+	case 8:
+		return "NXRRSET"
+	case 9:
+		return "NOTAUTH"
+	case 10:
+		return "NOTZONE"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", rcode)
+	}
+}
+
+// WriteTCP writes a message using the TCP framing, a 2 byte length prefix.
+//
+// See https://tools.ietf.org/html/rfc1035#section-4.2.2
+func WriteTCP(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
+
+// ReadTCP reads a message using the TCP framing.
+func ReadTCP(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+	}
+
+	buf = make([]byte, binary.BigEndian.Uint16(buf))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+	}
+	return buf, nil
+}
+
+// Reads a possibly compressed domain name at the given position, returning the name and the position after it.
+//
+// See https://tools.ietf.org/html/rfc1035#section-4.1.4
+func readName(b []byte, pos int) (string, int, error) {
+	var labels []string
+	end := -1
+
+	for pointers := 0; ; {
+		sub, next, err := wire.ReadBuffer(b, pos, 1)
+		if err != nil {
+			return "", 0, ErrMessageTruncated
+		}
+		length := int(sub[0])
+
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = next
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case length&0xC0 == 0xC0:
+			sub, next, err = wire.ReadBuffer(b, pos, 2)
+			if err != nil {
+				return "", 0, ErrMessageTruncated
+			}
+			if end < 0 {
+				end = next
+			}
+			pointers++
+			if pointers > maxPointers {
+				return "", 0, fmt.Errorf("%w: too many compression pointers", ErrMessageDecode)
+			}
+			pos = int(binary.BigEndian.Uint16(sub) & 0x3FFF)
+		case length&0xC0 != 0:
+			return "", 0, fmt.Errorf("%w: unsupported label type 0x%02x", ErrMessageDecode, length)
+		default:
+			label, next, err := wire.ReadBuffer(b, next, length)
+			if err != nil {
+				return "", 0, ErrMessageTruncated
+			}
+			labels = append(labels, string(label))
+			pos = next
+		}
+	}
+}
+
+// Decodes TXT record data, a series of length prefixed strings.
+func decodeTXT(rdata []byte) ([]string, error) {
+	txt := []string{}
+	for pos := 0; pos < len(rdata); {
+		length := int(rdata[pos])
+		s, next, err := wire.ReadBuffer(rdata, pos+1, length)
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+		txt = append(txt, string(s))
+		pos = next
+	}
+	return txt, nil
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/seglberg/protoscan/pkg/udp"
+)
+
+// ChaosNames lists the CHAOS class TXT names queried, commonly answered with the server software version,
+// host name and server identifier.
+var ChaosNames = []string{"version.bind", "hostname.bind", "id.server"}
+
+// Options configures the DNS prober.
+type Options struct {
+	// DialTCP makes a new TCP connection to the target.
+	DialTCP func() (net.Conn, error)
+
+	// DialUDP makes a new UDP transport to the target.
+	DialUDP func() (*udp.Transport, error)
+
+	// RecursionName is the name queried to test whether the server answers recursive queries.
+	RecursionName string
+}
+
+// Report contains the information gathered from a DNS server.
+type Report struct {
+	// Version is the first version.bind answer.
+	Version string `json:"version,omitempty"`
+
+	// Hostname is the first hostname.bind answer.
+	Hostname string `json:"hostname,omitempty"`
+
+	// ID is the first id.server answer.
+	ID string `json:"id,omitempty"`
+
+	// UDP contains the CHAOS query results over UDP, keyed by name.
+	UDP map[string]*Answer `json:"udp"`
+
+	// TCP contains the CHAOS query results over TCP, keyed by name.
+	TCP map[string]*Answer `json:"tcp"`
+
+	// Recursion contains the result of the recursive query.
+	Recursion *Answer `json:"recursion"`
+
+	// OpenResolver indicates if the server answered the recursive query, offering recursion to anyone.
+	OpenResolver bool `json:"open_resolver"`
+}
+
+// Answer is the result of a single query.
+type Answer struct {
+	// RCode is the name of the response code, for example "NOERROR".
+	RCode string `json:"rcode,omitempty"`
+
+	// Authoritative indicates if the server is authoritative for the name.
+	Authoritative bool `json:"authoritative"`
+
+	// RecursionAvailable indicates if the server offers recursion.
+	RecursionAvailable bool `json:"recursion_available"`
+
+	// Answers contains the data of each answer record.
+	Answers []string `json:"answers"`
+
+	// Error contains the reason the query failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Probe sends CHAOS TXT queries over UDP and TCP, then sends a recursive query for the configured name.
+// Each query uses its own connection.
+func Probe(opts *Options) (*Report, error) {
+	report := &Report{
+		UDP: map[string]*Answer{},
+		TCP: map[string]*Answer{},
+	}
+
+	// (1) CHAOS TXT Queries over UDP and TCP
+
+	responded := false
+	for _, name := range ChaosNames {
+		q := Question{Name: name, Type: TypeTXT, Class: ClassCH}
+
+		for _, transport := range []struct {
+			answers map[string]*Answer
+			query   func(q Question, recursionDesired bool) (*Message, error)
+		}{
+			{report.UDP, opts.queryUDP},
+			{report.TCP, opts.queryTCP},
+		} {
+			answer := newAnswer(transport.query(q, false))
+			if answer.Error == "" {
+				responded = true
+			}
+			transport.answers[name] = answer
+		}
+	}
+
+	report.Version = firstAnswer(report, "version.bind")
+	report.Hostname = firstAnswer(report, "hostname.bind")
+	report.ID = firstAnswer(report, "id.server")
+
+	// (2) Recursive Query
+	//		UDP is tried first, falling back to TCP.
+
+	q := Question{Name: opts.RecursionName, Type: TypeA, Class: ClassIN}
+	msg, err := opts.queryUDP(q, true)
+	if err != nil {
+		msg, err = opts.queryTCP(q, true)
+	}
+	if err != nil && !responded {
+		return nil, err
+	}
+
+	report.Recursion = newAnswer(msg, err)
+	report.OpenResolver = err == nil && msg.RCode == 0 && msg.RecursionAvailable && len(msg.Answers) > 0
+
+	return report, nil
+}
+
+// Makes a new UDP transport and sends a single query, waiting for the response with the matching ID.
+func (opts *Options) queryUDP(q Question, recursionDesired bool) (*Message, error) {
+	t, err := opts.DialUDP()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of transport.
+	defer func() {
+		_ = t.Close()
+	}()
+
+	req, id := EncodeQuery(q, recursionDesired)
+	resp, err := t.Exchange(req, func(datagram []byte) bool {
+		return len(datagram) >= 2 && binary.BigEndian.Uint16(datagram) == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMessage(resp)
+}
+
+// Makes a new TCP connection and sends a single query.
+func (opts *Options) queryTCP(q Question, recursionDesired bool) (*Message, error) {
+	conn, err := opts.DialTCP()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	req, id := EncodeQuery(q, recursionDesired)
+	if err := WriteTCP(conn, req); err != nil {
+		return nil, err
+	}
+	resp, err := ReadTCP(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := DecodeMessage(resp)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ID != id {
+		return nil, fmt.Errorf("%w: response id does not match query", ErrMessageDecode)
+	}
+	return msg, nil
+}
+
+// Creates the answer reported for a query's response, or the error it failed with.
+func newAnswer(msg *Message, err error) *Answer {
+	if err != nil {
+		return &Answer{Answers: []string{}, Error: err.Error()}
+	}
+
+	answer := &Answer{
+		RCode:              RCodeName(msg.RCode),
+		Authoritative:      msg.Authoritative,
+		RecursionAvailable: msg.RecursionAvailable,
+		Answers:            []string{},
+	}
+	for _, rr := range msg.Answers {
+		answer.Answers = append(answer.Answers, rr.Data)
+	}
+	return answer
+}
+
+// Returns the first answer for the name, preferring UDP.
+func firstAnswer(report *Report, name string) string {
+	for _, answers := range []map[string]*Answer{report.UDP, report.TCP} {
+		if a := answers[name]; a != nil && len(a.Answers) > 0 {
+			return a.Answers[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package docker provides facilities for probing and inspecting exposed Docker Engine APIs.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+var ErrResponse = fmt.Errorf("docker response")
+
+// Report contains the information gathered from a Docker Engine API.
+type Report struct {
+	// Version is the engine version, for example "19.03.13".
+	Version string `json:"version"`
+
+	// APIVersion is the highest API version supported, for example "1.40".
+	APIVersion string `json:"api_version"`
+
+	// MinAPIVersion is the lowest API version supported.
+	MinAPIVersion string `json:"min_api_version,omitempty"`
+
+	// GitCommit is the commit the engine was built from.
+	GitCommit string `json:"git_commit,omitempty"`
+
+	// GoVersion is the Go version the engine was built with.
+	GoVersion string `json:"go_version,omitempty"`
+
+	// OS is the operating system, for example "linux".
+	OS string `json:"os"`
+
+	// Arch is the architecture, for example "amd64".
+	Arch string `json:"arch"`
+
+	// KernelVersion is the kernel version of the host.
+	KernelVersion string `json:"kernel_version,omitempty"`
+
+	// Platform is the platform name, for example "Docker Engine - Community".
+	Platform string `json:"platform,omitempty"`
+
+	// AnonymousAccess indicates if /info was answered without credentials. The API grants full control of
+	// the host to anyone who can reach it, unless an authorization plugin is in use.
+	AnonymousAccess bool `json:"anonymous_access"`
+
+	// Info contains the system information returned by /info.
+	Info *Info `json:"info,omitempty"`
+
+	// InfoError contains the reason the /info request failed, if it did.
+	InfoError string `json:"info_error,omitempty"`
+}
+
+// Info contains the system information returned by /info.
+type Info struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	OperatingSystem   string   `json:"operating_system"`
+	OSType            string   `json:"os_type"`
+	Architecture      string   `json:"architecture"`
+	NCPU              int      `json:"ncpu"`
+	MemTotal          int64    `json:"mem_total"`
+	Containers        int      `json:"containers"`
+	ContainersRunning int      `json:"containers_running"`
+	Images            int      `json:"images"`
+	DockerRootDir     string   `json:"docker_root_dir,omitempty"`
+	SwarmState        string   `json:"swarm_state,omitempty"`
+	SecurityOptions   []string `json:"security_options,omitempty"`
+}
+
+// Response body of GET /version
+type version struct {
+	Version       string
+	APIVersion    string `json:"ApiVersion"`
+	MinAPIVersion string `json:"MinAPIVersion"`
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+	KernelVersion string
+	Platform      struct {
+		Name string
+	}
+}
+
+// Response body of GET /info
+type info struct {
+	ID                string
+	Name              string
+	OperatingSystem   string
+	OSType            string
+	Architecture      string
+	NCPU              int
+	MemTotal          int64
+	Containers        int
+	ContainersRunning int
+	Images            int
+	DockerRootDir     string
+	Swarm             struct {
+		LocalNodeState string
+	}
+	SecurityOptions []string
+}
+
+// Probe requests /version, which identifies the engine, then /info to determine whether the API is
+// accessible without credentials. Neither request changes anything on the host.
+func Probe(ctx context.Context, c *protohttp.Client) (*Report, error) {
+	// (1) GET /version
+
+	var v version
+	if err := getJSON(ctx, c, "/version", &v); err != nil {
+		return nil, err
+	}
+	if v.APIVersion == "" {
+		return nil, fmt.Errorf("%w: /version: not a docker engine api", ErrResponse)
+	}
+
+	report := &Report{
+		Version:       v.Version,
+		APIVersion:    v.APIVersion,
+		MinAPIVersion: v.MinAPIVersion,
+		GitCommit:     v.GitCommit,
+		GoVersion:     v.GoVersion,
+		OS:            v.Os,
+		Arch:          v.Arch,
+		KernelVersion: v.KernelVersion,
+		Platform:      v.Platform.Name,
+	}
+
+	// (2) GET /info
+
+	var i info
+	if err := getJSON(ctx, c, "/info", &i); err != nil {
+		report.InfoError = err.Error()
+		return report, nil
+	}
+
+	report.AnonymousAccess = true
+	report.Info = &Info{
+		ID:                i.ID,
+		Name:              i.Name,
+		OperatingSystem:   i.OperatingSystem,
+		OSType:            i.OSType,
+		Architecture:      i.Architecture,
+		NCPU:              i.NCPU,
+		MemTotal:          i.MemTotal,
+		Containers:        i.Containers,
+		ContainersRunning: i.ContainersRunning,
+		Images:            i.Images,
+		DockerRootDir:     i.DockerRootDir,
+		SwarmState:        i.Swarm.LocalNodeState,
+		SecurityOptions:   i.SecurityOptions,
+	}
+
+	return report, nil
+}
+
+// Sends a GET request and decodes the JSON response body, failing on any status other than 200 OK.
+func getJSON(ctx context.Context, c *protohttp.Client, path string, v interface{}) error {
+	resp, err := c.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: unexpected status %s", ErrResponse, path, resp.Status)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrResponse, path, err)
+	}
+	return nil
+}
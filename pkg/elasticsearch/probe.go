@@ -0,0 +1,230 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package elasticsearch provides facilities for probing and inspecting Elasticsearch and OpenSearch clusters.
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+var ErrResponse = fmt.Errorf("elasticsearch response")
+
+// Distributions
+const (
+	DistributionElasticsearch = "elasticsearch"
+	DistributionOpenSearch    = "opensearch"
+)
+
+// Report contains the information gathered from an Elasticsearch or OpenSearch node.
+type Report struct {
+	// StatusCode is the HTTP status code of the unauthenticated GET / request.
+	StatusCode int `json:"status_code"`
+
+	// SecurityDisabled indicates if the node answered the unauthenticated request, either because security
+	// is disabled or because anonymous access is enabled.
+	SecurityDisabled bool `json:"security_disabled"`
+
+	// Authenticate is the WWW-Authenticate challenge of a 401 response, for example `Basic realm="security"`.
+	Authenticate string `json:"authenticate,omitempty"`
+
+	// Distribution is either "elasticsearch" or "opensearch".
+	Distribution string `json:"distribution"`
+
+	// Name is the node name.
+	Name string `json:"name,omitempty"`
+
+	// ClusterName is the name of the cluster.
+	ClusterName string `json:"cluster_name,omitempty"`
+
+	// ClusterUUID is the unique identifier of the cluster, "_na_" until a master is elected.
+	ClusterUUID string `json:"cluster_uuid,omitempty"`
+
+	// Version is the version number, for example "7.10.0".
+	Version string `json:"version,omitempty"`
+
+	// BuildFlavor is the build flavor, for example "default" or "oss".
+	BuildFlavor string `json:"build_flavor,omitempty"`
+
+	// BuildType is the packaging, for example "docker", "deb" or "tar".
+	BuildType string `json:"build_type,omitempty"`
+
+	// BuildHash is the commit the node was built from.
+	BuildHash string `json:"build_hash,omitempty"`
+
+	// BuildDate is the date the node was built.
+	BuildDate string `json:"build_date,omitempty"`
+
+	// LuceneVersion is the version of the underlying Lucene library.
+	LuceneVersion string `json:"lucene_version,omitempty"`
+
+	// MinimumWireCompatibilityVersion is the oldest version the node can communicate with.
+	MinimumWireCompatibilityVersion string `json:"minimum_wire_compatibility_version,omitempty"`
+
+	// Tagline is the tagline, "You Know, for Search" for Elasticsearch.
+	Tagline string `json:"tagline,omitempty"`
+
+	// Health contains the cluster health, if the node answered the request.
+	Health *Health `json:"health,omitempty"`
+
+	// HealthError contains the reason the cluster health request failed, if it did.
+	HealthError string `json:"health_error,omitempty"`
+}
+
+// Health is the response to a GET /_cluster/health request.
+type Health struct {
+	// ClusterName is the name of the cluster.
+	ClusterName string `json:"cluster_name"`
+
+	// Status is the cluster status, "green", "yellow" or "red".
+	Status string `json:"status"`
+
+	// TimedOut indicates if the request timed out waiting for the cluster status.
+	TimedOut bool `json:"timed_out"`
+
+	// NumberOfNodes is the number of nodes in the cluster.
+	NumberOfNodes int `json:"number_of_nodes"`
+
+	// NumberOfDataNodes is the number of data nodes in the cluster.
+	NumberOfDataNodes int `json:"number_of_data_nodes"`
+
+	// ActivePrimaryShards is the number of active primary shards.
+	ActivePrimaryShards int `json:"active_primary_shards"`
+
+	// ActiveShards is the number of active primary and replica shards.
+	ActiveShards int `json:"active_shards"`
+
+	// UnassignedShards is the number of shards not allocated to a node.
+	UnassignedShards int `json:"unassigned_shards"`
+}
+
+// Response body of GET /
+type info struct {
+	Name        string `json:"name"`
+	ClusterName string `json:"cluster_name"`
+	ClusterUUID string `json:"cluster_uuid"`
+	Version     *struct {
+		Number                          string `json:"number"`
+		Distribution                    string `json:"distribution"`
+		BuildFlavor                     string `json:"build_flavor"`
+		BuildType                       string `json:"build_type"`
+		BuildHash                       string `json:"build_hash"`
+		BuildDate                       string `json:"build_date"`
+		LuceneVersion                   string `json:"lucene_version"`
+		MinimumWireCompatibilityVersion string `json:"minimum_wire_compatibility_version"`
+	} `json:"version"`
+	Tagline string `json:"tagline"`
+}
+
+// Probe sends an unauthenticated GET / request, reporting the node and version information, then requests the
+// cluster health. A 401 response is still reported, since the challenge identifies the distribution.
+func Probe(ctx context.Context, c *protohttp.Client) (*Report, error) {
+	// (1) GET /
+
+	resp, err := c.Get(ctx, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		StatusCode:   resp.StatusCode,
+		Authenticate: resp.Header.Get("WWW-Authenticate"),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var body info
+		if err := json.Unmarshal(resp.Body, &body); err != nil || body.Version == nil {
+			return nil, fmt.Errorf("%w: not an elasticsearch or opensearch node", ErrResponse)
+		}
+
+		report.SecurityDisabled = true
+		report.Name = body.Name
+		report.ClusterName = body.ClusterName
+		report.ClusterUUID = body.ClusterUUID
+		report.Version = body.Version.Number
+		report.BuildFlavor = body.Version.BuildFlavor
+		report.BuildType = body.Version.BuildType
+		report.BuildHash = body.Version.BuildHash
+		report.BuildDate = body.Version.BuildDate
+		report.LuceneVersion = body.Version.LuceneVersion
+		report.MinimumWireCompatibilityVersion = body.Version.MinimumWireCompatibilityVersion
+		report.Tagline = body.Tagline
+
+		report.Distribution = DistributionElasticsearch
+		if body.Version.Distribution == DistributionOpenSearch {
+			report.Distribution = DistributionOpenSearch
+		}
+
+	case http.StatusUnauthorized, http.StatusForbidden:
+		report.Distribution = distribution(resp)
+		if report.Distribution == "" {
+			return nil, fmt.Errorf("%w: not an elasticsearch or opensearch node (%s)", ErrResponse, resp.Status)
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrResponse, resp.Status)
+	}
+
+	// (2) GET /_cluster/health
+
+	report.Health, err = health(ctx, c)
+	if err != nil {
+		report.HealthError = err.Error()
+	}
+
+	return report, nil
+}
+
+// Requests the cluster health.
+func health(ctx context.Context, c *protohttp.Client) (*Health, error) {
+	resp, err := c.Get(ctx, "/_cluster/health")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %s", ErrResponse, resp.Status)
+	}
+
+	h := &Health{}
+	if err := json.Unmarshal(resp.Body, h); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResponse, err)
+	}
+	return h, nil
+}
+
+// Identifies the distribution of a node refusing an unauthenticated request, from the product header sent by
+// Elasticsearch 7.14 and later, the security realm, or the security exception in the body.
+func distribution(resp *protohttp.Response) string {
+	authenticate := resp.Header.Get("WWW-Authenticate")
+
+	switch {
+	case resp.Header.Get("X-Elastic-Product") != "":
+		return DistributionElasticsearch
+	case strings.Contains(authenticate, "OpenSearch") || strings.Contains(authenticate, "Open Distro"):
+		return DistributionOpenSearch
+	case strings.Contains(authenticate, `realm="security"`) || strings.Contains(string(resp.Body), "security_exception"):
+		return DistributionElasticsearch
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package etcd provides facilities for probing and inspecting etcd servers over their HTTP API.
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+var ErrResponse = fmt.Errorf("etcd response")
+
+// GatewayPrefixes lists the gRPC-gateway path prefixes tried, newest first:
+// /v3 since etcd 3.4, /v3beta in 3.3 and /v3alpha in 3.2.
+var GatewayPrefixes = []string{"/v3", "/v3beta", "/v3alpha"}
+
+// Report contains the information gathered from an etcd server.
+type Report struct {
+	// Version is the server version, for example "3.4.13".
+	Version string `json:"version,omitempty"`
+
+	// ClusterVersion is the version of the cluster, for example "3.4.0".
+	ClusterVersion string `json:"cluster_version,omitempty"`
+
+	// VersionError contains the reason the /version request failed, if it did.
+	VersionError string `json:"version_error,omitempty"`
+
+	// Healthy indicates if the /health endpoint reported the member healthy.
+	Healthy *bool `json:"healthy,omitempty"`
+
+	// HealthError contains the reason the /health request failed, if it did.
+	HealthError string `json:"health_error,omitempty"`
+
+	// V3 contains the results of the v3 API requests over the gRPC-gateway, if it is enabled.
+	V3 *V3 `json:"v3,omitempty"`
+
+	// V3Error contains the reason the v3 API requests failed, if they did.
+	V3Error string `json:"v3_error,omitempty"`
+}
+
+// V3 contains the results of the v3 API requests made over the gRPC-gateway.
+type V3 struct {
+	// Prefix is the gateway path prefix which answered, for example "/v3".
+	Prefix string `json:"prefix"`
+
+	// ClusterID is the hex encoded cluster ID.
+	ClusterID string `json:"cluster_id,omitempty"`
+
+	// MemberID is the hex encoded ID of the member answering.
+	MemberID string `json:"member_id,omitempty"`
+
+	// Leader is the hex encoded ID of the cluster leader.
+	Leader string `json:"leader,omitempty"`
+
+	// DBSize is the size of the backend database in bytes.
+	DBSize string `json:"db_size,omitempty"`
+
+	// RaftTerm is the current raft term.
+	RaftTerm string `json:"raft_term,omitempty"`
+
+	// Unauthenticated indicates if the keyspace could be read without credentials, either because
+	// authentication is disabled or because the guest role is allowed to read.
+	Unauthenticated bool `json:"unauthenticated"`
+
+	// KeyCount is the number of keys in the keyspace, if it could be read.
+	KeyCount string `json:"key_count,omitempty"`
+
+	// RangeError contains the reason the keyspace couldn't be read, for example "etcdserver: user name is empty".
+	RangeError string `json:"range_error,omitempty"`
+}
+
+// Response body of the gRPC-gateway Maintenance.Status call.
+// 64-bit integers are encoded as strings, which json.Number accepts.
+type status struct {
+	Header struct {
+		ClusterID json.Number `json:"cluster_id"`
+		MemberID  json.Number `json:"member_id"`
+	} `json:"header"`
+	Version  string      `json:"version"`
+	DBSize   json.Number `json:"dbSize"`
+	Leader   json.Number `json:"leader"`
+	RaftTerm json.Number `json:"raftTerm"`
+}
+
+// Response body of the gRPC-gateway KV.Range call, or an error.
+type rangeResponse struct {
+	Count json.Number `json:"count"`
+	Error string      `json:"error"`
+	// Older gateways report errors in the message field.
+	Message string `json:"message"`
+}
+
+// Probe requests the /version and /health endpoints, then calls the v3 API over the gRPC-gateway without
+// credentials: Maintenance.Status for the cluster details, and a count only KV.Range over the whole keyspace to
+// determine whether keys can be read anonymously. No key or value is read.
+func Probe(ctx context.Context, c *protohttp.Client) (*Report, error) {
+	report := &Report{}
+
+	// (1) GET /version
+
+	var version struct {
+		Server  string `json:"etcdserver"`
+		Cluster string `json:"etcdcluster"`
+	}
+	versionErr := getJSON(ctx, c, "/version", &version)
+	if versionErr == nil && version.Server == "" {
+		versionErr = fmt.Errorf("%w: /version: not an etcd server", ErrResponse)
+	}
+	if versionErr != nil {
+		report.VersionError = versionErr.Error()
+	}
+	report.Version = version.Server
+	report.ClusterVersion = version.Cluster
+
+	// (2) GET /health
+
+	var health struct {
+		Health string `json:"health"`
+	}
+	if err := getJSON(ctx, c, "/health", &health); err != nil {
+		report.HealthError = err.Error()
+	} else {
+		healthy := health.Health == "true"
+		report.Healthy = &healthy
+	}
+
+	// (3) v3 API over the gRPC-gateway
+
+	var err error
+	report.V3, err = probeV3(ctx, c)
+	if err != nil {
+		report.V3Error = err.Error()
+	}
+
+	if versionErr != nil && report.V3 == nil {
+		return nil, versionErr
+	}
+	return report, nil
+}
+
+// Finds the gateway prefix answering Maintenance.Status, then attempts a count only range over the keyspace.
+func probeV3(ctx context.Context, c *protohttp.Client) (*V3, error) {
+	var err error
+	for _, prefix := range GatewayPrefixes {
+		var resp *protohttp.Response
+		resp, err = postJSON(ctx, c, prefix+"/maintenance/status", struct{}{})
+		if err != nil {
+			return nil, err
+		}
+		var s status
+		if err = decodeJSON(resp, prefix+"/maintenance/status", &s); err != nil {
+			continue
+		}
+
+		v3 := &V3{
+			Prefix:    prefix,
+			ClusterID: hexID(s.Header.ClusterID),
+			MemberID:  hexID(s.Header.MemberID),
+			Leader:    hexID(s.Leader),
+			DBSize:    s.DBSize.String(),
+			RaftTerm:  s.RaftTerm.String(),
+		}
+
+		// The key and range end of "\x00" (base64 "AA==") select the whole keyspace.
+		// Authentication failures are reported in the JSON body of an error response.
+		var r rangeResponse
+		req := map[string]interface{}{"key": "AA==", "range_end": "AA==", "count_only": true}
+		resp, err = postJSON(ctx, c, prefix+"/kv/range", req)
+		if err == nil && json.Unmarshal(resp.Body, &r) != nil {
+			err = fmt.Errorf("%w: %s/kv/range: unexpected status %s", ErrResponse, prefix, resp.Status)
+		}
+		switch {
+		case err != nil:
+			v3.RangeError = err.Error()
+		case r.Error != "":
+			v3.RangeError = r.Error
+		case r.Message != "":
+			v3.RangeError = r.Message
+		case resp.StatusCode != http.StatusOK:
+			v3.RangeError = fmt.Sprintf("%v: %s/kv/range: unexpected status %s", ErrResponse, prefix, resp.Status)
+		default:
+			v3.Unauthenticated = true
+			v3.KeyCount = r.Count.String()
+			if v3.KeyCount == "" {
+				// Zero values are omitted by the gateway.
+				v3.KeyCount = "0"
+			}
+		}
+
+		return v3, nil
+	}
+	return nil, err
+}
+
+// Sends a GET request and decodes the JSON response body.
+func getJSON(ctx context.Context, c *protohttp.Client, path string, v interface{}) error {
+	resp, err := c.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(resp, path, v)
+}
+
+// Sends a POST request with the JSON encoded body.
+func postJSON(ctx context.Context, c *protohttp.Client, path string, body interface{}) (*protohttp.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(ctx, &protohttp.Request{
+		Method: http.MethodPost,
+		Path:   path,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   payload,
+	})
+}
+
+// Decodes a JSON response body, failing on any status other than 200 OK.
+func decodeJSON(resp *protohttp.Response, path string, v interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: unexpected status %s", ErrResponse, path, resp.Status)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrResponse, path, err)
+	}
+	return nil
+}
+
+// Formats a decimal 64-bit ID in hex, as etcdctl does.
+func hexID(id json.Number) string {
+	v, err := strconv.ParseUint(id.String(), 10, 64)
+	if err != nil {
+		return id.String()
+	}
+	return strconv.FormatUint(v, 16)
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ftp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of directory listing entries reported.
+const maxListing = 100
+
+var (
+	pasvPattern = regexp.MustCompile(`\((\d+),(\d+),(\d+),(\d+),(\d+),(\d+)\)`)
+	epsvPattern = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+
+	// Directory names conventionally used for anonymous uploads.
+	uploadPattern = regexp.MustCompile(`(?i)^(incoming|upload|uploads|dropbox|drop|pub/incoming|tmp|temp)$`)
+)
+
+// Anonymous contains the result of an anonymous login attempt.
+type Anonymous struct {
+	// Allowed indicates if the anonymous login succeeded.
+	Allowed bool `json:"allowed"`
+
+	// Reply is the server's reply to the login attempt.
+	Reply string `json:"reply"`
+
+	// WorkingDirectory is the PWD reply after logging in.
+	WorkingDirectory string `json:"working_directory,omitempty"`
+
+	// Listing contains the (truncated) directory listing of the working directory.
+	Listing []string `json:"listing,omitempty"`
+
+	// WritableHints lists the reasons the server may allow anonymous writes.
+	// These are hints only, nothing is ever written to the server.
+	WritableHints []string `json:"writable_hints,omitempty"`
+}
+
+// Attempts an anonymous login on a new connection and, if allowed, lists the working directory
+// looking for hints that anonymous users may write to the server.
+func probeAnonymous(dial func() (net.Conn, error), dialData func(port int) (net.Conn, error)) (*Anonymous, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	text := textproto.NewConn(conn)
+	// Best effort QUIT.
+	defer func() {
+		_ = text.PrintfLine("QUIT")
+	}()
+
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return nil, wrapError(err)
+	}
+
+	anon := &Anonymous{}
+
+	// (1) Login
+
+	code, msg, err := command(text, 0, "USER %s", anonymousUser)
+	if err != nil && !isReplyError(err) {
+		return nil, err
+	}
+	if code == 331 {
+		code, msg, err = command(text, 0, "PASS %s", anonymousPassword)
+		if err != nil && !isReplyError(err) {
+			return nil, err
+		}
+	}
+
+	anon.Reply = fmt.Sprintf("%d %s", code, msg)
+	anon.Allowed = code == 230
+	if !anon.Allowed {
+		return anon, nil
+	}
+
+	if strings.Contains(strings.ToLower(msg), "upload") {
+		anon.WritableHints = append(anon.WritableHints, "login message mentions uploads")
+	}
+
+	// (2) Inspect the Working Directory
+	//		Failures past this point are not fatal, the login result is still reported.
+
+	if _, msg, err := command(text, 257, "PWD"); err == nil {
+		anon.WorkingDirectory = msg
+	}
+
+	listing, err := list(text, dialData)
+	if err != nil {
+		return anon, nil
+	}
+	anon.Listing = listing
+	anon.WritableHints = append(anon.WritableHints, writableHints(listing)...)
+
+	return anon, nil
+}
+
+// Lists the working directory over a passive mode data connection.
+func list(text *textproto.Conn, dialData func(port int) (net.Conn, error)) ([]string, error) {
+	if dialData == nil {
+		return nil, fmt.Errorf("%w: no data connection dialer", ErrResponse)
+	}
+
+	port, err := passive(text)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := dialData(port)
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = data.Close()
+	}()
+
+	id, err := text.Cmd("LIST")
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	if _, _, err := text.ReadResponse(1); err != nil {
+		return nil, wrapError(err)
+	}
+
+	var listing []string
+	scanner := bufio.NewScanner(data)
+	for scanner.Scan() && len(listing) < maxListing {
+		listing = append(listing, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	_ = data.Close()
+
+	// The transfer may be aborted if the listing was truncated, so the final reply is not checked.
+	_, _, _ = text.ReadResponse(2)
+
+	return listing, nil
+}
+
+// Enters passive mode, preferring EPSV, and returns the data port.
+func passive(text *textproto.Conn) (int, error) {
+	if _, msg, err := command(text, 229, "EPSV"); err == nil {
+		if m := epsvPattern.FindStringSubmatch(msg); m != nil {
+			return strconv.Atoi(m[1])
+		}
+	}
+
+	_, msg, err := command(text, 227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+	m := pasvPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, fmt.Errorf("%w: invalid passive reply %q", ErrResponse, msg)
+	}
+
+	// Only the port is used, the address is ignored since it is often an internal one.
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+	return p1*256 + p2, nil
+}
+
+// Looks for world writable or conventionally named upload directories in a Unix style listing.
+func writableHints(listing []string) []string {
+	var hints []string
+	for _, entry := range listing {
+		fields := strings.Fields(entry)
+		if len(fields) < 9 || len(fields[0]) != 10 {
+			continue
+		}
+
+		perms := fields[0]
+		name := strings.Join(fields[8:], " ")
+
+		if perms[0] == 'd' && perms[8] == 'w' {
+			hints = append(hints, fmt.Sprintf("world writable directory %q", name))
+		} else if perms[0] == 'd' && uploadPattern.MatchString(name) {
+			hints = append(hints, fmt.Sprintf("upload directory %q", name))
+		}
+	}
+	return hints
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ftp provides facilities for probing and inspecting FTP servers.
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+var ErrResponse = fmt.Errorf("ftp response")
+
+// Credentials used for the anonymous login attempt.
+const (
+	anonymousUser     = "anonymous"
+	anonymousPassword = "anonymous@example.com"
+)
+
+// Options configures the FTP prober.
+type Options struct {
+	// ServerName is sent as SNI when upgrading the connection with AUTH TLS.
+	ServerName string
+
+	// Anonymous enables the anonymous login attempt.
+	Anonymous bool
+
+	// DialData makes a passive mode data connection to the given port on the target.
+	// Required if Anonymous is set.
+	DialData func(port int) (net.Conn, error)
+}
+
+// Report contains the information gathered from an FTP server.
+type Report struct {
+	// Banner is the server's 220 greeting.
+	Banner string `json:"banner"`
+
+	// Software is the server software, parsed from the banner.
+	Software *Software `json:"software,omitempty"`
+
+	// System is the SYST response, for example "UNIX Type: L8".
+	System string `json:"system,omitempty"`
+
+	// Features lists the extensions returned by FEAT.
+	Features []string `json:"features"`
+
+	// AuthTLS indicates if the server accepted AUTH TLS.
+	AuthTLS bool `json:"auth_tls"`
+
+	// TLS describes the upgraded TLS session, if the upgrade succeeded.
+	TLS *tlsinfo.Report `json:"tls,omitempty"`
+
+	// TLSError contains the reason the upgrade failed, if it did.
+	TLSError string `json:"tls_error,omitempty"`
+
+	// Anonymous contains the result of the anonymous login attempt, if enabled.
+	Anonymous *Anonymous `json:"anonymous,omitempty"`
+}
+
+// Probe reads the server greeting, sends FEAT and SYST, and attempts to upgrade the connection with AUTH TLS.
+// If enabled, an anonymous login is then attempted on a new connection, since most servers refuse a
+// plain text login once the control connection has been upgraded.
+func Probe(dial func() (net.Conn, error), opts *Options) (*Report, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	text := textproto.NewConn(conn)
+
+	// (1) Read the Greeting
+
+	_, banner, err := text.ReadResponse(220)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	report := &Report{
+		Banner:   banner,
+		Software: ParseSoftware(banner),
+		Features: []string{},
+	}
+
+	// (2) Send FEAT and SYST
+	//		Both are optional, servers that don't support them simply aren't reported on.
+
+	if _, msg, err := command(text, 211, "FEAT"); err == nil {
+		report.Features = parseFeatures(msg)
+	} else if !isReplyError(err) {
+		return nil, err
+	}
+
+	if _, msg, err := command(text, 215, "SYST"); err == nil {
+		report.System = msg
+	} else if !isReplyError(err) {
+		return nil, err
+	}
+
+	// (3) Upgrade with AUTH TLS
+
+	_, _, err = command(text, 234, "AUTH TLS")
+	switch {
+	case err == nil:
+		report.AuthTLS = true
+
+		tlsConn, tlsReport, err := tlsinfo.Client(conn, opts.ServerName)
+		if err != nil {
+			report.TLSError = err.Error()
+		} else {
+			report.TLS = tlsReport
+			text = textproto.NewConn(tlsConn)
+		}
+	case !isReplyError(err):
+		return nil, err
+	}
+
+	// Best effort QUIT.
+	_ = text.PrintfLine("QUIT")
+
+	// (4) Attempt an Anonymous Login
+
+	if opts.Anonymous {
+		report.Anonymous, err = probeAnonymous(dial, opts.DialData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// Sends a command and reads the reply, expecting the given reply code.
+// Unexpected replies are returned as *textproto.Error.
+func command(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", wrapError(err)
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	code, msg, err := text.ReadResponse(expectCode)
+	if err != nil {
+		return code, msg, wrapError(err)
+	}
+	return code, msg, nil
+}
+
+// Parses the FEAT reply into a list of features.
+// The first and last lines ("Features:" and "End") are not features.
+func parseFeatures(msg string) []string {
+	features := []string{}
+
+	lines := strings.Split(msg, "\n")
+	if len(lines) < 3 {
+		return features
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if feat := strings.TrimSpace(line); feat != "" {
+			features = append(features, feat)
+		}
+	}
+	return features
+}
+
+// Determines if the error is a well formed but unexpected reply from the server.
+func isReplyError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr)
+}
+
+// Wraps a protocol error, passing through deadline errors and server replies untouched.
+func wrapError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) || isReplyError(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrResponse, err)
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ftp
+
+import (
+	"regexp"
+)
+
+// Software identifies the FTP server software.
+type Software struct {
+	// Name is the server software name, for example "vsFTPd".
+	Name string `json:"name"`
+
+	// Version is the server software version, if the banner includes it.
+	Version string `json:"version,omitempty"`
+}
+
+// Banner patterns of well known server software.
+// The first sub-match, if any, is the version.
+var softwarePatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"vsFTPd", regexp.MustCompile(`(?i)vsFTPd\s+([\d.]+)`)},
+	{"ProFTPD", regexp.MustCompile(`(?i)ProFTPD\s+([\w.]+)`)},
+	{"Pure-FTPd", regexp.MustCompile(`(?i)Pure-FTPd(?:\s+\[?([\w.]+))?`)},
+	{"FileZilla Server", regexp.MustCompile(`(?i)FileZilla Server(?:\s+(?:version\s+)?([\w.-]+))?`)},
+	{"Microsoft FTP Service", regexp.MustCompile(`(?i)Microsoft FTP Service()`)},
+	{"Serv-U", regexp.MustCompile(`(?i)Serv-U FTP Server v?([\d.]+)?`)},
+	{"wu-ftpd", regexp.MustCompile(`(?i)wu-([\d.]+)`)},
+	{"Gene6 FTP Server", regexp.MustCompile(`(?i)Gene6 FTP Server v?([\d.]+)?`)},
+	{"WS_FTP Server", regexp.MustCompile(`(?i)WS_FTP Server\s+([\d.]+)?`)},
+	{"Titan FTP Server", regexp.MustCompile(`(?i)Titan FTP Server\s+([\d.]+)?`)},
+	{"Xlight FTP Server", regexp.MustCompile(`(?i)Xlight FTP Server\s+([\d.]+)?`)},
+	{"Cerberus FTP Server", regexp.MustCompile(`(?i)Cerberus FTP Server\s+([\d.]+)?`)},
+	{"bftpd", regexp.MustCompile(`(?i)bftpd\s+([\d.]+)?`)},
+}
+
+// ParseSoftware identifies the server software from the banner.
+// nil is returned if the software isn't recognized.
+func ParseSoftware(banner string) *Software {
+	for _, sp := range softwarePatterns {
+		m := sp.pattern.FindStringSubmatch(banner)
+		if m == nil {
+			continue
+		}
+
+		sw := &Software{
+			Name: sp.name,
+		}
+		if len(m) > 1 {
+			sw.Version = m[1]
+		}
+		return sw
+	}
+	return nil
+}
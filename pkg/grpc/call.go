@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpc provides facilities for probing gRPC servers, calling the server reflection and health services
+// over a minimal HTTP/2 connection.
+package grpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/http2"
+)
+
+var ErrNotGRPC = fmt.Errorf("not grpc")
+var ErrMessageDecode = fmt.Errorf("grpc message decode")
+
+// User agent sent with each call.
+const userAgent = "protoscan"
+
+// Code is a gRPC status code.
+type Code uint32
+
+// Status Codes
+const (
+	CodeOK            Code = 0
+	CodeUnimplemented Code = 12
+)
+
+func (c Code) String() string {
+	names := []string{
+		"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+		"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE",
+		"UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+	}
+	if int(c) < len(names) {
+		return names[c]
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint32(c))
+}
+
+// StatusError is returned when a call ends with a status other than OK.
+type StatusError struct {
+	Code    Code
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("grpc status %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("grpc status %s", e.Code)
+}
+
+// Call makes a unary call of the given method (for example "/grpc.health.v1.Health/Check") on a new stream,
+// returning the response message. The HTTP/2 response is returned too, unless the request failed outright.
+//
+// See https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+func Call(c *http2.Conn, scheme, authority, method string, request []byte) ([]byte, *http2.Response, error) {
+	resp, err := c.RoundTrip([]http2.HeaderField{
+		{Name: ":method", Value: "POST"},
+		{Name: ":scheme", Value: scheme},
+		{Name: ":authority", Value: authority},
+		{Name: ":path", Value: method},
+		{Name: "content-type", Value: "application/grpc"},
+		{Name: "te", Value: "trailers"},
+		{Name: "user-agent", Value: userAgent},
+	}, EncodeMessage(request))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// (1) Content Type
+
+	contentType := resp.Get("content-type")
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		return nil, resp, fmt.Errorf("%w: status %s, content type %q", ErrNotGRPC, resp.Get(":status"), contentType)
+	}
+
+	// (2) Status
+	//	Sent in the trailers, or in the headers of a trailers-only response.
+
+	status := resp.Get("grpc-status")
+	code, err := strconv.ParseUint(status, 10, 32)
+	if err != nil {
+		return nil, resp, fmt.Errorf("%w: invalid grpc-status %q", ErrMessageDecode, status)
+	}
+	if Code(code) != CodeOK {
+		// The message is percent encoded.
+		message, err := url.PathUnescape(resp.Get("grpc-message"))
+		if err != nil {
+			message = resp.Get("grpc-message")
+		}
+		return nil, resp, &StatusError{Code: Code(code), Message: message}
+	}
+
+	// (3) Response Message
+
+	msg, err := DecodeMessage(resp.Body)
+	if err != nil {
+		return nil, resp, err
+	}
+	return msg, resp, nil
+}
+
+// EncodeMessage encodes a length prefixed, uncompressed message.
+func EncodeMessage(msg []byte) []byte {
+	// Length-Prefixed Message
+	//	1 Byte: Compressed Flag
+	//	4 Bytes: Message Length
+	//	n Bytes: Message
+
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	return append(buf, msg...)
+}
+
+// DecodeMessage decodes the first length prefixed message of a response body.
+func DecodeMessage(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("%w: truncated message", ErrMessageDecode)
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("%w: compressed messages are not supported", ErrMessageDecode)
+	}
+
+	length := binary.BigEndian.Uint32(body[1:])
+	if uint64(len(body)-5) < uint64(length) {
+		return nil, fmt.Errorf("%w: truncated message", ErrMessageDecode)
+	}
+	return body[5 : 5+length], nil
+}
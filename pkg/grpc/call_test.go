@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncodeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+		want []byte
+	}{
+		{"empty", nil, []byte{0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"health check", []byte("\x0a\x00"), []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x0A, 0x00}},
+		{"long", make([]byte, 0x0102), append([]byte{0x00, 0x00, 0x00, 0x01, 0x02}, make([]byte, 0x0102)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EncodeMessage(tt.msg)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EncodeMessage() = %x, want %x", got, tt.want)
+			}
+
+			decoded, err := DecodeMessage(got)
+			if err != nil {
+				t.Fatalf("DecodeMessage() error = %v", err)
+			}
+			if !bytes.Equal(decoded, tt.msg) {
+				t.Errorf("DecodeMessage() = %x, want %x", decoded, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"empty message", []byte{0x00, 0x00, 0x00, 0x00, 0x00}, []byte{}, nil},
+		{"serving", []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01}, []byte{0x08, 0x01}, nil},
+		{"first of several", []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0xAA, 0x00, 0x00, 0x00, 0x00, 0x01, 0xBB}, []byte{0xAA}, nil},
+		{"empty body", nil, nil, ErrMessageDecode},
+		{"truncated prefix", []byte{0x00, 0x00, 0x00}, nil, ErrMessageDecode},
+		{"truncated message", []byte{0x00, 0x00, 0x00, 0x00, 0x03, 0x08}, nil, ErrMessageDecode},
+		{"compressed", []byte{0x01, 0x00, 0x00, 0x00, 0x00}, nil, ErrMessageDecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMessage(tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("DecodeMessage() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/seglberg/protoscan/pkg/http2"
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+// Methods
+const (
+	MethodHealthCheck       = "/grpc.health.v1.Health/Check"
+	MethodReflectionV1Alpha = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+	MethodReflectionV1      = "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+)
+
+// Report contains the information gathered from an HTTP/2 server, and the gRPC services it exposes.
+type Report struct {
+	// Transport is "h2" if HTTP/2 was negotiated over TLS with ALPN, or "h2c" for cleartext HTTP/2.
+	Transport string `json:"transport"`
+
+	// TLS contains the negotiated TLS session, if HTTP/2 was negotiated over TLS.
+	TLS *tlsinfo.Report `json:"tls,omitempty"`
+
+	// TLSError contains the reason HTTP/2 over TLS failed, if it did.
+	TLSError string `json:"tls_error,omitempty"`
+
+	// Settings contains the parameters of the server's SETTINGS frame.
+	Settings http2.Settings `json:"settings"`
+
+	// Server is the server header of the first response, if any.
+	Server string `json:"server,omitempty"`
+
+	// GRPC indicates if the server responded to gRPC calls.
+	GRPC bool `json:"grpc"`
+
+	// Health is the serving status reported by the health service, for example "SERVING".
+	Health string `json:"health,omitempty"`
+
+	// HealthError contains the reason the health check failed, if it did.
+	HealthError string `json:"health_error,omitempty"`
+
+	// Reflection is the version of the reflection service which listed the services, "v1alpha" or "v1".
+	Reflection string `json:"reflection,omitempty"`
+
+	// Services lists the fully qualified names of the services exposed through server reflection.
+	Services []string `json:"services,omitempty"`
+
+	// ReflectionError contains the reason server reflection failed, if it did.
+	ReflectionError string `json:"reflection_error,omitempty"`
+}
+
+// Probe establishes an HTTP/2 connection, first over TLS negotiating "h2" with ALPN, falling back to cleartext
+// h2c with prior knowledge. If the server speaks gRPC, the health service is checked and the exposed services
+// are listed through server reflection. The authority is the target's "host:port", the host is sent as SNI.
+func Probe(dial func() (net.Conn, error), authority string) (*Report, error) {
+	report := &Report{}
+
+	serverName, _, err := net.SplitHostPort(authority)
+	if err != nil {
+		serverName = authority
+	}
+
+	// (1) HTTP/2 over TLS
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	c, tlsReport, err := handshakeTLS(conn, serverName)
+	scheme := "https"
+	if err == nil {
+		report.Transport = "h2"
+		report.TLS = tlsReport
+	} else {
+		report.TLSError = err.Error()
+
+		// (2) Cleartext HTTP/2 (h2c)
+
+		_ = conn.Close()
+		conn, err = dial()
+		if err != nil {
+			return nil, err
+		}
+
+		c, err = http2.Handshake(conn)
+		if err != nil {
+			return nil, err
+		}
+		scheme = "http"
+		report.Transport = "h2c"
+	}
+	report.Settings = c.Settings
+
+	// (3) Health Check
+	//	The empty request checks the overall health of the server.
+
+	msg, resp, err := Call(c, scheme, authority, MethodHealthCheck, nil)
+	if resp != nil {
+		report.Server = resp.Get("server")
+	}
+	if errors.Is(err, ErrNotGRPC) {
+		return report, nil
+	}
+	if resp != nil {
+		report.GRPC = true
+	}
+	if err != nil {
+		report.HealthError = err.Error()
+	} else if report.Health, err = decodeHealthCheckResponse(msg); err != nil {
+		report.HealthError = err.Error()
+	}
+
+	// (4) Server Reflection
+	//	v1alpha is the most widely deployed, newer servers may only implement v1.
+
+	for _, version := range []struct{ name, method string }{
+		{"v1alpha", MethodReflectionV1Alpha},
+		{"v1", MethodReflectionV1},
+	} {
+		report.Services, resp, err = listServices(c, scheme, authority, version.method)
+		if resp != nil {
+			report.GRPC = true
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == CodeUnimplemented {
+			continue
+		}
+		if err == nil {
+			report.Reflection = version.name
+		}
+		break
+	}
+	if err != nil {
+		report.ReflectionError = err.Error()
+	}
+
+	return report, nil
+}
+
+// Performs the TLS handshake, requiring the server to negotiate "h2", then the HTTP/2 handshake.
+func handshakeTLS(conn net.Conn, serverName string) (*http2.Conn, *tlsinfo.Report, error) {
+	tlsConn, tlsReport, err := tlsinfo.Client(conn, serverName, "h2")
+	if err != nil {
+		return nil, nil, err
+	}
+	if tlsConn.ConnectionState().NegotiatedProtocol != "h2" {
+		return nil, nil, fmt.Errorf("server did not negotiate h2 (alpn %q)", tlsConn.ConnectionState().NegotiatedProtocol)
+	}
+
+	c, err := http2.Handshake(tlsConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, tlsReport, nil
+}
+
+// Calls the reflection service with a list services request, returning the sorted service names.
+func listServices(c *http2.Conn, scheme, authority, method string) ([]string, *http2.Response, error) {
+	// ServerReflectionRequest
+	//	1 string host
+	//	7 string list_services (oneof message_request)
+
+	req := appendBytesField(nil, 1, []byte(authority))
+	req = appendBytesField(req, 7, []byte("*"))
+
+	msg, resp, err := Call(c, scheme, authority, method, req)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	services, err := decodeServerReflectionResponse(msg)
+	if err != nil {
+		return nil, resp, err
+	}
+	sort.Strings(services)
+	return services, resp, nil
+}
+
+// Decodes the service names of a ServerReflectionResponse.
+func decodeServerReflectionResponse(msg []byte) ([]string, error) {
+	// ServerReflectionResponse
+	//	6 ListServiceResponse list_services_response
+	//		1 repeated ServiceResponse service
+	//			1 string name
+	//	7 ErrorResponse error_response
+	//		1 int32 error_code
+	//		2 string error_message
+
+	fields, err := DecodeFields(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		switch {
+		case f.Number == 6 && f.Wire == wireLengthDelimited:
+			list, err := DecodeFields(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			services := []string{}
+			for _, service := range list {
+				if service.Number != 1 || service.Wire != wireLengthDelimited {
+					continue
+				}
+				name, err := DecodeFields(service.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				for _, n := range name {
+					if n.Number == 1 && n.Wire == wireLengthDelimited {
+						services = append(services, string(n.Bytes))
+					}
+				}
+			}
+			return services, nil
+		case f.Number == 7 && f.Wire == wireLengthDelimited:
+			errFields, err := DecodeFields(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			statusErr := &StatusError{}
+			for _, e := range errFields {
+				switch {
+				case e.Number == 1 && e.Wire == wireVarint:
+					statusErr.Code = Code(e.Varint)
+				case e.Number == 2 && e.Wire == wireLengthDelimited:
+					statusErr.Message = string(e.Bytes)
+				}
+			}
+			return nil, statusErr
+		}
+	}
+
+	return nil, fmt.Errorf("%w: reflection response without service list", ErrProtobufDecode)
+}
+
+// Decodes the serving status of a HealthCheckResponse.
+func decodeHealthCheckResponse(msg []byte) (string, error) {
+	// HealthCheckResponse
+	//	1 ServingStatus status
+
+	fields, err := DecodeFields(msg)
+	if err != nil {
+		return "", err
+	}
+
+	// A missing field is the default value, UNKNOWN.
+	status := uint64(0)
+	for _, f := range fields {
+		if f.Number == 1 && f.Wire == wireVarint {
+			status = f.Varint
+		}
+	}
+
+	switch status {
+	case 0:
+		return "UNKNOWN", nil
+	case 1:
+		return "SERVING", nil
+	case 2:
+		return "NOT_SERVING", nil
+	case 3:
+		return "SERVICE_UNKNOWN", nil
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", status), nil
+	}
+}
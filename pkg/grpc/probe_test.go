@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeServerReflectionResponse(t *testing.T) {
+	service := func(name string) []byte {
+		return appendBytesField(nil, 1, appendBytesField(nil, 1, []byte(name)))
+	}
+	list := append(service("grpc.health.v1.Health"), service("grpc.reflection.v1alpha.ServerReflection")...)
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    []string
+		wantErr error
+	}{
+		{
+			"services",
+			append(appendBytesField(nil, 1, []byte("localhost")), appendBytesField(nil, 6, list)...),
+			[]string{"grpc.health.v1.Health", "grpc.reflection.v1alpha.ServerReflection"},
+			nil,
+		},
+		{
+			"no services",
+			appendBytesField(nil, 6, nil),
+			[]string{},
+			nil,
+		},
+		{
+			"error response",
+			appendBytesField(nil, 7, append([]byte{0x08, 0x0C}, appendBytesField(nil, 2, []byte("not implemented"))...)),
+			nil,
+			&StatusError{Code: CodeUnimplemented, Message: "not implemented"},
+		},
+		{
+			"missing service list",
+			appendBytesField(nil, 1, []byte("localhost")),
+			nil,
+			ErrProtobufDecode,
+		},
+		{
+			"truncated",
+			[]byte("\x32\x05\x0a"),
+			nil,
+			ErrProtobufTruncated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeServerReflectionResponse(tt.msg)
+			var statusErr *StatusError
+			if errors.As(tt.wantErr, &statusErr) {
+				if !reflect.DeepEqual(err, tt.wantErr) {
+					t.Fatalf("decodeServerReflectionResponse() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeServerReflectionResponse() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeServerReflectionResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHealthCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    string
+		wantErr error
+	}{
+		{"default", nil, "UNKNOWN", nil},
+		{"serving", []byte{0x08, 0x01}, "SERVING", nil},
+		{"not serving", []byte{0x08, 0x02}, "NOT_SERVING", nil},
+		{"service unknown", []byte{0x08, 0x03}, "SERVICE_UNKNOWN", nil},
+		{"unknown status", []byte{0x08, 0x09}, "UNKNOWN(9)", nil},
+		{"truncated", []byte{0x08}, "", ErrProtobufTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeHealthCheckResponse(tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeHealthCheckResponse() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeHealthCheckResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var ErrProtobufDecode = fmt.Errorf("protobuf decode")
+var ErrProtobufTruncated = fmt.Errorf("%w: truncated message", ErrProtobufDecode)
+
+// Protobuf Wire Types
+const (
+	wireVarint          = 0
+	wireFixed64         = 1
+	wireLengthDelimited = 2
+	wireFixed32         = 5
+)
+
+// Field is a single decoded protobuf field. Only the value matching the wire type is set.
+type Field struct {
+	Number uint64
+	Wire   uint8
+	Varint uint64
+	Bytes  []byte
+}
+
+// Appends a length delimited field, such as a string or an embedded message.
+func appendBytesField(buf []byte, number uint64, value []byte) []byte {
+	buf = appendUvarint(buf, number<<3|wireLengthDelimited)
+	buf = appendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// Appends a varint.
+func appendUvarint(buf []byte, v uint64) []byte {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(tmp, v)
+	return append(buf, tmp[:n]...)
+}
+
+// DecodeFields decodes the fields of a protobuf message, in the order encoded.
+//
+// See https://developers.google.com/protocol-buffers/docs/encoding
+func DecodeFields(b []byte) ([]Field, error) {
+	var fields []Field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, ErrProtobufTruncated
+		}
+		b = b[n:]
+
+		f := Field{Number: key >> 3, Wire: uint8(key & 0x07)}
+		switch f.Wire {
+		case wireVarint:
+			if f.Varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, ErrProtobufTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, ErrProtobufTruncated
+			}
+			f.Varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireLengthDelimited:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, ErrProtobufTruncated
+			}
+			f.Bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, ErrProtobufTruncated
+			}
+			f.Varint = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("%w: unsupported wire type %d", ErrProtobufDecode, f.Wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAppendUvarint(t *testing.T) {
+	tests := []struct {
+		value uint64
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x80, 0x01}},
+		{300, []byte{0xAC, 0x02}},
+		{1<<64 - 1, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}},
+	}
+
+	for _, tt := range tests {
+		if got := appendUvarint([]byte{0xAA}, tt.value); !bytes.Equal(got, append([]byte{0xAA}, tt.want...)) {
+			t.Errorf("appendUvarint(%d) = %x, want aa%x", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAppendBytesField(t *testing.T) {
+	tests := []struct {
+		name   string
+		number uint64
+		value  []byte
+		want   []byte
+	}{
+		{"empty", 1, nil, []byte{0x0A, 0x00}},
+		{"string", 2, []byte("testing"), []byte("\x12\x07testing")},
+		{"list services", 7, []byte("*"), []byte("\x3A\x01*")},
+		{"two byte key", 16, []byte("a"), []byte("\x82\x01\x01a")},
+		{"two byte length", 1, make([]byte, 200), append([]byte{0x0A, 0xC8, 0x01}, make([]byte, 200)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := appendBytesField(nil, tt.number, tt.value)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("appendBytesField() = %x, want %x", got, tt.want)
+			}
+
+			fields, err := DecodeFields(got)
+			if err != nil {
+				t.Fatalf("DecodeFields() error = %v", err)
+			}
+			want := []Field{{Number: tt.number, Wire: wireLengthDelimited, Bytes: got[len(got)-len(tt.value):]}}
+			if !reflect.DeepEqual(fields, want) {
+				t.Errorf("DecodeFields() = %+v, want %+v", fields, want)
+			}
+		})
+	}
+}
+
+func TestDecodeFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    []Field
+		wantErr error
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"varint",
+			[]byte{0x08, 0x96, 0x01},
+			[]Field{{Number: 1, Wire: wireVarint, Varint: 150}},
+			nil,
+		},
+		{
+			"fixed64",
+			[]byte{0x11, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			[]Field{{Number: 2, Wire: wireFixed64, Varint: 0x0807060504030201}},
+			nil,
+		},
+		{
+			"length delimited",
+			[]byte("\x12\x07testing"),
+			[]Field{{Number: 2, Wire: wireLengthDelimited, Bytes: []byte("testing")}},
+			nil,
+		},
+		{
+			"fixed32",
+			[]byte{0x1D, 0x01, 0x02, 0x03, 0x04},
+			[]Field{{Number: 3, Wire: wireFixed32, Varint: 0x04030201}},
+			nil,
+		},
+		{
+			"several fields in order",
+			[]byte("\x08\x01\x12\x02hi\x08\x02"),
+			[]Field{
+				{Number: 1, Wire: wireVarint, Varint: 1},
+				{Number: 2, Wire: wireLengthDelimited, Bytes: []byte("hi")},
+				{Number: 1, Wire: wireVarint, Varint: 2},
+			},
+			nil,
+		},
+		{
+			"truncated key",
+			[]byte{0x80},
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"truncated varint",
+			[]byte{0x08, 0x96},
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"truncated fixed64",
+			[]byte{0x11, 0x01, 0x02, 0x03},
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"truncated length delimited",
+			[]byte("\x12\x07test"),
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"missing length",
+			[]byte{0x12},
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"truncated fixed32",
+			[]byte{0x1D, 0x01},
+			nil,
+			ErrProtobufTruncated,
+		},
+		{
+			"group wire type",
+			[]byte{0x0B, 0x0C},
+			nil,
+			ErrProtobufDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeFields(tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeFields() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeFields() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
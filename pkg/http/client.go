@@ -0,0 +1,213 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package http provides facilities for probing and inspecting HTTP and HTTPS servers.
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// MaxBodySize is the maximum number of response body bytes read for a single request.
+// Anything beyond this is discarded.
+const MaxBodySize = 1 << 20
+
+var ErrRequest = fmt.Errorf("http request")
+
+// Dialer makes connections to a target.
+// It is satisfied by *net.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Request is a single HTTP request sent to the target.
+type Request struct {
+	// Method is the HTTP method, for example GET.
+	Method string
+
+	// Path is the request path, including any query string.
+	Path string
+
+	// Header contains additional request headers.
+	Header http.Header
+
+	// Body is the (optional) request body.
+	Body []byte
+}
+
+// Response is the HTTP response to a single Request.
+// The body is read in full (up to MaxBodySize) before the response is returned.
+type Response struct {
+	// URL is the URL the request was sent to.
+	URL string
+
+	// StatusCode is the numeric HTTP status code, for example 200.
+	StatusCode int
+
+	// Status is the status line text, for example "200 OK".
+	Status string
+
+	// Proto is the HTTP version the server responded with, for example "HTTP/1.1".
+	Proto string
+
+	// Header contains the response headers.
+	Header http.Header
+
+	// Body is the response body, truncated to MaxBodySize.
+	Body []byte
+
+	// TLS contains the TLS connection state, if the request was made over HTTPS.
+	TLS *tls.ConnectionState
+}
+
+// Client sends HTTP requests to a single target.
+// Redirects are never followed automatically, it is up to the caller to decide how to handle them.
+type Client struct {
+	target string
+	scheme string
+	client *http.Client
+}
+
+// NewClient creates a Client for the target (host:port).
+// If secure is set, requests are made over HTTPS. Server certificates are not verified,
+// since the goal is to inspect the server and not to trust it.
+// The timeout applies to each individual request, 0 disables it.
+func NewClient(target string, secure bool, dialer Dialer, timeout time.Duration) *Client {
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	transport := &http.Transport{
+		DialContext: dialer.DialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true, //nolint:gosec
+		},
+		ForceAttemptHTTP2:  true,
+		DisableCompression: true,
+	}
+
+	return &Client{
+		target: target,
+		scheme: scheme,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Target returns the host:port the Client sends requests to.
+func (c *Client) Target() string {
+	return c.target
+}
+
+// URL returns the absolute URL for the given request path on the target.
+func (c *Client) URL(path string) string {
+	u := url.URL{
+		Scheme: c.scheme,
+		Host:   c.target,
+	}
+	return u.String() + path
+}
+
+// Get sends a GET request for the given path.
+func (c *Client) Get(ctx context.Context, path string) (*Response, error) {
+	return c.Do(ctx, &Request{
+		Method: http.MethodGet,
+		Path:   path,
+	})
+}
+
+// Do sends the request to the target.
+func (c *Client) Do(ctx context.Context, r *Request) (*Response, error) {
+	return c.DoURL(ctx, c.URL(r.Path), r)
+}
+
+// DoURL sends the request to the given absolute URL, ignoring the request path.
+// This is used to follow redirects which may change the scheme or port.
+func (c *Client) DoURL(ctx context.Context, rawURL string, r *Request) (*Response, error) {
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, rawURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequest, err)
+	}
+	for name, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	if host := r.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		return nil, fmt.Errorf("%w: %v", ErrRequest, err)
+	}
+	// Best effort close of body.
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	payload, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	if err != nil {
+		if isTimeout(err) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		return nil, fmt.Errorf("%w: %v", ErrRequest, err)
+	}
+
+	return &Response{
+		URL:        rawURL,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Proto:      resp.Proto,
+		Header:     resp.Header,
+		Body:       payload,
+		TLS:        resp.TLS,
+	}, nil
+}
+
+// Determines if the error was caused by a timeout, either from a deadline or the client's own timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
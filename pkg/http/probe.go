@@ -0,0 +1,291 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SecurityHeaderNames lists the response headers reported as security headers.
+var SecurityHeaderNames = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+	"X-XSS-Protection",
+	"Cross-Origin-Opener-Policy",
+	"Cross-Origin-Embedder-Policy",
+	"Cross-Origin-Resource-Policy",
+}
+
+// ProbeOptions configures the request sent by Probe.
+type ProbeOptions struct {
+	// Method is the HTTP method, defaults to GET.
+	Method string
+
+	// Path is the request path, defaults to "/".
+	Path string
+
+	// Header contains additional request headers.
+	Header http.Header
+
+	// MaxRedirects is the maximum number of redirects followed.
+	// Only redirects to the same host are followed.
+	MaxRedirects int
+}
+
+// Report contains the information gathered about an HTTP server.
+type Report struct {
+	// URL is the final URL after following redirects.
+	URL string `json:"url"`
+
+	// StatusCode is the final HTTP status code.
+	StatusCode int `json:"status_code"`
+
+	// Status is the final status line text.
+	Status string `json:"status"`
+
+	// Proto is the HTTP version the server responded with.
+	Proto string `json:"proto"`
+
+	// Server is the value of the Server header.
+	Server string `json:"server,omitempty"`
+
+	// PoweredBy is the value of the X-Powered-By header.
+	PoweredBy string `json:"powered_by,omitempty"`
+
+	// Title is the HTML document title, if any.
+	Title string `json:"title,omitempty"`
+
+	// Redirects contains each redirect response encountered, in order.
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// BodySize is the number of body bytes read (up to MaxBodySize).
+	BodySize int `json:"body_size"`
+
+	// BodySHA256 is the hex encoded SHA-256 digest of the body.
+	BodySHA256 string `json:"body_sha256"`
+
+	// FaviconHash is the hash of /favicon.ico, see FaviconHash.
+	// Omitted if the server has no favicon.
+	FaviconHash *int32 `json:"favicon_hash,omitempty"`
+
+	// SecurityHeaders contains the security headers set by the server.
+	SecurityHeaders map[string]string `json:"security_headers"`
+
+	// MissingSecurityHeaders lists the security headers not set by the server.
+	MissingSecurityHeaders []string `json:"missing_security_headers"`
+}
+
+// Redirect is a single hop of a redirect chain.
+type Redirect struct {
+	// URL is the URL which responded with the redirect.
+	URL string `json:"url"`
+
+	// StatusCode is the redirect HTTP status code, for example 301.
+	StatusCode int `json:"status_code"`
+
+	// Location is the value of the Location header.
+	Location string `json:"location"`
+}
+
+// Probe sends the configured request to the target and reports on the response.
+// Same-host redirects are followed up to the configured maximum, after which the favicon is fetched.
+func Probe(ctx context.Context, c *Client, opts *ProbeOptions) (*Report, error) {
+	req := &Request{
+		Method: opts.Method,
+		Path:   opts.Path,
+		Header: opts.Header,
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if req.Path == "" {
+		req.Path = "/"
+	}
+
+	report := &Report{}
+
+	// (1) Send the Request, Following Redirects
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < opts.MaxRedirects && isRedirect(resp.StatusCode); i++ {
+		location := resp.Header.Get("Location")
+		report.Redirects = append(report.Redirects, Redirect{
+			URL:        resp.URL,
+			StatusCode: resp.StatusCode,
+			Location:   location,
+		})
+
+		next, ok := resolveRedirect(resp.URL, location)
+		if !ok {
+			break
+		}
+
+		req = redirectRequest(req, resp.StatusCode)
+		resp, err = c.DoURL(ctx, next, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// (2) Inspect the Final Response
+
+	digest := sha256.Sum256(resp.Body)
+
+	report.URL = resp.URL
+	report.StatusCode = resp.StatusCode
+	report.Status = resp.Status
+	report.Proto = resp.Proto
+	report.Server = resp.Header.Get("Server")
+	report.PoweredBy = resp.Header.Get("X-Powered-By")
+	report.Title = parseTitle(resp.Body)
+	report.BodySize = len(resp.Body)
+	report.BodySHA256 = hex.EncodeToString(digest[:])
+	report.SecurityHeaders = map[string]string{}
+	report.MissingSecurityHeaders = []string{}
+
+	for _, name := range SecurityHeaderNames {
+		if v := resp.Header.Get(name); v != "" {
+			report.SecurityHeaders[name] = v
+		} else {
+			report.MissingSecurityHeaders = append(report.MissingSecurityHeaders, name)
+		}
+	}
+
+	// (3) Fetch the Favicon
+	//		Failing to fetch the favicon is not fatal, it is simply left out of the report.
+
+	icon, err := c.Get(ctx, "/favicon.ico")
+	if err == nil && icon.StatusCode == http.StatusOK && len(icon.Body) > 0 {
+		hash := FaviconHash(icon.Body)
+		report.FaviconHash = &hash
+	}
+
+	return report, nil
+}
+
+// Determines if the status code is a redirect that carries a Location header.
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the request to send to the location of a redirect, following net/http: a 303 is followed with GET
+// (unless the request is HEAD), as is a 301 or 302 after a POST. Only 307 and 308 resend the request body.
+func redirectRequest(req *Request, code int) *Request {
+	if code == http.StatusTemporaryRedirect || code == http.StatusPermanentRedirect {
+		return req
+	}
+
+	next := *req
+	next.Body = nil
+	if (code == http.StatusSeeOther && req.Method != http.MethodHead) ||
+		((code == http.StatusMovedPermanently || code == http.StatusFound) && req.Method == http.MethodPost) {
+		next.Method = http.MethodGet
+	}
+	return &next
+}
+
+// Resolves the redirect location against the URL which issued it.
+// Redirects are only followed if they stay on the same host, the scanner never wanders off the target.
+func resolveRedirect(from, location string) (string, bool) {
+	if location == "" {
+		return "", false
+	}
+
+	base, err := url.Parse(from)
+	if err != nil {
+		return "", false
+	}
+	next, err := base.Parse(location)
+	if err != nil {
+		return "", false
+	}
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return "", false
+	}
+	if !strings.EqualFold(hostname(next), hostname(base)) {
+		return "", false
+	}
+
+	return next.String(), true
+}
+
+// Returns the host of the URL without any port.
+func hostname(u *url.URL) string {
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return host
+	}
+	return u.Host
+}
+
+// Extracts the text of the first <title> element of an HTML document.
+// An empty string is returned if the document has no title.
+func parseTitle(body []byte) string {
+	lower := asciiLower(body)
+
+	start := bytes.Index(lower, []byte("<title"))
+	if start < 0 {
+		return ""
+	}
+	open := bytes.IndexByte(lower[start:], '>')
+	if open < 0 {
+		return ""
+	}
+	start += open + 1
+
+	end := bytes.Index(lower[start:], []byte("</title"))
+	if end < 0 {
+		return ""
+	}
+
+	title := html.UnescapeString(string(body[start : start+end]))
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// Returns a copy of b with only the ASCII letters A-Z lowercased. Unlike bytes.ToLower, which replaces invalid
+// UTF-8 (for example Latin-1 pages) with multi-byte runes, every offset into the copy is also an offset into b.
+func asciiLower(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"no title", []byte("<html><body>Hi</body></html>"), ""},
+		{"simple", []byte("<html><head><title>Hello</title></head></html>"), "Hello"},
+		{"upper case", []byte("<HTML><TITLE>Hello</TITLE></HTML>"), "Hello"},
+		{"attributes", []byte(`<title lang="en">Hello</title>`), "Hello"},
+		{"whitespace", []byte("<title>\n  Hello \t World\n</title>"), "Hello World"},
+		{"entities", []byte("<title>Tom &amp; Jerry</title>"), "Tom & Jerry"},
+		{"unterminated", []byte("<title>Hello"), ""},
+		{"latin-1 before", append(bytes.Repeat([]byte{0xE9}, 40), "<title>Hi</title>"...), "Hi"},
+		{"latin-1 title", []byte("<TITLE>Caf\xE9 \xC0 la carte</TITLE>"), "Caf\xE9 \xC0 la carte"},
+		{"latin-1 after", append([]byte("<title>Hi</title>"), bytes.Repeat([]byte{0xC9}, 40)...), "Hi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTitle(tt.body); got != tt.want {
+				t.Errorf("parseTitle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectRequest(t *testing.T) {
+	tests := []struct {
+		method     string
+		code       int
+		wantMethod string
+		wantBody   bool
+	}{
+		{http.MethodGet, http.StatusMovedPermanently, http.MethodGet, false},
+		{http.MethodPost, http.StatusMovedPermanently, http.MethodGet, false},
+		{http.MethodPost, http.StatusFound, http.MethodGet, false},
+		{http.MethodPut, http.StatusFound, http.MethodPut, false},
+		{http.MethodPut, http.StatusSeeOther, http.MethodGet, false},
+		{http.MethodHead, http.StatusSeeOther, http.MethodHead, false},
+		{http.MethodPost, http.StatusTemporaryRedirect, http.MethodPost, true},
+		{http.MethodPost, http.StatusPermanentRedirect, http.MethodPost, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+http.StatusText(tt.code), func(t *testing.T) {
+			req := &Request{Method: tt.method, Path: "/", Body: []byte("body")}
+
+			got := redirectRequest(req, tt.code)
+			if got.Method != tt.wantMethod {
+				t.Errorf("method = %s, want %s", got.Method, tt.wantMethod)
+			}
+			if (got.Body != nil) != tt.wantBody {
+				t.Errorf("body = %q, want body %v", got.Body, tt.wantBody)
+			}
+			if req.Method != tt.method || req.Body == nil {
+				t.Errorf("original request was modified")
+			}
+		})
+	}
+}
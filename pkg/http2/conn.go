@@ -0,0 +1,244 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Receive window advertised for each stream and the connection, large enough that no WINDOW_UPDATE
+// needs to be sent for the responses read.
+const receiveWindow = 1 << 20
+
+// Default initial window size, before the client's SETTINGS apply.
+const defaultWindow = 65535
+
+// Maximum number of frames read while waiting for a single response.
+const maxFrames = 1024
+
+// Conn is a minimal client connection, sending one request at a time.
+type Conn struct {
+	rw           io.ReadWriter
+	decoder      *Decoder
+	nextStreamID uint32
+
+	// Settings contains the parameters of the server's first SETTINGS frame.
+	Settings Settings
+}
+
+// Response is the response to a request on a single stream.
+type Response struct {
+	// Header contains the response header fields, including the :status pseudo-header.
+	Header []HeaderField
+
+	// Body is the concatenated payload of the response's DATA frames.
+	Body []byte
+
+	// Trailer contains the trailing header fields, if the response had any.
+	Trailer []HeaderField
+}
+
+// Get returns the value of the first header or trailer field with the given name.
+func (r *Response) Get(name string) string {
+	for _, fields := range [][]HeaderField{r.Header, r.Trailer} {
+		for _, f := range fields {
+			if f.Name == name {
+				return f.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Handshake sends the client connection preface and SETTINGS, then reads the server's SETTINGS, which must be
+// the first frame the server sends.
+//
+// See https://tools.ietf.org/html/rfc7540#section-3.5
+func Handshake(rw io.ReadWriter) (*Conn, error) {
+	// (1) Client Preface, SETTINGS and Connection Window
+
+	increment := make([]byte, 4)
+	binary.BigEndian.PutUint32(increment, receiveWindow-defaultWindow)
+
+	buf := []byte(Preface)
+	buf = append(buf, EncodeFrame(FrameSettings, 0, 0, EncodeSettings(Settings{
+		{ID: SettingEnablePush, Value: 0},
+		{ID: SettingInitialWindowSize, Value: receiveWindow},
+	}))...)
+	buf = append(buf, EncodeFrame(FrameWindowUpdate, 0, 0, increment)...)
+	if _, err := rw.Write(buf); err != nil {
+		return nil, err
+	}
+
+	// (2) Server SETTINGS
+
+	f, err := ReadFrame(rw, DefaultMaxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != FrameSettings || f.Has(FlagAck) || f.StreamID != 0 {
+		return nil, fmt.Errorf("%w: expected settings, got %s frame", ErrFrameDecode, f.Type)
+	}
+	settings, err := DecodeSettings(f.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{
+		rw:           rw,
+		decoder:      NewDecoder(),
+		nextStreamID: 1,
+		Settings:     settings,
+	}
+	if err := c.write(FrameSettings, FlagAck, 0, nil); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// RoundTrip sends a request on a new stream and reads the complete response. The header fields must include
+// the request pseudo-headers (:method, :scheme, :authority and :path).
+func (c *Conn) RoundTrip(header []HeaderField, body []byte) (*Response, error) {
+	streamID := c.nextStreamID
+	c.nextStreamID += 2
+
+	// (1) Request HEADERS and DATA
+	//	Requests are small enough to fit a single frame of the minimum maximum frame size.
+
+	flags := uint8(FlagEndHeaders)
+	if body == nil {
+		flags |= FlagEndStream
+	}
+	if err := c.write(FrameHeaders, flags, streamID, EncodeHeaders(header)); err != nil {
+		return nil, err
+	}
+	if body != nil {
+		if len(body) > DefaultMaxFrameSize {
+			return nil, fmt.Errorf("http/2: request body exceeds maximum frame size")
+		}
+		if err := c.write(FrameData, FlagEndStream, streamID, body); err != nil {
+			return nil, err
+		}
+	}
+
+	// (2) Response Frames
+
+	resp := &Response{}
+
+	// Header block being reassembled from a HEADERS frame and its CONTINUATION frames.
+	// The END_STREAM flag is carried on the HEADERS frame, but only applies once the block is complete.
+	var block []byte
+	blockStreamID := uint32(0)
+	blockEndStream := false
+
+	for i := 0; i < maxFrames; i++ {
+		f, err := ReadFrame(c.rw, DefaultMaxFrameSize)
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.Type {
+		case FrameSettings:
+			if !f.Has(FlagAck) {
+				if err := c.write(FrameSettings, FlagAck, 0, nil); err != nil {
+					return nil, err
+				}
+			}
+
+		case FramePing:
+			if !f.Has(FlagAck) {
+				if err := c.write(FramePing, FlagAck, 0, f.Payload); err != nil {
+					return nil, err
+				}
+			}
+
+		case FrameGoAway:
+			goAway, err := DecodeGoAway(f.Payload)
+			if err != nil {
+				return nil, err
+			}
+			return nil, goAway
+
+		case FrameRSTStream:
+			if f.StreamID == streamID && len(f.Payload) >= 4 {
+				return nil, &StreamError{StreamID: streamID, Code: ErrorCode(binary.BigEndian.Uint32(f.Payload))}
+			}
+
+		case FrameHeaders, FrameContinuation:
+			// Header blocks are decoded even for other streams, to keep the dynamic table in sync.
+			data := f.Payload
+			if f.Type == FrameHeaders {
+				if data, err = f.Data(); err != nil {
+					return nil, err
+				}
+				blockStreamID = f.StreamID
+				blockEndStream = f.Has(FlagEndStream)
+				block = nil
+			}
+			block = append(block, data...)
+			if !f.Has(FlagEndHeaders) {
+				continue
+			}
+
+			fields, err := c.decoder.Decode(block)
+			if err != nil {
+				return nil, err
+			}
+			if blockStreamID != streamID {
+				continue
+			}
+			if resp.Header == nil {
+				resp.Header = fields
+			} else {
+				resp.Trailer = fields
+			}
+			if blockEndStream {
+				return resp, nil
+			}
+
+		case FrameData:
+			if f.StreamID != streamID {
+				continue
+			}
+			data, err := f.Data()
+			if err != nil {
+				return nil, err
+			}
+			if len(resp.Body)+len(data) > receiveWindow {
+				return nil, fmt.Errorf("%w: response body exceeds receive window", ErrFrameDecode)
+			}
+			resp.Body = append(resp.Body, data...)
+			if f.Has(FlagEndStream) {
+				return resp, nil
+			}
+
+		default:
+			// WINDOW_UPDATE, PRIORITY and unknown frames are ignored.
+		}
+	}
+
+	return nil, fmt.Errorf("%w: too many frames without the response ending", ErrFrameDecode)
+}
+
+// Writes a single frame.
+func (c *Conn) write(t FrameType, flags uint8, streamID uint32, payload []byte) error {
+	_, err := c.rw.Write(EncodeFrame(t, flags, streamID, payload))
+	return err
+}
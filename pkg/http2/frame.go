@@ -0,0 +1,232 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package http2 provides a minimal HTTP/2 frame codec and client connection, sufficient for probing servers
+// and making simple requests (such as gRPC calls) without relying on an outside implementation.
+package http2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrFrameDecode = fmt.Errorf("frame decode")
+var ErrFrameTruncated = fmt.Errorf("%w: truncated frame or connection is not http/2", ErrFrameDecode)
+
+// Preface is the client connection preface, sent before the client's first SETTINGS frame.
+const Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// FrameType identifies the type of a frame.
+type FrameType uint8
+
+// Frame Types
+const (
+	FrameData         FrameType = 0x0
+	FrameHeaders      FrameType = 0x1
+	FramePriority     FrameType = 0x2
+	FrameRSTStream    FrameType = 0x3
+	FrameSettings     FrameType = 0x4
+	FramePushPromise  FrameType = 0x5
+	FramePing         FrameType = 0x6
+	FrameGoAway       FrameType = 0x7
+	FrameWindowUpdate FrameType = 0x8
+	FrameContinuation FrameType = 0x9
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FrameData:
+		return "DATA"
+	case FrameHeaders:
+		return "HEADERS"
+	case FramePriority:
+		return "PRIORITY"
+	case FrameRSTStream:
+		return "RST_STREAM"
+	case FrameSettings:
+		return "SETTINGS"
+	case FramePushPromise:
+		return "PUSH_PROMISE"
+	case FramePing:
+		return "PING"
+	case FrameGoAway:
+		return "GOAWAY"
+	case FrameWindowUpdate:
+		return "WINDOW_UPDATE"
+	case FrameContinuation:
+		return "CONTINUATION"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(t))
+	}
+}
+
+// Frame Flags
+const (
+	FlagEndStream  = 0x01
+	FlagAck        = 0x01
+	FlagEndHeaders = 0x04
+	FlagPadded     = 0x08
+	FlagPriority   = 0x20
+)
+
+// Size of the frame header.
+const frameHeaderSize = 9
+
+// Default maximum frame payload size, which every endpoint must accept.
+const DefaultMaxFrameSize = 16384
+
+// Frame is a single HTTP/2 frame.
+//
+// See https://tools.ietf.org/html/rfc7540#section-4.1
+type Frame struct {
+	Type     FrameType
+	Flags    uint8
+	StreamID uint32
+	Payload  []byte
+}
+
+// Has determines if the frame has the given flag set.
+func (f *Frame) Has(flag uint8) bool {
+	return f.Flags&flag == flag
+}
+
+// EncodeFrame encodes a frame.
+func EncodeFrame(t FrameType, flags uint8, streamID uint32, payload []byte) []byte {
+	// Frame Header
+	//	3 Bytes: Length
+	//	1 Byte: Type
+	//	1 Byte: Flags
+	//	4 Bytes: Reserved (1 bit), Stream Identifier (31 bits)
+
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	buf[0] = byte(len(payload) >> 16)
+	buf[1] = byte(len(payload) >> 8)
+	buf[2] = byte(len(payload))
+	buf[3] = byte(t)
+	buf[4] = flags
+	binary.BigEndian.PutUint32(buf[5:], streamID&0x7FFFFFFF)
+	return append(buf, payload...)
+}
+
+// ReadFrame reads a single frame, accepting payloads up to the given maximum size.
+func ReadFrame(r io.Reader, maxSize uint32) (*Frame, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	length := uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+	if length > maxSize {
+		if string(header[:4]) == "HTTP" {
+			return nil, fmt.Errorf("%w: server responded over http/1.x", ErrFrameDecode)
+		}
+		return nil, fmt.Errorf("%w: frame length %d exceeds maximum", ErrFrameDecode, length)
+	}
+
+	f := &Frame{
+		Type:     FrameType(header[3]),
+		Flags:    header[4],
+		StreamID: binary.BigEndian.Uint32(header[5:]) & 0x7FFFFFFF,
+		Payload:  make([]byte, length),
+	}
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	return f, nil
+}
+
+// Data returns the payload of a DATA, HEADERS or PUSH_PROMISE frame without any padding or priority fields.
+func (f *Frame) Data() ([]byte, error) {
+	payload := f.Payload
+
+	padding := 0
+	if f.Has(FlagPadded) && (f.Type == FrameData || f.Type == FrameHeaders || f.Type == FramePushPromise) {
+		if len(payload) < 1 {
+			return nil, ErrFrameTruncated
+		}
+		padding = int(payload[0])
+		payload = payload[1:]
+	}
+	if f.Type == FrameHeaders && f.Has(FlagPriority) {
+		// 4 Bytes: Exclusive (1 bit), Stream Dependency (31 bits)
+		// 1 Byte: Weight
+		if len(payload) < 5 {
+			return nil, ErrFrameTruncated
+		}
+		payload = payload[5:]
+	}
+
+	if padding > len(payload) {
+		return nil, fmt.Errorf("%w: padding exceeds payload", ErrFrameDecode)
+	}
+	return payload[:len(payload)-padding], nil
+}
+
+// ErrorCode is an RST_STREAM or GOAWAY error code.
+type ErrorCode uint32
+
+func (c ErrorCode) String() string {
+	names := []string{
+		"NO_ERROR", "PROTOCOL_ERROR", "INTERNAL_ERROR", "FLOW_CONTROL_ERROR", "SETTINGS_TIMEOUT", "STREAM_CLOSED",
+		"FRAME_SIZE_ERROR", "REFUSED_STREAM", "CANCEL", "COMPRESSION_ERROR", "CONNECT_ERROR", "ENHANCE_YOUR_CALM",
+		"INADEQUATE_SECURITY", "HTTP_1_1_REQUIRED",
+	}
+	if int(c) < len(names) {
+		return names[c]
+	}
+	return fmt.Sprintf("UNKNOWN(0x%02x)", uint32(c))
+}
+
+// GoAwayError is returned when the server sends a GOAWAY frame, closing the connection.
+type GoAwayError struct {
+	Code  ErrorCode
+	Debug string
+}
+
+func (e *GoAwayError) Error() string {
+	if e.Debug != "" {
+		return fmt.Sprintf("http/2 goaway: %s: %s", e.Code, e.Debug)
+	}
+	return fmt.Sprintf("http/2 goaway: %s", e.Code)
+}
+
+// StreamError is returned when the server resets a stream with an RST_STREAM frame.
+type StreamError struct {
+	StreamID uint32
+	Code     ErrorCode
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("http/2 stream %d reset: %s", e.StreamID, e.Code)
+}
+
+// DecodeGoAway decodes the payload of a GOAWAY frame.
+func DecodeGoAway(payload []byte) (*GoAwayError, error) {
+	// 4 Bytes: Last Stream ID
+	// 4 Bytes: Error Code
+	// n Bytes: Additional Debug Data
+	if len(payload) < 8 {
+		return nil, ErrFrameTruncated
+	}
+	return &GoAwayError{
+		Code:  ErrorCode(binary.BigEndian.Uint32(payload[4:])),
+		Debug: string(payload[8:]),
+	}, nil
+}
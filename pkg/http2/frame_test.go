@@ -0,0 +1,272 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      FrameType
+		flags    uint8
+		streamID uint32
+		payload  []byte
+		want     []byte
+	}{
+		{
+			"empty settings ack", FrameSettings, FlagAck, 0, nil,
+			[]byte{0x00, 0x00, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			"data", FrameData, FlagEndStream, 1, []byte("hello"),
+			[]byte{0x00, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 'h', 'e', 'l', 'l', 'o'},
+		},
+		{
+			"reserved bit cleared", FramePing, 0, 0x80000003, nil,
+			[]byte{0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x03},
+		},
+		{
+			"24-bit length", FrameData, 0, 5, make([]byte, 0x010203),
+			append([]byte{0x01, 0x02, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05}, make([]byte, 0x010203)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeFrame(tt.typ, tt.flags, tt.streamID, tt.payload); !bytes.Equal(got, tt.want) {
+				t.Errorf("EncodeFrame() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		maxSize uint32
+		want    *Frame
+		wantErr error
+	}{
+		{
+			"settings",
+			EncodeFrame(FrameSettings, 0, 0, []byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x64}),
+			DefaultMaxFrameSize,
+			&Frame{Type: FrameSettings, StreamID: 0, Payload: []byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x64}},
+			nil,
+		},
+		{
+			"headers",
+			EncodeFrame(FrameHeaders, FlagEndHeaders|FlagEndStream, 3, []byte{0x88}),
+			DefaultMaxFrameSize,
+			&Frame{Type: FrameHeaders, Flags: FlagEndHeaders | FlagEndStream, StreamID: 3, Payload: []byte{0x88}},
+			nil,
+		},
+		{
+			"reserved bit ignored",
+			[]byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x80, 0x00, 0x00, 0x07},
+			DefaultMaxFrameSize,
+			&Frame{Type: FrameWindowUpdate, StreamID: 7, Payload: []byte{}},
+			nil,
+		},
+		{
+			"unknown type",
+			EncodeFrame(FrameType(0xFA), 0, 0, []byte{0x01}),
+			DefaultMaxFrameSize,
+			&Frame{Type: FrameType(0xFA), Payload: []byte{0x01}},
+			nil,
+		},
+		{
+			"empty",
+			nil,
+			DefaultMaxFrameSize,
+			nil,
+			ErrFrameTruncated,
+		},
+		{
+			"truncated header",
+			[]byte{0x00, 0x00, 0x00, 0x04},
+			DefaultMaxFrameSize,
+			nil,
+			ErrFrameTruncated,
+		},
+		{
+			"truncated payload",
+			EncodeFrame(FrameData, 0, 1, []byte("hello"))[:12],
+			DefaultMaxFrameSize,
+			nil,
+			ErrFrameTruncated,
+		},
+		{
+			"exceeds maximum",
+			EncodeFrame(FrameData, 0, 1, []byte("hello")),
+			4,
+			nil,
+			ErrFrameDecode,
+		},
+		{
+			"http/1.x response",
+			[]byte("HTTP/1.1 400 Bad Request\r\n\r\n"),
+			DefaultMaxFrameSize,
+			nil,
+			ErrFrameDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFrame(bytes.NewReader(tt.input), tt.maxSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadFrame() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFrame() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrameSequence(t *testing.T) {
+	frames := []*Frame{
+		{Type: FrameSettings, Payload: EncodeSettings(Settings{{ID: SettingMaxFrameSize, Value: 16384}})},
+		{Type: FrameSettings, Flags: FlagAck, Payload: []byte{}},
+		{Type: FrameHeaders, Flags: FlagEndHeaders, StreamID: 1, Payload: []byte{0x88}},
+		{Type: FrameData, Flags: FlagEndStream, StreamID: 1, Payload: []byte("body")},
+	}
+
+	var buf bytes.Buffer
+	for _, f := range frames {
+		buf.Write(EncodeFrame(f.Type, f.Flags, f.StreamID, f.Payload))
+	}
+
+	for i, want := range frames {
+		got, err := ReadFrame(&buf, DefaultMaxFrameSize)
+		if err != nil {
+			t.Fatalf("ReadFrame() #%d error = %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadFrame() #%d = %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := ReadFrame(&buf, DefaultMaxFrameSize); !errors.Is(err, ErrFrameTruncated) {
+		t.Errorf("ReadFrame() at end error = %v, want %v", err, ErrFrameTruncated)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unread bytes: %d", buf.Len())
+	}
+}
+
+func TestFrameData(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   Frame
+		want    []byte
+		wantErr error
+	}{
+		{
+			"data",
+			Frame{Type: FrameData, Payload: []byte("hello")},
+			[]byte("hello"),
+			nil,
+		},
+		{
+			"padded data",
+			Frame{Type: FrameData, Flags: FlagPadded, Payload: []byte("\x03hello\x00\x00\x00")},
+			[]byte("hello"),
+			nil,
+		},
+		{
+			"headers with priority",
+			Frame{Type: FrameHeaders, Flags: FlagPriority, Payload: []byte("\x80\x00\x00\x01\x0f\x88")},
+			[]byte{0x88},
+			nil,
+		},
+		{
+			"padded headers with priority",
+			Frame{Type: FrameHeaders, Flags: FlagPadded | FlagPriority, Payload: []byte("\x02\x00\x00\x00\x01\x0f\x88\x00\x00")},
+			[]byte{0x88},
+			nil,
+		},
+		{
+			"priority flag ignored on data",
+			Frame{Type: FrameData, Flags: FlagPriority, Payload: []byte("hello")},
+			[]byte("hello"),
+			nil,
+		},
+		{
+			"missing pad length",
+			Frame{Type: FrameData, Flags: FlagPadded, Payload: []byte{}},
+			nil,
+			ErrFrameTruncated,
+		},
+		{
+			"truncated priority",
+			Frame{Type: FrameHeaders, Flags: FlagPriority, Payload: []byte{0x00, 0x00}},
+			nil,
+			ErrFrameTruncated,
+		},
+		{
+			"padding exceeds payload",
+			Frame{Type: FrameData, Flags: FlagPadded, Payload: []byte("\x09hello")},
+			nil,
+			ErrFrameDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.frame.Data()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Data() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Data() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeGoAway(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+		wantErr error
+	}{
+		{"no debug data", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, "http/2 goaway: PROTOCOL_ERROR", nil},
+		{"debug data", []byte("\x00\x00\x00\x01\x00\x00\x00\x0dHTTP/1.1 only"), "http/2 goaway: HTTP_1_1_REQUIRED: HTTP/1.1 only", nil},
+		{"unknown code", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF}, "http/2 goaway: UNKNOWN(0xff)", nil},
+		{"truncated", []byte{0x00, 0x00, 0x00, 0x00}, "", ErrFrameTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeGoAway(tt.payload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeGoAway() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Error() != tt.want {
+				t.Errorf("DecodeGoAway() = %q, want %q", got.Error(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"fmt"
+)
+
+var ErrHeaderDecode = fmt.Errorf("header decode")
+var ErrHeaderTruncated = fmt.Errorf("%w: truncated header block", ErrHeaderDecode)
+
+// HeaderField is a single header name and value.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// Default size of the HPACK dynamic table, until changed by the encoder.
+const defaultHeaderTableSize = 4096
+
+// Size overhead of each dynamic table entry.
+const entryOverhead = 32
+
+// HPACK static table, indexed from 1.
+//
+// See https://tools.ietf.org/html/rfc7541#appendix-A
+var staticTable = []HeaderField{
+	{":authority", ""},
+	{":method", "GET"},
+	{":method", "POST"},
+	{":path", "/"},
+	{":path", "/index.html"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "200"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "304"},
+	{":status", "400"},
+	{":status", "404"},
+	{":status", "500"},
+	{"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"},
+	{"accept-language", ""},
+	{"accept-ranges", ""},
+	{"accept", ""},
+	{"access-control-allow-origin", ""},
+	{"age", ""},
+	{"allow", ""},
+	{"authorization", ""},
+	{"cache-control", ""},
+	{"content-disposition", ""},
+	{"content-encoding", ""},
+	{"content-language", ""},
+	{"content-length", ""},
+	{"content-location", ""},
+	{"content-range", ""},
+	{"content-type", ""},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"expect", ""},
+	{"expires", ""},
+	{"from", ""},
+	{"host", ""},
+	{"if-match", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"if-range", ""},
+	{"if-unmodified-since", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"max-forwards", ""},
+	{"proxy-authenticate", ""},
+	{"proxy-authorization", ""},
+	{"range", ""},
+	{"referer", ""},
+	{"refresh", ""},
+	{"retry-after", ""},
+	{"server", ""},
+	{"set-cookie", ""},
+	{"strict-transport-security", ""},
+	{"transfer-encoding", ""},
+	{"user-agent", ""},
+	{"vary", ""},
+	{"via", ""},
+	{"www-authenticate", ""},
+}
+
+// EncodeHeaders encodes a header block. Every field is encoded as a literal without indexing and without
+// Huffman encoding, so the encoder needs no state and the decoder's dynamic table is never used.
+func EncodeHeaders(fields []HeaderField) []byte {
+	// Literal Header Field without Indexing, New Name
+	//	1 Byte: 0x00
+	//	String: Name (H bit and 7-bit prefix length)
+	//	String: Value (H bit and 7-bit prefix length)
+
+	var buf []byte
+	for _, f := range fields {
+		buf = append(buf, 0x00)
+		buf = appendInt(buf, 7, 0x00, uint64(len(f.Name)))
+		buf = append(buf, f.Name...)
+		buf = appendInt(buf, 7, 0x00, uint64(len(f.Value)))
+		buf = append(buf, f.Value...)
+	}
+	return buf
+}
+
+// Decoder decodes HPACK header blocks, maintaining the dynamic table across the blocks of a connection.
+//
+// See https://tools.ietf.org/html/rfc7541
+type Decoder struct {
+	dynamic []HeaderField // newest first
+	size    int
+	maxSize int
+}
+
+// NewDecoder creates a Decoder with the default dynamic table size.
+func NewDecoder() *Decoder {
+	return &Decoder{
+		maxSize: defaultHeaderTableSize,
+	}
+}
+
+// Decode decodes a complete header block.
+func (d *Decoder) Decode(b []byte) ([]HeaderField, error) {
+	var fields []HeaderField
+
+	for len(b) > 0 {
+		var err error
+		switch {
+		// Indexed Header Field
+		//	1xxxxxxx: Index (7-bit prefix)
+		case b[0]&0x80 != 0:
+			var index uint64
+			if index, b, err = readInt(b, 7); err != nil {
+				return nil, err
+			}
+			f, err := d.lookup(index)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+
+		// Literal Header Field with Incremental Indexing
+		//	01xxxxxx: Index of Name (6-bit prefix), 0 for a new name
+		case b[0]&0xC0 == 0x40:
+			var f HeaderField
+			if f, b, err = d.readLiteral(b, 6); err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			d.add(f)
+
+		// Dynamic Table Size Update
+		//	001xxxxx: Maximum Size (5-bit prefix)
+		case b[0]&0xE0 == 0x20:
+			var size uint64
+			if size, b, err = readInt(b, 5); err != nil {
+				return nil, err
+			}
+			if size > defaultHeaderTableSize {
+				return nil, fmt.Errorf("%w: dynamic table size %d exceeds maximum", ErrHeaderDecode, size)
+			}
+			d.maxSize = int(size)
+			d.evict()
+
+		// Literal Header Field without Indexing, or Never Indexed
+		//	0000xxxx or 0001xxxx: Index of Name (4-bit prefix), 0 for a new name
+		default:
+			var f HeaderField
+			if f, b, err = d.readLiteral(b, 4); err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	return fields, nil
+}
+
+// Reads a literal header field whose name index has the given prefix size.
+func (d *Decoder) readLiteral(b []byte, prefix uint) (HeaderField, []byte, error) {
+	index, b, err := readInt(b, prefix)
+	if err != nil {
+		return HeaderField{}, nil, err
+	}
+
+	var f HeaderField
+	if index == 0 {
+		if f.Name, b, err = readString(b); err != nil {
+			return HeaderField{}, nil, err
+		}
+	} else {
+		named, err := d.lookup(index)
+		if err != nil {
+			return HeaderField{}, nil, err
+		}
+		f.Name = named.Name
+	}
+
+	if f.Value, b, err = readString(b); err != nil {
+		return HeaderField{}, nil, err
+	}
+	return f, b, nil
+}
+
+// Looks up an index in the static table followed by the dynamic table.
+func (d *Decoder) lookup(index uint64) (HeaderField, error) {
+	switch {
+	case index == 0:
+		return HeaderField{}, fmt.Errorf("%w: index 0", ErrHeaderDecode)
+	case index <= uint64(len(staticTable)):
+		return staticTable[index-1], nil
+	case index-uint64(len(staticTable)) <= uint64(len(d.dynamic)):
+		return d.dynamic[index-uint64(len(staticTable))-1], nil
+	default:
+		return HeaderField{}, fmt.Errorf("%w: index %d out of range", ErrHeaderDecode, index)
+	}
+}
+
+// Adds a field to the dynamic table, evicting the oldest entries to make room.
+func (d *Decoder) add(f HeaderField) {
+	d.dynamic = append([]HeaderField{f}, d.dynamic...)
+	d.size += len(f.Name) + len(f.Value) + entryOverhead
+	d.evict()
+}
+
+// Evicts the oldest entries until the dynamic table fits its maximum size.
+func (d *Decoder) evict() {
+	for d.size > d.maxSize && len(d.dynamic) > 0 {
+		last := d.dynamic[len(d.dynamic)-1]
+		d.size -= len(last.Name) + len(last.Value) + entryOverhead
+		d.dynamic = d.dynamic[:len(d.dynamic)-1]
+	}
+}
+
+// Reads an integer with the given prefix size (in bits) from the first byte.
+//
+// See https://tools.ietf.org/html/rfc7541#section-5.1
+func readInt(b []byte, prefix uint) (uint64, []byte, error) {
+	if len(b) < 1 {
+		return 0, nil, ErrHeaderTruncated
+	}
+
+	max := uint64(1)<<prefix - 1
+	v := uint64(b[0]) & max
+	b = b[1:]
+	if v < max {
+		return v, b, nil
+	}
+
+	for shift := uint(0); ; shift += 7 {
+		if len(b) < 1 {
+			return 0, nil, ErrHeaderTruncated
+		}
+		if shift > 28 {
+			return 0, nil, fmt.Errorf("%w: integer overflow", ErrHeaderDecode)
+		}
+		v += uint64(b[0]&0x7F) << shift
+		more := b[0]&0x80 != 0
+		b = b[1:]
+		if !more {
+			return v, b, nil
+		}
+	}
+}
+
+// Appends an integer with the given prefix size, combined with the flags in the first byte.
+func appendInt(buf []byte, prefix uint, flags byte, v uint64) []byte {
+	max := uint64(1)<<prefix - 1
+	if v < max {
+		return append(buf, flags|byte(v))
+	}
+
+	buf = append(buf, flags|byte(max))
+	v -= max
+	for v >= 0x80 {
+		buf = append(buf, byte(v&0x7F)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// Reads a string literal, Huffman encoded if the H bit is set.
+//
+// See https://tools.ietf.org/html/rfc7541#section-5.2
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 1 {
+		return "", nil, ErrHeaderTruncated
+	}
+	huffman := b[0]&0x80 != 0
+
+	length, b, err := readInt(b, 7)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint64(len(b)) < length {
+		return "", nil, ErrHeaderTruncated
+	}
+	s, b := b[:length], b[length:]
+
+	if huffman {
+		decoded, err := huffmanDecode(s)
+		return decoded, b, err
+	}
+	return string(s), b, nil
+}
@@ -0,0 +1,472 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Decodes a hex dump as printed in RFC 7541, ignoring whitespace.
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// See https://tools.ietf.org/html/rfc7541#appendix-C.1
+func TestInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  uint
+		value   uint64
+		encoded []byte
+	}{
+		{"C.1.1 10 with 5-bit prefix", 5, 10, []byte{0x0A}},
+		{"C.1.2 1337 with 5-bit prefix", 5, 1337, []byte{0x1F, 0x9A, 0x0A}},
+		{"C.1.3 42 with 8-bit prefix", 8, 42, []byte{0x2A}},
+		{"prefix maximum", 7, 127, []byte{0x7F, 0x00}},
+		{"below prefix maximum", 7, 126, []byte{0x7E}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendInt(nil, tt.prefix, 0x00, tt.value); !bytes.Equal(got, tt.encoded) {
+				t.Errorf("appendInt() = %x, want %x", got, tt.encoded)
+			}
+
+			got, rest, err := readInt(tt.encoded, tt.prefix)
+			if err != nil {
+				t.Fatalf("readInt() error = %v", err)
+			}
+			if got != tt.value || len(rest) != 0 {
+				t.Errorf("readInt() = %d, %x, want %d", got, rest, tt.value)
+			}
+		})
+	}
+}
+
+func TestReadIntErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+		wantErr error
+	}{
+		{"empty", nil, ErrHeaderTruncated},
+		{"truncated continuation", []byte{0x1F, 0x9A}, ErrHeaderTruncated},
+		{"overflow", []byte{0x1F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, ErrHeaderDecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := readInt(tt.encoded, 5); !errors.Is(err, tt.wantErr) {
+				t.Errorf("readInt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A header block and the expected decoder state after decoding it.
+type hpackStep struct {
+	block   string
+	fields  []HeaderField
+	dynamic []HeaderField
+	size    int
+}
+
+// See https://tools.ietf.org/html/rfc7541#appendix-C.2 to C.6
+func TestDecoder(t *testing.T) {
+	date1 := "Mon, 21 Oct 2013 20:13:21 GMT"
+	date2 := "Mon, 21 Oct 2013 20:13:22 GMT"
+	cookie := "foo=ASDJKHQKBZXOQWEOPIUAXQWEOIU; max-age=3600; version=1"
+
+	requests := [][]HeaderField{
+		{
+			{":method", "GET"},
+			{":scheme", "http"},
+			{":path", "/"},
+			{":authority", "www.example.com"},
+		},
+		{
+			{":method", "GET"},
+			{":scheme", "http"},
+			{":path", "/"},
+			{":authority", "www.example.com"},
+			{"cache-control", "no-cache"},
+		},
+		{
+			{":method", "GET"},
+			{":scheme", "https"},
+			{":path", "/index.html"},
+			{":authority", "www.example.com"},
+			{"custom-key", "custom-value"},
+		},
+	}
+	requestTables := [][]HeaderField{
+		{
+			{":authority", "www.example.com"},
+		},
+		{
+			{"cache-control", "no-cache"},
+			{":authority", "www.example.com"},
+		},
+		{
+			{"custom-key", "custom-value"},
+			{"cache-control", "no-cache"},
+			{":authority", "www.example.com"},
+		},
+	}
+
+	responses := [][]HeaderField{
+		{
+			{":status", "302"},
+			{"cache-control", "private"},
+			{"date", date1},
+			{"location", "https://www.example.com"},
+		},
+		{
+			{":status", "307"},
+			{"cache-control", "private"},
+			{"date", date1},
+			{"location", "https://www.example.com"},
+		},
+		{
+			{":status", "200"},
+			{"cache-control", "private"},
+			{"date", date2},
+			{"location", "https://www.example.com"},
+			{"content-encoding", "gzip"},
+			{"set-cookie", cookie},
+		},
+	}
+	responseTables := [][]HeaderField{
+		{
+			{"location", "https://www.example.com"},
+			{"date", date1},
+			{"cache-control", "private"},
+			{":status", "302"},
+		},
+		{
+			{":status", "307"},
+			{"location", "https://www.example.com"},
+			{"date", date1},
+			{"cache-control", "private"},
+		},
+		{
+			{"set-cookie", cookie},
+			{"content-encoding", "gzip"},
+			{"date", date2},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		maxTableSize int
+		steps        []hpackStep
+	}{
+		{
+			"C.2.1 literal header field with indexing",
+			defaultHeaderTableSize,
+			[]hpackStep{{
+				"400a 6375 7374 6f6d 2d6b 6579 0d63 7573 746f 6d2d 6865 6164 6572",
+				[]HeaderField{{"custom-key", "custom-header"}},
+				[]HeaderField{{"custom-key", "custom-header"}},
+				55,
+			}},
+		},
+		{
+			"C.2.2 literal header field without indexing",
+			defaultHeaderTableSize,
+			[]hpackStep{{
+				"040c 2f73 616d 706c 652f 7061 7468",
+				[]HeaderField{{":path", "/sample/path"}},
+				nil,
+				0,
+			}},
+		},
+		{
+			"C.2.3 literal header field never indexed",
+			defaultHeaderTableSize,
+			[]hpackStep{{
+				"1008 7061 7373 776f 7264 0673 6563 7265 74",
+				[]HeaderField{{"password", "secret"}},
+				nil,
+				0,
+			}},
+		},
+		{
+			"C.2.4 indexed header field",
+			defaultHeaderTableSize,
+			[]hpackStep{{
+				"82",
+				[]HeaderField{{":method", "GET"}},
+				nil,
+				0,
+			}},
+		},
+		{
+			"C.3 requests without huffman coding",
+			defaultHeaderTableSize,
+			[]hpackStep{
+				{
+					"8286 8441 0f77 7777 2e65 7861 6d70 6c65 2e63 6f6d",
+					requests[0], requestTables[0], 57,
+				},
+				{
+					"8286 84be 5808 6e6f 2d63 6163 6865",
+					requests[1], requestTables[1], 110,
+				},
+				{
+					"8287 85bf 400a 6375 7374 6f6d 2d6b 6579 0c63 7573 746f 6d2d 7661 6c75 65",
+					requests[2], requestTables[2], 164,
+				},
+			},
+		},
+		{
+			"C.4 requests with huffman coding",
+			defaultHeaderTableSize,
+			[]hpackStep{
+				{
+					"8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff",
+					requests[0], requestTables[0], 57,
+				},
+				{
+					"8286 84be 5886 a8eb 1064 9cbf",
+					requests[1], requestTables[1], 110,
+				},
+				{
+					"8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf",
+					requests[2], requestTables[2], 164,
+				},
+			},
+		},
+		{
+			"C.5 responses without huffman coding",
+			256,
+			[]hpackStep{
+				{
+					`4803 3330 3258 0770 7269 7661 7465 611d
+					4d6f 6e2c 2032 3120 4f63 7420 3230 3133
+					2032 303a 3133 3a32 3120 474d 546e 1768
+					7474 7073 3a2f 2f77 7777 2e65 7861 6d70
+					6c65 2e63 6f6d`,
+					responses[0], responseTables[0], 222,
+				},
+				{
+					"4803 3330 37c1 c0bf",
+					responses[1], responseTables[1], 222,
+				},
+				{
+					`88c1 611d 4d6f 6e2c 2032 3120 4f63 7420
+					3230 3133 2032 303a 3133 3a32 3220 474d
+					54c0 5a04 677a 6970 7738 666f 6f3d 4153
+					444a 4b48 514b 425a 584f 5157 454f 5049
+					5541 5851 5745 4f49 553b 206d 6178 2d61
+					6765 3d33 3630 303b 2076 6572 7369 6f6e
+					3d31`,
+					responses[2], responseTables[2], 215,
+				},
+			},
+		},
+		{
+			"C.6 responses with huffman coding",
+			256,
+			[]hpackStep{
+				{
+					`4882 6402 5885 aec3 771a 4b61 96d0 7abe
+					9410 54d4 44a8 2005 9504 0b81 66e0 82a6
+					2d1b ff6e 919d 29ad 1718 63c7 8f0b 97c8
+					e9ae 82ae 43d3`,
+					responses[0], responseTables[0], 222,
+				},
+				{
+					"4883 640e ffc1 c0bf",
+					responses[1], responseTables[1], 222,
+				},
+				{
+					`88c1 6196 d07a be94 1054 d444 a820 0595
+					040b 8166 e084 a62d 1bff c05a 839b d9ab
+					77ad 94e7 821d d7f2 e6c7 b335 dfdf cd5b
+					3960 d5af 2708 7f36 72c1 ab27 0fb5 291f
+					9587 3160 65c0 03ed 4ee5 b106 3d50 07`,
+					responses[2], responseTables[2], 215,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder()
+			d.maxSize = tt.maxTableSize
+
+			for i, step := range tt.steps {
+				fields, err := d.Decode(unhex(t, step.block))
+				if err != nil {
+					t.Fatalf("Decode() #%d error = %v", i, err)
+				}
+				if !reflect.DeepEqual(fields, step.fields) {
+					t.Errorf("Decode() #%d = %v, want %v", i, fields, step.fields)
+				}
+				if len(d.dynamic) != 0 || len(step.dynamic) != 0 {
+					if !reflect.DeepEqual(d.dynamic, step.dynamic) {
+						t.Errorf("dynamic table #%d = %v, want %v", i, d.dynamic, step.dynamic)
+					}
+				}
+				if d.size != step.size {
+					t.Errorf("dynamic table size #%d = %d, want %d", i, d.size, step.size)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string
+		wantErr error
+	}{
+		{"index 0", "80", ErrHeaderDecode},
+		{"index out of range", "be", ErrHeaderDecode},
+		{"name index out of range", "7f01 0161", ErrHeaderDecode},
+		{"truncated name", "4005 6162", ErrHeaderTruncated},
+		{"truncated value", "0001 6103 62", ErrHeaderTruncated},
+		{"missing value", "0001 61", ErrHeaderTruncated},
+		{"table size exceeds maximum", "3fe2 1f", ErrHeaderDecode},
+		{"huffman eos", "0001 6184 ffff ffff", ErrHeaderDecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDecoder().Decode(unhex(t, tt.block)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecoderTableSizeUpdate(t *testing.T) {
+	d := NewDecoder()
+
+	// C.3.1 followed by a size update to 0, which empties the dynamic table.
+	if _, err := d.Decode(unhex(t, "8286 8441 0f77 7777 2e65 7861 6d70 6c65 2e63 6f6d")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Decode([]byte{0x20}); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.dynamic) != 0 || d.size != 0 {
+		t.Errorf("dynamic table = %v (%d), want empty", d.dynamic, d.size)
+	}
+	if _, err := d.Decode([]byte{0xBE}); !errors.Is(err, ErrHeaderDecode) {
+		t.Errorf("Decode() of evicted entry error = %v, want %v", err, ErrHeaderDecode)
+	}
+}
+
+func TestEncodeHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []HeaderField
+		encoded string
+	}{
+		{
+			"empty",
+			nil,
+			"",
+		},
+		{
+			"request",
+			[]HeaderField{
+				{":method", "POST"},
+				{":path", "/grpc.health.v1.Health/Check"},
+			},
+			"0007 3a6d 6574 686f 6404 504f 5354" +
+				"0005 3a70 6174 681c 2f67 7270 632e 6865 616c 7468 2e76 312e 4865 616c 7468 2f43 6865 636b",
+		},
+		{
+			"empty value",
+			[]HeaderField{{"te", ""}},
+			"0002 7465 00",
+		},
+		{
+			"long value",
+			[]HeaderField{{"x", strings.Repeat("a", 200)}},
+			"0001 787f 49" + strings.Repeat("61", 200),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := EncodeHeaders(tt.fields)
+			if want := unhex(t, tt.encoded); !bytes.Equal(encoded, want) {
+				t.Errorf("EncodeHeaders() = %x, want %x", encoded, want)
+			}
+
+			d := NewDecoder()
+			decoded, err := d.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.fields) {
+				t.Errorf("Decode() = %v, want %v", decoded, tt.fields)
+			}
+			if len(d.dynamic) != 0 {
+				t.Errorf("dynamic table = %v, want empty", d.dynamic)
+			}
+		})
+	}
+}
+
+// See https://tools.ietf.org/html/rfc7541#section-5.2
+func TestHuffmanDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    string
+		wantErr error
+	}{
+		{"empty", "", "", nil},
+		{"C.4.1 authority", "f1e3 c2e5 f23a 6ba0 ab90 f4ff", "www.example.com", nil},
+		{"C.4.2 cache-control", "a8eb 1064 9cbf", "no-cache", nil},
+		{"C.4.3 custom-key", "25a8 49e9 5ba9 7d7f", "custom-key", nil},
+		{"C.6.1 status", "6402", "302", nil},
+		{"padded", "1f", "a", nil},
+		{"padding not eos", "18", "", ErrHeaderDecode},
+		{"padding too long", "1fff", "", ErrHeaderDecode},
+		{"eos", "ffff ffff", "", ErrHeaderDecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := huffmanDecode(unhex(t, tt.encoded))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("huffmanDecode() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("huffmanDecode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// SettingID identifies a SETTINGS parameter.
+type SettingID uint16
+
+// Setting Parameters
+const (
+	SettingHeaderTableSize       SettingID = 0x1
+	SettingEnablePush            SettingID = 0x2
+	SettingMaxConcurrentStreams  SettingID = 0x3
+	SettingInitialWindowSize     SettingID = 0x4
+	SettingMaxFrameSize          SettingID = 0x5
+	SettingMaxHeaderListSize     SettingID = 0x6
+	SettingEnableConnectProtocol SettingID = 0x8
+)
+
+func (id SettingID) String() string {
+	switch id {
+	case SettingHeaderTableSize:
+		return "SETTINGS_HEADER_TABLE_SIZE"
+	case SettingEnablePush:
+		return "SETTINGS_ENABLE_PUSH"
+	case SettingMaxConcurrentStreams:
+		return "SETTINGS_MAX_CONCURRENT_STREAMS"
+	case SettingInitialWindowSize:
+		return "SETTINGS_INITIAL_WINDOW_SIZE"
+	case SettingMaxFrameSize:
+		return "SETTINGS_MAX_FRAME_SIZE"
+	case SettingMaxHeaderListSize:
+		return "SETTINGS_MAX_HEADER_LIST_SIZE"
+	case SettingEnableConnectProtocol:
+		return "SETTINGS_ENABLE_CONNECT_PROTOCOL"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint16(id))
+	}
+}
+
+// Setting is a single SETTINGS parameter.
+type Setting struct {
+	ID    SettingID
+	Value uint32
+}
+
+// Settings is the list of parameters of a SETTINGS frame, in the order sent.
+type Settings []Setting
+
+// Get returns the value of the given parameter, if present.
+func (s Settings) Get(id SettingID) (uint32, bool) {
+	for _, setting := range s {
+		if setting.ID == id {
+			return setting.Value, true
+		}
+	}
+	return 0, false
+}
+
+// MarshalJSON marshals the settings as an object keyed by parameter name.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	values := map[string]uint32{}
+	for _, setting := range s {
+		values[setting.ID.String()] = setting.Value
+	}
+	return json.Marshal(values)
+}
+
+// EncodeSettings encodes the payload of a SETTINGS frame.
+func EncodeSettings(settings Settings) []byte {
+	// Setting
+	//	2 Bytes: Identifier
+	//	4 Bytes: Value
+
+	buf := make([]byte, 6*len(settings))
+	for i, setting := range settings {
+		binary.BigEndian.PutUint16(buf[i*6:], uint16(setting.ID))
+		binary.BigEndian.PutUint32(buf[i*6+2:], setting.Value)
+	}
+	return buf
+}
+
+// DecodeSettings decodes the payload of a SETTINGS frame.
+func DecodeSettings(payload []byte) (Settings, error) {
+	if len(payload)%6 != 0 {
+		return nil, fmt.Errorf("%w: settings length %d is not a multiple of 6", ErrFrameDecode, len(payload))
+	}
+
+	settings := make(Settings, 0, len(payload)/6)
+	for pos := 0; pos < len(payload); pos += 6 {
+		settings = append(settings, Setting{
+			ID:    SettingID(binary.BigEndian.Uint16(payload[pos:])),
+			Value: binary.BigEndian.Uint32(payload[pos+2:]),
+		})
+	}
+	return settings, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		encoded  []byte
+		json     string
+	}{
+		{
+			"empty",
+			Settings{},
+			[]byte{},
+			`{}`,
+		},
+		{
+			"client",
+			Settings{
+				{ID: SettingEnablePush, Value: 0},
+				{ID: SettingInitialWindowSize, Value: 1 << 20},
+			},
+			[]byte{
+				0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x04, 0x00, 0x10, 0x00, 0x00,
+			},
+			`{"SETTINGS_ENABLE_PUSH":0,"SETTINGS_INITIAL_WINDOW_SIZE":1048576}`,
+		},
+		{
+			"server",
+			Settings{
+				{ID: SettingMaxConcurrentStreams, Value: 100},
+				{ID: SettingMaxFrameSize, Value: 16384},
+				{ID: SettingMaxHeaderListSize, Value: 0xFFFFFFFF},
+				{ID: SettingID(0x0A0A), Value: 7},
+			},
+			[]byte{
+				0x00, 0x03, 0x00, 0x00, 0x00, 0x64,
+				0x00, 0x05, 0x00, 0x00, 0x40, 0x00,
+				0x00, 0x06, 0xFF, 0xFF, 0xFF, 0xFF,
+				0x0A, 0x0A, 0x00, 0x00, 0x00, 0x07,
+			},
+			`{"SETTINGS_MAX_CONCURRENT_STREAMS":100,"SETTINGS_MAX_FRAME_SIZE":16384,` +
+				`"SETTINGS_MAX_HEADER_LIST_SIZE":4294967295,"UNKNOWN(0xa0a)":7}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := EncodeSettings(tt.settings)
+			if !bytes.Equal(encoded, tt.encoded) {
+				t.Errorf("EncodeSettings() = %x, want %x", encoded, tt.encoded)
+			}
+
+			decoded, err := DecodeSettings(tt.encoded)
+			if err != nil {
+				t.Fatalf("DecodeSettings() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.settings) {
+				t.Errorf("DecodeSettings() = %v, want %v", decoded, tt.settings)
+			}
+
+			serialized, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(serialized) != tt.json {
+				t.Errorf("json.Marshal() = %s, want %s", serialized, tt.json)
+			}
+		})
+	}
+}
+
+func TestDecodeSettingsLength(t *testing.T) {
+	for _, length := range []int{1, 5, 7, 13} {
+		if _, err := DecodeSettings(make([]byte, length)); !errors.Is(err, ErrFrameDecode) {
+			t.Errorf("DecodeSettings() with length %d error = %v, want %v", length, err, ErrFrameDecode)
+		}
+	}
+}
+
+func TestSettingsGet(t *testing.T) {
+	settings := Settings{
+		{ID: SettingMaxConcurrentStreams, Value: 100},
+		{ID: SettingInitialWindowSize, Value: 65535},
+		{ID: SettingMaxConcurrentStreams, Value: 250},
+	}
+
+	tests := []struct {
+		id     SettingID
+		want   uint32
+		wantOK bool
+	}{
+		{SettingMaxConcurrentStreams, 100, true},
+		{SettingInitialWindowSize, 65535, true},
+		{SettingMaxFrameSize, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id.String(), func(t *testing.T) {
+			got, ok := settings.Get(tt.id)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Get() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
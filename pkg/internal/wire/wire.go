@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package wire provides helpers shared by the protocol packages for reading, decoding and pacing wire data.
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrOutOfBounds is returned by ReadBuffer when reading past the end of the buffer.
+var ErrOutOfBounds = fmt.Errorf("out of bounds")
+
+// ReadError wraps an error that occurred while reading, passing through deadline errors untouched.
+// A connection closed or reset before the whole message was read wraps the package's truncated error,
+// servers commonly reset the connection instead of responding to protocols they refuse. Any other error
+// wraps the package's decode error.
+func ReadError(err, truncated, decode error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: connection closed", truncated)
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("%w: connection reset", truncated)
+	}
+	return fmt.Errorf("%w: %v", decode, err)
+}
+
+// ReadBuffer reads the buffer at the given position up to the offset.
+// The resulting sub-slice of bytes is returned, along with the new cursor position.
+// If the given position + offset extend past the slice, out of bounds, an error is returned.
+func ReadBuffer(b []byte, pos, offset int) ([]byte, int, error) {
+	if pos < 0 || offset < 0 || pos+offset > len(b) {
+		return nil, 0, ErrOutOfBounds
+	}
+	return b[pos : pos+offset], pos + offset, nil
+}
+
+// Pause waits the delay before the next request on the connection, then resets the connection deadline to the
+// timeout, so time spent pacing requests doesn't count against the read timeout. A timeout of 0 leaves the
+// deadline unset.
+func Pause(conn net.Conn, delay, timeout time.Duration) error {
+	time.Sleep(delay)
+	if timeout <= 0 {
+		return nil
+	}
+	return conn.SetDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var ErrDecode = fmt.Errorf("kafka decode")
+var ErrTruncated = fmt.Errorf("%w: truncated payload or not a kafka response", ErrDecode)
+
+// encoder builds a Kafka protocol message.
+// See https://kafka.apache.org/protocol#protocol_types
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *encoder) int16(v int16) {
+	e.buf = append(e.buf, 0, 0)
+	binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(v))
+}
+
+func (e *encoder) uvarint(v uint64) {
+	tmp := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(tmp, v)
+	e.buf = append(e.buf, tmp[:n]...)
+}
+
+// STRING: INT16 length followed by the bytes.
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// COMPACT_STRING: UNSIGNED_VARINT length + 1 followed by the bytes.
+func (e *encoder) compactString(s string) {
+	e.uvarint(uint64(len(s)) + 1)
+	e.buf = append(e.buf, s...)
+}
+
+// TAGGED_FIELDS: an empty tagged field section.
+func (e *encoder) emptyTaggedFields() {
+	e.uvarint(0)
+}
+
+// decoder reads a Kafka protocol message.
+// The first error encountered is kept and all following reads return zero values.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = ErrTruncated
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	b := d.read(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (d *decoder) int16() int16 {
+	b := d.read(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (d *decoder) int32() int32 {
+	b := d.read(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		d.err = ErrTruncated
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// STRING or NULLABLE_STRING, null is decoded as an empty string.
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.read(int(n)))
+}
+
+// COMPACT_STRING or COMPACT_NULLABLE_STRING, null is decoded as an empty string.
+func (d *decoder) compactString() string {
+	n := d.uvarint()
+	if n == 0 {
+		return ""
+	}
+	return string(d.read(int(n - 1)))
+}
+
+// ARRAY length, null is decoded as an empty array.
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 {
+		return 0
+	}
+	if int(n) > len(d.buf) {
+		d.err = ErrTruncated
+		return 0
+	}
+	return int(n)
+}
+
+// COMPACT_ARRAY length, null is decoded as an empty array.
+func (d *decoder) compactArrayLen() int {
+	n := d.uvarint()
+	if n == 0 {
+		return 0
+	}
+	if n-1 > uint64(len(d.buf)) {
+		d.err = ErrTruncated
+		return 0
+	}
+	return int(n - 1)
+}
+
+// TAGGED_FIELDS: skips over every tagged field.
+func (d *decoder) skipTaggedFields() {
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		_ = d.uvarint() // Tag
+		size := d.uvarint()
+		d.read(int(size))
+	}
+}
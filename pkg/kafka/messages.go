@@ -0,0 +1,318 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+// API Keys
+const (
+	APIKeyMetadata    int16 = 3
+	APIKeyApiVersions int16 = 18
+)
+
+// Error Codes
+const (
+	ErrorNone               int16 = 0
+	ErrorUnsupportedVersion int16 = 35
+)
+
+// The first Metadata version using the flexible (compact) encoding.
+const metadataFlexibleVersion = 9
+
+// The highest Metadata version the prober knows how to encode.
+const metadataMaxVersion = 12
+
+// APIVersion is the range of versions a broker supports for a single API.
+type APIVersion struct {
+	// Key is the numeric API key.
+	Key int16 `json:"key"`
+
+	// Name is the API name, if it is a well known one.
+	Name string `json:"name,omitempty"`
+
+	// MinVersion is the lowest supported version.
+	MinVersion int16 `json:"min_version"`
+
+	// MaxVersion is the highest supported version.
+	MaxVersion int16 `json:"max_version"`
+}
+
+// APIVersions represents an ApiVersions response.
+type APIVersions struct {
+	// ErrorCode is the response error code, 0 on success.
+	ErrorCode int16 `json:"error_code"`
+
+	// APIKeys lists every API the broker supports.
+	APIKeys []APIVersion `json:"api_keys"`
+}
+
+// Find returns the supported version range of the given API key, if the broker supports it.
+func (v *APIVersions) Find(key int16) (APIVersion, bool) {
+	for _, api := range v.APIKeys {
+		if api.Key == key {
+			return api, true
+		}
+	}
+	return APIVersion{}, false
+}
+
+// Broker is a single broker from a Metadata response.
+type Broker struct {
+	// NodeID is the broker ID.
+	NodeID int32 `json:"node_id"`
+
+	// Host is the advertised listener host name.
+	Host string `json:"host"`
+
+	// Port is the advertised listener port.
+	Port int32 `json:"port"`
+
+	// Rack is the broker rack, if any.
+	Rack string `json:"rack,omitempty"`
+}
+
+// Metadata represents the cluster portion of a Metadata response.
+type Metadata struct {
+	// Version is the Metadata API version used.
+	Version int16 `json:"version"`
+
+	// ClusterID is the cluster ID.
+	ClusterID string `json:"cluster_id,omitempty"`
+
+	// ControllerID is the broker ID of the controller.
+	ControllerID int32 `json:"controller_id"`
+
+	// Brokers lists every broker in the cluster along with its advertised listener.
+	Brokers []Broker `json:"brokers"`
+}
+
+// EncodeAPIVersionsRequest encodes an ApiVersions request body.
+// Version 3 and later carry the client software name and version.
+func EncodeAPIVersionsRequest(version int16, softwareName, softwareVersion string) []byte {
+	e := &encoder{}
+	if version >= 3 {
+		e.compactString(softwareName)
+		e.compactString(softwareVersion)
+		e.emptyTaggedFields()
+	}
+	return e.buf
+}
+
+// DecodeAPIVersionsResponse attempts to decode an ApiVersions response body of the given version.
+//
+// If the broker doesn't support the requested version, it responds with UNSUPPORTED_VERSION
+// using the version 0 format, which is decoded instead.
+func DecodeAPIVersionsResponse(version int16, body []byte) (*APIVersions, error) {
+	d := &decoder{buf: body}
+
+	resp := &APIVersions{
+		ErrorCode: d.int16(),
+	}
+
+	flexible := version >= 3 && resp.ErrorCode != ErrorUnsupportedVersion
+
+	var n int
+	if flexible {
+		n = d.compactArrayLen()
+	} else {
+		n = d.arrayLen()
+	}
+
+	resp.APIKeys = make([]APIVersion, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		api := APIVersion{
+			Key:        d.int16(),
+			MinVersion: d.int16(),
+			MaxVersion: d.int16(),
+		}
+		api.Name = apiNames[api.Key]
+		if flexible {
+			d.skipTaggedFields()
+		}
+		resp.APIKeys = append(resp.APIKeys, api)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return resp, nil
+}
+
+// EncodeMetadataRequest encodes a Metadata request body asking for no topics, only the cluster and brokers.
+func EncodeMetadataRequest(version int16) []byte {
+	e := &encoder{}
+
+	// Topics: an empty (not null) array requests no topics.
+
+	if version >= metadataFlexibleVersion {
+		e.uvarint(1)
+	} else {
+		e.int32(0)
+	}
+
+	if version >= 4 {
+		e.bool(false) // Allow Auto Topic Creation
+	}
+	if version >= 8 && version <= 10 {
+		e.bool(false) // Include Cluster Authorized Operations
+	}
+	if version >= 8 {
+		e.bool(false) // Include Topic Authorized Operations
+	}
+	if version >= metadataFlexibleVersion {
+		e.emptyTaggedFields()
+	}
+
+	return e.buf
+}
+
+// DecodeMetadataResponse attempts to decode the cluster portion of a Metadata response body of the given version.
+// Topics are never requested and are not decoded.
+func DecodeMetadataResponse(version int16, body []byte) (*Metadata, error) {
+	d := &decoder{buf: body}
+	flexible := version >= metadataFlexibleVersion
+
+	md := &Metadata{
+		Version: version,
+	}
+
+	if version >= 3 {
+		_ = d.int32() // Throttle Time
+	}
+
+	// Brokers
+
+	var n int
+	if flexible {
+		n = d.compactArrayLen()
+	} else {
+		n = d.arrayLen()
+	}
+
+	md.Brokers = make([]Broker, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		b := Broker{
+			NodeID: d.int32(),
+		}
+		if flexible {
+			b.Host = d.compactString()
+		} else {
+			b.Host = d.string()
+		}
+		b.Port = d.int32()
+		if version >= 1 {
+			if flexible {
+				b.Rack = d.compactString()
+			} else {
+				b.Rack = d.string()
+			}
+		}
+		if flexible {
+			d.skipTaggedFields()
+		}
+		md.Brokers = append(md.Brokers, b)
+	}
+
+	// Cluster ID + Controller ID
+
+	if version >= 2 {
+		if flexible {
+			md.ClusterID = d.compactString()
+		} else {
+			md.ClusterID = d.string()
+		}
+	}
+	if version >= 1 {
+		md.ControllerID = d.int32()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return md, nil
+}
+
+// Names of the well known API keys.
+var apiNames = map[int16]string{
+	0:  "Produce",
+	1:  "Fetch",
+	2:  "ListOffsets",
+	3:  "Metadata",
+	4:  "LeaderAndIsr",
+	5:  "StopReplica",
+	6:  "UpdateMetadata",
+	7:  "ControlledShutdown",
+	8:  "OffsetCommit",
+	9:  "OffsetFetch",
+	10: "FindCoordinator",
+	11: "JoinGroup",
+	12: "Heartbeat",
+	13: "LeaveGroup",
+	14: "SyncGroup",
+	15: "DescribeGroups",
+	16: "ListGroups",
+	17: "SaslHandshake",
+	18: "ApiVersions",
+	19: "CreateTopics",
+	20: "DeleteTopics",
+	21: "DeleteRecords",
+	22: "InitProducerId",
+	23: "OffsetForLeaderEpoch",
+	24: "AddPartitionsToTxn",
+	25: "AddOffsetsToTxn",
+	26: "EndTxn",
+	27: "WriteTxnMarkers",
+	28: "TxnOffsetCommit",
+	29: "DescribeAcls",
+	30: "CreateAcls",
+	31: "DeleteAcls",
+	32: "DescribeConfigs",
+	33: "AlterConfigs",
+	34: "AlterReplicaLogDirs",
+	35: "DescribeLogDirs",
+	36: "SaslAuthenticate",
+	37: "CreatePartitions",
+	38: "CreateDelegationToken",
+	39: "RenewDelegationToken",
+	40: "ExpireDelegationToken",
+	41: "DescribeDelegationToken",
+	42: "DeleteGroups",
+	43: "ElectLeaders",
+	44: "IncrementalAlterConfigs",
+	45: "AlterPartitionReassignments",
+	46: "ListPartitionReassignments",
+	47: "OffsetDelete",
+	48: "DescribeClientQuotas",
+	49: "AlterClientQuotas",
+	50: "DescribeUserScramCredentials",
+	51: "AlterUserScramCredentials",
+	52: "Vote",
+	53: "BeginQuorumEpoch",
+	54: "EndQuorumEpoch",
+	55: "DescribeQuorum",
+	56: "AlterPartition",
+	57: "UpdateFeatures",
+	58: "Envelope",
+	59: "FetchSnapshot",
+	60: "DescribeCluster",
+	61: "DescribeProducers",
+	62: "BrokerRegistration",
+	63: "BrokerHeartbeat",
+	64: "UnregisterBroker",
+	65: "DescribeTransactions",
+	66: "ListTransactions",
+	67: "AllocateProducerIds",
+	68: "ConsumerGroupHeartbeat",
+}
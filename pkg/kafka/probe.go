@@ -0,0 +1,208 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kafka provides facilities for probing and inspecting Apache Kafka brokers.
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+// Client identification sent with every request.
+const (
+	ClientID        = "protoscan"
+	SoftwareName    = "protoscan"
+	SoftwareVersion = "1.0.0"
+)
+
+// Maximum response size accepted.
+const maxResponseSize = 16 << 20
+
+// Report contains the information gathered from a Kafka broker.
+type Report struct {
+	// APIVersionsV3 indicates if the broker accepted an ApiVersions v3 request carrying the client software name.
+	APIVersionsV3 bool `json:"api_versions_v3"`
+
+	// APIKeys lists every API the broker supports, from the newest ApiVersions response.
+	APIKeys []APIVersion `json:"api_keys"`
+
+	// Metadata describes the cluster and its brokers' advertised listeners.
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// MetadataError contains the reason the Metadata request failed, if it did.
+	MetadataError string `json:"metadata_error,omitempty"`
+}
+
+// Conn sends Kafka requests over a connection.
+type Conn struct {
+	rw            io.ReadWriter
+	correlationID int32
+}
+
+// NewConn creates a Conn on the given connection.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{
+		rw: rw,
+	}
+}
+
+// Probe sends ApiVersions requests (v0 and v3) to list the supported APIs, followed by a Metadata
+// request to report the cluster ID and the brokers' advertised listeners.
+func Probe(rw io.ReadWriter) (*Report, error) {
+	conn := NewConn(rw)
+	report := &Report{}
+
+	// (1) ApiVersions v0
+
+	body, err := conn.Request(APIKeyApiVersions, 0, EncodeAPIVersionsRequest(0, "", ""))
+	if err != nil {
+		return nil, err
+	}
+	v0, err := DecodeAPIVersionsResponse(0, body)
+	if err != nil {
+		return nil, err
+	}
+	if v0.ErrorCode != ErrorNone {
+		return nil, fmt.Errorf("%w: api versions failed with error code %d", ErrDecode, v0.ErrorCode)
+	}
+	report.APIKeys = v0.APIKeys
+
+	// (2) ApiVersions v3
+	//		Only sent if the broker supports it, older brokers close the connection on unsupported versions.
+
+	versions := v0
+	if api, ok := v0.Find(APIKeyApiVersions); ok && api.MaxVersion >= 3 {
+		body, err = conn.Request(APIKeyApiVersions, 3, EncodeAPIVersionsRequest(3, SoftwareName, SoftwareVersion))
+		if err != nil {
+			return nil, err
+		}
+		v3, err := DecodeAPIVersionsResponse(3, body)
+		if err != nil {
+			return nil, err
+		}
+		if v3.ErrorCode == ErrorNone {
+			report.APIVersionsV3 = true
+			report.APIKeys = v3.APIKeys
+			versions = v3
+		}
+	}
+
+	// (3) Metadata
+
+	api, ok := versions.Find(APIKeyMetadata)
+	if !ok {
+		report.MetadataError = "metadata api not supported"
+		return report, nil
+	}
+
+	version := api.MaxVersion
+	if version > metadataMaxVersion {
+		version = metadataMaxVersion
+	}
+	if version < 1 || version < api.MinVersion {
+		report.MetadataError = fmt.Sprintf("no supported metadata version in range v%d-v%d", api.MinVersion, api.MaxVersion)
+		return report, nil
+	}
+
+	body, err = conn.Request(APIKeyMetadata, version, EncodeMetadataRequest(version))
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata, err = DecodeMetadataResponse(version, body)
+	if err != nil {
+		report.MetadataError = err.Error()
+	}
+
+	return report, nil
+}
+
+// Request sends a request with the given API key, version and body, and returns the response body.
+func (c *Conn) Request(key, version int16, body []byte) ([]byte, error) {
+	c.correlationID++
+	flexible := isFlexible(key, version)
+
+	// (1) Request Header
+	//	INT32: Size
+	//	INT16: API Key
+	//	INT16: API Version
+	//	INT32: Correlation ID
+	//	STRING: Client ID
+	//	TAGGED_FIELDS: Only in flexible versions (request header v2)
+
+	e := &encoder{}
+	e.int32(0) // Size, filled in below
+	e.int16(key)
+	e.int16(version)
+	e.int32(c.correlationID)
+	e.string(ClientID)
+	if flexible {
+		e.emptyTaggedFields()
+	}
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	if _, err := c.rw.Write(e.buf); err != nil {
+		return nil, err
+	}
+
+	// (2) Response Header
+	//	INT32: Size
+	//	INT32: Correlation ID
+	//	TAGGED_FIELDS: Only in flexible versions (response header v1), except for ApiVersions
+
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(c.rw, size); err != nil {
+		return nil, wire.ReadError(err, ErrTruncated, ErrDecode)
+	}
+	n := binary.BigEndian.Uint32(size)
+	if n < 4 || n > maxResponseSize {
+		return nil, fmt.Errorf("%w: invalid response size, connection is not kafka", ErrDecode)
+	}
+
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, resp); err != nil {
+		return nil, wire.ReadError(err, ErrTruncated, ErrDecode)
+	}
+
+	d := &decoder{buf: resp}
+	if id := d.int32(); id != c.correlationID {
+		return nil, fmt.Errorf("%w: unexpected correlation id %d, connection is not kafka", ErrDecode, id)
+	}
+	if flexible && key != APIKeyApiVersions {
+		d.skipTaggedFields()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return resp[d.pos:], nil
+}
+
+// Determines if the API version uses the flexible encoding.
+func isFlexible(key, version int16) bool {
+	switch key {
+	case APIKeyApiVersions:
+		return version >= 3
+	case APIKeyMetadata:
+		return version >= metadataFlexibleVersion
+	default:
+		return false
+	}
+}
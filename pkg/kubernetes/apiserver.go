@@ -0,0 +1,206 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kubernetes provides facilities for probing and inspecting exposed Kubernetes API servers and kubelets.
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+var ErrResponse = fmt.Errorf("kubernetes response")
+
+// Report contains the information gathered from a Kubernetes API server.
+type Report struct {
+	// Version contains the version information, if /version could be read.
+	Version *Version `json:"version,omitempty"`
+
+	// VersionError contains the reason the /version request failed, if it did.
+	VersionError string `json:"version_error,omitempty"`
+
+	// Healthz is the response body of /healthz, "ok" for a healthy server.
+	Healthz string `json:"healthz,omitempty"`
+
+	// HealthzError contains the reason the /healthz request failed, if it did.
+	HealthzError string `json:"healthz_error,omitempty"`
+
+	// AnonymousAuth indicates if requests without credentials are authenticated as system:anonymous
+	// (--anonymous-auth, enabled by default) rather than refused with 401 Unauthorized.
+	AnonymousAuth bool `json:"anonymous_auth"`
+
+	// AnonymousAccess indicates if namespaces could be listed without credentials, meaning the
+	// system:anonymous user has been granted access to the cluster.
+	AnonymousAccess bool `json:"anonymous_access"`
+
+	// Namespaces lists the namespace names, if they could be listed.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespacesError contains the reason namespaces couldn't be listed, for example "forbidden: User
+	// \"system:anonymous\" cannot list resource \"namespaces\"".
+	NamespacesError string `json:"namespaces_error,omitempty"`
+}
+
+// Version contains the version information returned by /version.
+type Version struct {
+	// GitVersion is the full version, for example "v1.19.4".
+	GitVersion string `json:"git_version"`
+
+	Major     string `json:"major"`
+	Minor     string `json:"minor"`
+	GitCommit string `json:"git_commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Response body of GET /version
+type version struct {
+	Major      string `json:"major"`
+	Minor      string `json:"minor"`
+	GitVersion string `json:"gitVersion"`
+	GitCommit  string `json:"gitCommit"`
+	BuildDate  string `json:"buildDate"`
+	GoVersion  string `json:"goVersion"`
+	Platform   string `json:"platform"`
+}
+
+// Status is the body of an API error response.
+type status struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+// Response body of GET /api/v1/namespaces, only the names are decoded.
+type namespaceList struct {
+	Kind  string `json:"kind"`
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// Probe requests /version and /healthz, readable by system:anonymous under the default RBAC policy, then
+// attempts to list namespaces to determine whether anonymous requests are authenticated and authorized.
+func Probe(ctx context.Context, c *protohttp.Client) (*Report, error) {
+	report := &Report{}
+
+	// (1) GET /version
+
+	var v version
+	resp, err := getJSON(ctx, c, "/version", &v)
+	switch {
+	case err != nil:
+		report.VersionError = err.Error()
+	case v.GitVersion == "":
+		report.VersionError = fmt.Sprintf("%v: /version: no git version", ErrResponse)
+	default:
+		report.Version = &Version{
+			GitVersion: v.GitVersion,
+			Major:      v.Major,
+			Minor:      v.Minor,
+			GitCommit:  v.GitCommit,
+			BuildDate:  v.BuildDate,
+			GoVersion:  v.GoVersion,
+			Platform:   v.Platform,
+		}
+	}
+	if resp == nil {
+		// The target couldn't be reached at all.
+		return nil, err
+	}
+
+	// (2) GET /healthz
+
+	resp, err = c.Get(ctx, "/healthz")
+	switch {
+	case err != nil:
+		report.HealthzError = err.Error()
+	case resp.StatusCode != http.StatusOK:
+		report.HealthzError = fmt.Sprintf("%v: /healthz: unexpected status %s", ErrResponse, resp.Status)
+	default:
+		report.Healthz = strings.TrimSpace(string(resp.Body))
+	}
+
+	// (3) GET /api/v1/namespaces
+
+	var list namespaceList
+	resp, err = getJSON(ctx, c, "/api/v1/namespaces", &list)
+	switch {
+	case err == nil && list.Kind == "NamespaceList":
+		report.AnonymousAuth = true
+		report.AnonymousAccess = true
+		report.Namespaces = []string{}
+		for _, item := range list.Items {
+			report.Namespaces = append(report.Namespaces, item.Metadata.Name)
+		}
+	case resp != nil && resp.StatusCode == http.StatusForbidden:
+		// Forbidden (rather than Unauthorized) means the request was authenticated as system:anonymous.
+		report.AnonymousAuth = true
+		report.NamespacesError = statusMessage(resp)
+	case resp != nil:
+		report.NamespacesError = statusMessage(resp)
+	default:
+		report.NamespacesError = err.Error()
+	}
+
+	if report.Version == nil && !isAPIServer(resp) {
+		return nil, fmt.Errorf("%w: not a kubernetes api server", ErrResponse)
+	}
+	return report, nil
+}
+
+// Determines if the response is an API server's Status error response.
+func isAPIServer(resp *protohttp.Response) bool {
+	if resp == nil {
+		return false
+	}
+	var s status
+	return json.Unmarshal(resp.Body, &s) == nil && s.Kind == "Status"
+}
+
+// Returns the message of an error response, from its Status body if it has one.
+func statusMessage(resp *protohttp.Response) string {
+	var s status
+	if json.Unmarshal(resp.Body, &s) == nil && s.Message != "" {
+		return s.Message
+	}
+	return fmt.Sprintf("unexpected status %s", resp.Status)
+}
+
+// Sends a GET request and decodes the JSON response body, failing on any status other than 200 OK.
+// The response is returned even when decoding fails, so the caller can inspect error responses.
+func getJSON(ctx context.Context, c *protohttp.Client, path string, v interface{}) (*protohttp.Response, error) {
+	resp, err := c.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("%w: %s: unexpected status %s", ErrResponse, path, resp.Status)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return resp, fmt.Errorf("%w: %s: %v", ErrResponse, path, err)
+	}
+	return resp, nil
+}
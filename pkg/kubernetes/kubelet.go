@@ -0,0 +1,138 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	protohttp "github.com/seglberg/protoscan/pkg/http"
+)
+
+// KubeletReport contains the information gathered from a kubelet API.
+type KubeletReport struct {
+	// StatusCode is the HTTP status code of the /pods request.
+	StatusCode int `json:"status_code"`
+
+	// Healthz is the response body of /healthz, "ok" for a healthy kubelet.
+	Healthz string `json:"healthz,omitempty"`
+
+	// AnonymousAuth indicates if requests without credentials are authenticated as system:anonymous
+	// (--anonymous-auth, enabled by default) rather than refused with 401 Unauthorized.
+	AnonymousAuth bool `json:"anonymous_auth"`
+
+	// AnonymousAccess indicates if pods could be listed without credentials. With access to the kubelet API
+	// anyone can also execute commands in the node's containers.
+	AnonymousAccess bool `json:"anonymous_access"`
+
+	// Node is the name of the node the kubelet runs on, taken from its pods.
+	Node string `json:"node,omitempty"`
+
+	// Pods lists the pods running on the node as "namespace/name".
+	Pods []string `json:"pods,omitempty"`
+
+	// Images lists the distinct container images of the pods.
+	Images []string `json:"images,omitempty"`
+
+	// Error is the response body of a refused /pods request, for example
+	// "Forbidden (user=system:anonymous, verb=get, resource=nodes, subresource=proxy)".
+	Error string `json:"error,omitempty"`
+}
+
+// Response body of GET /pods, only the fields reported are decoded.
+type podList struct {
+	Kind  string `json:"kind"`
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			NodeName   string `json:"nodeName"`
+			Containers []struct {
+				Image string `json:"image"`
+			} `json:"containers"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// ProbeKubelet requests /healthz and /pods from a kubelet, on either its authenticated port (10250, HTTPS) or
+// its read-only port (10255, HTTP), to determine whether pods can be listed without credentials.
+func ProbeKubelet(ctx context.Context, c *protohttp.Client) (*KubeletReport, error) {
+	// (1) GET /pods
+
+	var list podList
+	resp, err := getJSON(ctx, c, "/pods", &list)
+	if resp == nil {
+		return nil, err
+	}
+
+	report := &KubeletReport{
+		StatusCode: resp.StatusCode,
+	}
+
+	switch {
+	case err == nil && list.Kind == "PodList":
+		report.AnonymousAuth = true
+		report.AnonymousAccess = true
+		report.Pods = []string{}
+
+		images := map[string]bool{}
+		for _, pod := range list.Items {
+			report.Pods = append(report.Pods, pod.Metadata.Namespace+"/"+pod.Metadata.Name)
+			if report.Node == "" {
+				report.Node = pod.Spec.NodeName
+			}
+			for _, container := range pod.Spec.Containers {
+				images[container.Image] = true
+			}
+		}
+		for image := range images {
+			report.Images = append(report.Images, image)
+		}
+		sort.Strings(report.Images)
+
+	case resp.StatusCode == http.StatusForbidden:
+		// Forbidden (rather than Unauthorized) means the request was authenticated as system:anonymous.
+		report.AnonymousAuth = true
+		report.Error = strings.TrimSpace(string(resp.Body))
+	case resp.StatusCode == http.StatusUnauthorized:
+		report.Error = strings.TrimSpace(string(resp.Body))
+	default:
+		return nil, fmt.Errorf("%w: /pods: not a kubelet (%s)", ErrResponse, resp.Status)
+	}
+	status := resp.Status
+
+	// (2) GET /healthz
+
+	resp, err = c.Get(ctx, "/healthz")
+	if err == nil && resp.StatusCode == http.StatusOK {
+		report.Healthz = strings.TrimSpace(string(resp.Body))
+	}
+
+	// (3) Any HTTP server may refuse /pods, only count a refusal as a kubelet with kubelet-specific evidence
+
+	if !report.AnonymousAccess && report.Healthz != "ok" &&
+		!strings.HasPrefix(report.Error, "Forbidden (user=system:anonymous") {
+		return nil, fmt.Errorf("%w: /pods: not a kubelet (%s)", ErrResponse, status)
+	}
+
+	return report, nil
+}
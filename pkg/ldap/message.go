@@ -0,0 +1,280 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/ber"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated message or not an ldap response", ErrMessageDecode)
+var ErrDisconnected = fmt.Errorf("%w: server disconnected", ErrMessageDecode)
+
+// Protocol Operations (APPLICATION tags)
+const (
+	opUnbindRequest     = 2
+	opSearchRequest     = 3
+	opSearchResultEntry = 4
+	opSearchResultDone  = 5
+	opSearchResultRef   = 19
+	opExtendedResponse  = 24
+)
+
+// Search Scopes and Alias Dereferencing
+const (
+	scopeBaseObject        = 0
+	derefNeverDerefAliases = 0
+)
+
+// Filter Choices (CONTEXT tags)
+const (
+	filterPresent = 7
+)
+
+// Maximum size of a single LDAP message accepted from the server.
+const maxMessageSize = 1 << 20
+
+// Message is a decoded LDAPMessage envelope.
+//
+// See https://tools.ietf.org/html/rfc4511#section-4.2
+type Message struct {
+	// ID is the message ID, 0 for unsolicited notifications.
+	ID int64
+
+	// Op is the protocol operation.
+	Op *ber.Element
+}
+
+// Entry is a decoded SearchResultEntry.
+type Entry struct {
+	// DN is the distinguished name of the entry, empty for the rootDSE.
+	DN string
+
+	// Attributes contains the values of each returned attribute, keyed by the name the server sent.
+	Attributes map[string][]string
+}
+
+// Result is a decoded LDAPResult, as carried by SearchResultDone and ExtendedResponse.
+type Result struct {
+	// Code is the result code.
+	Code int64
+
+	// MatchedDN is the matched distinguished name.
+	MatchedDN string
+
+	// DiagnosticMessage is the server's diagnostic message, if any.
+	DiagnosticMessage string
+}
+
+// EncodeSearchRequest encodes a base object search of the given DN for the given attributes,
+// matching any entry with an objectClass.
+//
+// See https://tools.ietf.org/html/rfc4511#section-4.5.1
+func EncodeSearchRequest(id int64, baseDN string, attributes []string) []byte {
+	// SearchRequest
+	//	baseObject, scope, derefAliases, sizeLimit, timeLimit, typesOnly, filter, attributes
+
+	attrs := make([][]byte, len(attributes))
+	for i, a := range attributes {
+		attrs[i] = ber.OctetString(a)
+	}
+
+	search := ber.Encode(ber.ClassApplication, true, opSearchRequest, concat(
+		ber.OctetString(baseDN),
+		ber.Enumerated(scopeBaseObject),
+		ber.Enumerated(derefNeverDerefAliases),
+		ber.Integer(0),
+		ber.Integer(0),
+		ber.Boolean(false),
+		ber.Encode(ber.ClassContext, false, filterPresent, []byte("objectClass")),
+		ber.Sequence(attrs...),
+	))
+
+	return ber.Sequence(ber.Integer(id), search)
+}
+
+// EncodeUnbindRequest encodes an UnbindRequest.
+func EncodeUnbindRequest(id int64) []byte {
+	return ber.Sequence(ber.Integer(id), ber.Encode(ber.ClassApplication, false, opUnbindRequest, nil))
+}
+
+// ReadMessage reads an LDAPMessage from the reader.
+func ReadMessage(r io.Reader) (*Message, error) {
+	e, err := ber.Read(r, maxMessageSize)
+	if err != nil {
+		if errors.Is(err, ber.ErrDecode) {
+			return nil, fmt.Errorf("%w: %v, connection is not ldap", ErrMessageDecode, err)
+		}
+		return nil, err
+	}
+	if !e.Is(ber.ClassUniversal, ber.TagSequence) {
+		return nil, fmt.Errorf("%w: message is not a sequence, connection is not ldap", ErrMessageDecode)
+	}
+
+	// LDAPMessage
+	//	messageID, protocolOp, controls (ignored)
+
+	children, err := e.Children()
+	if err != nil || len(children) < 2 {
+		return nil, ErrMessageTruncated
+	}
+	if !children[0].Is(ber.ClassUniversal, ber.TagInteger) {
+		return nil, fmt.Errorf("%w: invalid message id, connection is not ldap", ErrMessageDecode)
+	}
+	id, err := children[0].Int()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid message id, connection is not ldap", ErrMessageDecode)
+	}
+	if children[1].Class != ber.ClassApplication {
+		return nil, fmt.Errorf("%w: invalid protocol operation, connection is not ldap", ErrMessageDecode)
+	}
+
+	return &Message{
+		ID: id,
+		Op: children[1],
+	}, nil
+}
+
+// DecodeEntry decodes a SearchResultEntry protocol operation.
+//
+// See https://tools.ietf.org/html/rfc4511#section-4.5.2
+func DecodeEntry(op *ber.Element) (*Entry, error) {
+	// SearchResultEntry
+	//	objectName, attributes (SEQUENCE OF PartialAttribute)
+
+	children, err := op.Children()
+	if err != nil || len(children) < 2 {
+		return nil, ErrMessageTruncated
+	}
+
+	entry := &Entry{
+		DN:         string(children[0].Value),
+		Attributes: map[string][]string{},
+	}
+
+	attributes, err := children[1].Children()
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+
+	// PartialAttribute
+	//	type, vals (SET OF value)
+
+	for _, attr := range attributes {
+		parts, err := attr.Children()
+		if err != nil || len(parts) < 2 {
+			return nil, ErrMessageTruncated
+		}
+		vals, err := parts[1].Children()
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+
+		name := string(parts[0].Value)
+		values := make([]string, 0, len(vals))
+		for _, v := range vals {
+			values = append(values, string(v.Value))
+		}
+		entry.Attributes[name] = append(entry.Attributes[name], values...)
+	}
+
+	return entry, nil
+}
+
+// DecodeResult decodes the LDAPResult of a SearchResultDone or ExtendedResponse protocol operation.
+//
+// See https://tools.ietf.org/html/rfc4511#section-4.1.9
+func DecodeResult(op *ber.Element) (*Result, error) {
+	// LDAPResult
+	//	resultCode, matchedDN, diagnosticMessage, referral (optional, ignored)
+
+	children, err := op.Children()
+	if err != nil || len(children) < 3 {
+		return nil, ErrMessageTruncated
+	}
+
+	code, err := children[0].Int()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid result code", ErrMessageDecode)
+	}
+
+	return &Result{
+		Code:              code,
+		MatchedDN:         string(children[1].Value),
+		DiagnosticMessage: string(children[2].Value),
+	}, nil
+}
+
+// ResultCodeName returns the name of an LDAP result code.
+//
+// See https://tools.ietf.org/html/rfc4511#appendix-A.1
+func ResultCodeName(code int64) string {
+	switch code {
+	case 0:
+		return "success"
+	case 1:
+		return "operationsError"
+	case 2:
+		return "protocolError"
+	case 3:
+		return "timeLimitExceeded"
+	case 4:
+		return "sizeLimitExceeded"
+	case 7:
+		return "authMethodNotSupported"
+	case 8:
+		return "strongerAuthRequired"
+	case 10:
+		return "referral"
+	case 11:
+		return "adminLimitExceeded"
+	case 12:
+		return "unavailableCriticalExtension"
+	case 13:
+		return "confidentialityRequired"
+	case 32:
+		return "noSuchObject"
+	case 48:
+		return "inappropriateAuthentication"
+	case 49:
+		return "invalidCredentials"
+	case 50:
+		return "insufficientAccessRights"
+	case 51:
+		return "busy"
+	case 52:
+		return "unavailable"
+	case 53:
+		return "unwillingToPerform"
+	case 80:
+		return "other"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", code)
+	}
+}
+
+func concat(elements ...[]byte) []byte {
+	var buf []byte
+	for _, e := range elements {
+		buf = append(buf, e...)
+	}
+	return buf
+}
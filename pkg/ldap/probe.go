@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ldap provides facilities for probing and inspecting LDAP directory servers.
+package ldap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OIDStartTLS is the StartTLS extended operation OID, listed in supportedExtension when offered.
+const OIDStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// RootDSEAttributes lists the attributes requested from the rootDSE.
+// Servers only return operational attributes when asked for them, either by name or with "+".
+var RootDSEAttributes = []string{
+	"*",
+	"+",
+	"namingContexts",
+	"defaultNamingContext",
+	"supportedLDAPVersion",
+	"supportedSASLMechanisms",
+	"supportedControl",
+	"supportedExtension",
+	"supportedFeatures",
+	"vendorName",
+	"vendorVersion",
+	"subschemaSubentry",
+}
+
+// Report contains the information gathered from an LDAP server's rootDSE.
+type Report struct {
+	// ResultCode is the result code of the search.
+	ResultCode int64 `json:"result_code"`
+
+	// Result is the name of the result code, for example "success".
+	Result string `json:"result"`
+
+	// DiagnosticMessage is the server's diagnostic message, if any.
+	DiagnosticMessage string `json:"diagnostic_message,omitempty"`
+
+	// NamingContexts lists the naming contexts held by the server.
+	NamingContexts []string `json:"naming_contexts,omitempty"`
+
+	// DefaultNamingContext is the default naming context (Active Directory).
+	DefaultNamingContext string `json:"default_naming_context,omitempty"`
+
+	// SupportedLDAPVersion lists the supported LDAP protocol versions.
+	SupportedLDAPVersion []string `json:"supported_ldap_version,omitempty"`
+
+	// SupportedSASLMechanisms lists the offered SASL mechanisms.
+	SupportedSASLMechanisms []string `json:"supported_sasl_mechanisms,omitempty"`
+
+	// SupportedControl lists the supported control OIDs.
+	SupportedControl []string `json:"supported_control,omitempty"`
+
+	// SupportedExtension lists the supported extended operation OIDs.
+	SupportedExtension []string `json:"supported_extension,omitempty"`
+
+	// VendorName is the server vendor name.
+	VendorName string `json:"vendor_name,omitempty"`
+
+	// VendorVersion is the server vendor version.
+	VendorVersion string `json:"vendor_version,omitempty"`
+
+	// StartTLS indicates if the StartTLS extended operation is offered.
+	StartTLS bool `json:"starttls"`
+
+	// Attributes contains every attribute returned for the rootDSE.
+	Attributes map[string][]string `json:"attributes"`
+}
+
+// Probe performs an anonymous search of the rootDSE, the entry with an empty DN describing the server itself.
+// No bind is sent, LDAPv3 servers treat an unbound connection as anonymous.
+func Probe(rw io.ReadWriter) (*Report, error) {
+	const searchID = 1
+
+	// (1) Search the rootDSE
+
+	if _, err := rw.Write(EncodeSearchRequest(searchID, "", RootDSEAttributes)); err != nil {
+		return nil, err
+	}
+
+	// (2) Read Entries until the Search is Done
+
+	report := &Report{
+		Attributes: map[string][]string{},
+	}
+
+	for {
+		msg, err := ReadMessage(rw)
+		if err != nil {
+			return nil, err
+		}
+
+		// Servers send an unsolicited Notice of Disconnection (message ID 0) before closing the connection.
+
+		if msg.ID == 0 && msg.Op.Tag == opExtendedResponse {
+			result, err := DecodeResult(msg.Op)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %s: %s", ErrDisconnected, ResultCodeName(result.Code), result.DiagnosticMessage)
+		}
+		if msg.ID != searchID {
+			continue
+		}
+
+		switch msg.Op.Tag {
+		case opSearchResultEntry:
+			entry, err := DecodeEntry(msg.Op)
+			if err != nil {
+				return nil, err
+			}
+			for name, values := range entry.Attributes {
+				report.Attributes[name] = append(report.Attributes[name], values...)
+			}
+		case opSearchResultRef:
+			// References point to other servers, ignored.
+		case opSearchResultDone:
+			result, err := DecodeResult(msg.Op)
+			if err != nil {
+				return nil, err
+			}
+			report.ResultCode = result.Code
+			report.Result = ResultCodeName(result.Code)
+			report.DiagnosticMessage = result.DiagnosticMessage
+
+			_, _ = rw.Write(EncodeUnbindRequest(searchID + 1))
+
+			inspect(report)
+			return report, nil
+		default:
+			return nil, fmt.Errorf("%w: unexpected protocol operation %d", ErrMessageDecode, msg.Op.Tag)
+		}
+	}
+}
+
+// Fills in the well known rootDSE attributes from the returned attributes.
+func inspect(report *Report) {
+	report.NamingContexts = attribute(report.Attributes, "namingContexts")
+	report.DefaultNamingContext = first(attribute(report.Attributes, "defaultNamingContext"))
+	report.SupportedLDAPVersion = attribute(report.Attributes, "supportedLDAPVersion")
+	report.SupportedSASLMechanisms = attribute(report.Attributes, "supportedSASLMechanisms")
+	report.SupportedControl = attribute(report.Attributes, "supportedControl")
+	report.SupportedExtension = attribute(report.Attributes, "supportedExtension")
+	report.VendorName = first(attribute(report.Attributes, "vendorName"))
+	report.VendorVersion = first(attribute(report.Attributes, "vendorVersion"))
+
+	for _, oid := range report.SupportedExtension {
+		if oid == OIDStartTLS {
+			report.StartTLS = true
+		}
+	}
+}
+
+// Returns the values of the attribute, attribute names are case insensitive.
+func attribute(attributes map[string][]string, name string) []string {
+	for k, v := range attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Returns the first value, or an empty string if there are none.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
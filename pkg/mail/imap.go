@@ -0,0 +1,165 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+// Maximum number of untagged lines read while waiting for a tagged response.
+const maxUntagged = 256
+
+// ProbeIMAP reads the IMAP greeting, sends CAPABILITY and upgrades the connection with STARTTLS if it is offered.
+//
+// See https://tools.ietf.org/html/rfc3501
+func ProbeIMAP(conn net.Conn, serverName string) (*Report, error) {
+	text := textproto.NewConn(conn)
+
+	// (1) Read the Greeting
+	//		Either "* OK", "* PREAUTH" or "* BYE" if the server refuses the connection.
+
+	banner, err := text.ReadLine()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if !strings.HasPrefix(banner, "* OK") && !strings.HasPrefix(banner, "* PREAUTH") {
+		return nil, fmt.Errorf("%w: unexpected greeting %q", ErrResponse, banner)
+	}
+
+	report := &Report{
+		Banner: strings.TrimPrefix(banner, "* "),
+	}
+
+	// (2) Send CAPABILITY
+
+	capabilities, err := imapCapability(text, "a1")
+	if err != nil {
+		return nil, err
+	}
+
+	report.Capabilities = capabilities
+	report.AuthMechanisms = imapAuthMechanisms(capabilities)
+
+	for _, capability := range capabilities {
+		switch strings.ToUpper(capability) {
+		case "IDLE":
+			report.Idle = true
+		case "STARTTLS":
+			report.StartTLS = true
+		}
+	}
+
+	// (3) Upgrade with STARTTLS
+
+	if report.StartTLS {
+		text = imapStartTLS(text, conn, serverName, report)
+	}
+
+	// Best effort LOGOUT.
+	_ = text.PrintfLine("a4 LOGOUT")
+
+	return report, nil
+}
+
+// Upgrades the connection and sends CAPABILITY again, recording the results in the report.
+// The connection to continue the conversation on is returned.
+// Failing to upgrade is not fatal, the reason is recorded instead.
+func imapStartTLS(text *textproto.Conn, conn net.Conn, serverName string, report *Report) *textproto.Conn {
+	if _, err := imapCommand(text, "a2", "STARTTLS"); err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+
+	tlsConn, tlsReport, err := tlsinfo.Client(conn, serverName)
+	if err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+	report.TLS = tlsReport
+
+	text = textproto.NewConn(tlsConn)
+
+	capabilities, err := imapCapability(text, "a3")
+	if err != nil {
+		return text
+	}
+	report.TLSCapabilities = capabilities
+	report.TLSAuthMechanisms = imapAuthMechanisms(capabilities)
+
+	return text
+}
+
+// Sends CAPABILITY and returns the advertised capabilities.
+func imapCapability(text *textproto.Conn, tag string) ([]string, error) {
+	untagged, err := imapCommand(text, tag, "CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range untagged {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.EqualFold(fields[1], "CAPABILITY") {
+			return fields[2:], nil
+		}
+	}
+	return []string{}, nil
+}
+
+// Sends a tagged command and reads the untagged responses up to the tagged completion.
+// An error is returned unless the command completes with OK.
+func imapCommand(text *textproto.Conn, tag, command string) ([]string, error) {
+	if err := text.PrintfLine("%s %s", tag, command); err != nil {
+		return nil, wrapError(err)
+	}
+
+	var untagged []string
+	for i := 0; i < maxUntagged; i++ {
+		line, err := text.ReadLine()
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+
+		status := strings.TrimPrefix(line, tag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			return nil, fmt.Errorf("%w: %s failed: %q", ErrResponse, command, status)
+		}
+		return untagged, nil
+	}
+
+	return nil, fmt.Errorf("%w: too many untagged responses", ErrResponse)
+}
+
+// Returns the mechanisms listed by the AUTH= capabilities.
+func imapAuthMechanisms(capabilities []string) []string {
+	var mechanisms []string
+	for _, capability := range capabilities {
+		if strings.HasPrefix(strings.ToUpper(capability), "AUTH=") {
+			mechanisms = append(mechanisms, capability[len("AUTH="):])
+		}
+	}
+	return mechanisms
+}
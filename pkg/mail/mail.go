@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mail provides facilities for probing SMTP, POP3 and IMAP mail servers, including upgrading
+// the connection with STARTTLS to inspect the server's certificates.
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+var ErrResponse = fmt.Errorf("mail response")
+
+// Hostname sent by the client in SMTP EHLO/HELO commands.
+const clientHostname = "protoscan"
+
+// Report contains the information gathered from a mail server.
+// The same report is used by the SMTP, POP3 and IMAP probers, fields not applicable to a protocol are omitted.
+type Report struct {
+	// Banner is the server greeting.
+	Banner string `json:"banner"`
+
+	// Capabilities lists the advertised extensions (SMTP EHLO, POP3 CAPA or IMAP CAPABILITY).
+	Capabilities []string `json:"capabilities"`
+
+	// AuthMechanisms lists the advertised SASL authentication mechanisms.
+	AuthMechanisms []string `json:"auth_mechanisms,omitempty"`
+
+	// Size is the maximum message size advertised by SMTP servers (SIZE).
+	Size *uint64 `json:"size,omitempty"`
+
+	// Pipelining indicates if command pipelining is supported (SMTP and POP3).
+	Pipelining bool `json:"pipelining,omitempty"`
+
+	// Idle indicates if the IMAP IDLE extension is supported.
+	Idle bool `json:"idle,omitempty"`
+
+	// StartTLS indicates if the server offers to upgrade the connection (STARTTLS or STLS).
+	StartTLS bool `json:"starttls"`
+
+	// TLS describes the upgraded TLS session, if the upgrade succeeded.
+	TLS *tlsinfo.Report `json:"tls,omitempty"`
+
+	// TLSCapabilities lists the extensions advertised after upgrading to TLS.
+	TLSCapabilities []string `json:"tls_capabilities,omitempty"`
+
+	// TLSAuthMechanisms lists the authentication mechanisms advertised after upgrading to TLS.
+	TLSAuthMechanisms []string `json:"tls_auth_mechanisms,omitempty"`
+
+	// TLSError contains the reason the upgrade failed, if it did.
+	TLSError string `json:"tls_error,omitempty"`
+}
+
+// Wraps a protocol error, passing through deadline errors untouched.
+func wrapError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, ErrResponse) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrResponse, err)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+// ProbePOP3 reads the POP3 greeting, sends CAPA and upgrades the connection with STLS if it is offered.
+//
+// See https://tools.ietf.org/html/rfc2449 and https://tools.ietf.org/html/rfc2595
+func ProbePOP3(conn net.Conn, serverName string) (*Report, error) {
+	text := textproto.NewConn(conn)
+
+	// (1) Read the Greeting
+
+	banner, err := pop3Status(text)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Banner: banner,
+	}
+
+	// (2) Send CAPA
+	//		Servers that don't support CAPA respond with an error, in which case there are no capabilities to report.
+
+	capabilities, err := pop3Capa(text)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Capabilities = capabilities
+	report.AuthMechanisms = pop3AuthMechanisms(capabilities)
+
+	for _, capability := range capabilities {
+		keyword, _ := splitKeyword(capability)
+		switch keyword {
+		case "PIPELINING":
+			report.Pipelining = true
+		case "STLS":
+			report.StartTLS = true
+		}
+	}
+
+	// (3) Upgrade with STLS
+
+	if report.StartTLS {
+		text = pop3StartTLS(text, conn, serverName, report)
+	}
+
+	// Best effort QUIT.
+	_ = text.PrintfLine("QUIT")
+
+	return report, nil
+}
+
+// Upgrades the connection and sends CAPA again, recording the results in the report.
+// The connection to continue the conversation on is returned.
+// Failing to upgrade is not fatal, the reason is recorded instead.
+func pop3StartTLS(text *textproto.Conn, conn net.Conn, serverName string, report *Report) *textproto.Conn {
+	if err := text.PrintfLine("STLS"); err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+	if _, err := pop3Status(text); err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+
+	tlsConn, tlsReport, err := tlsinfo.Client(conn, serverName)
+	if err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+	report.TLS = tlsReport
+
+	text = textproto.NewConn(tlsConn)
+
+	capabilities, err := pop3Capa(text)
+	if err != nil {
+		return text
+	}
+	report.TLSCapabilities = capabilities
+	report.TLSAuthMechanisms = pop3AuthMechanisms(capabilities)
+
+	return text
+}
+
+// Sends CAPA and returns the capabilities, one per line.
+// An empty list is returned if the server doesn't support CAPA.
+func pop3Capa(text *textproto.Conn) ([]string, error) {
+	if err := text.PrintfLine("CAPA"); err != nil {
+		return nil, wrapError(err)
+	}
+
+	line, err := text.ReadLine()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return []string{}, nil
+	}
+
+	lines, err := text.ReadDotLines()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return lines, nil
+}
+
+// Reads a single status line, returning an error for anything other than +OK.
+// The status indicator is stripped from the returned line.
+func pop3Status(text *textproto.Conn) (string, error) {
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", wrapError(err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("%w: unexpected response %q", ErrResponse, line)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+// Returns the mechanisms listed by the SASL capability.
+func pop3AuthMechanisms(capabilities []string) []string {
+	for _, capability := range capabilities {
+		keyword, param := splitKeyword(capability)
+		if keyword == "SASL" {
+			return strings.Fields(param)
+		}
+	}
+	return nil
+}
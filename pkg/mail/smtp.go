@@ -0,0 +1,161 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mail
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/tlsinfo"
+)
+
+// ProbeSMTP reads the SMTP greeting, sends EHLO and upgrades the connection with STARTTLS if it is offered.
+//
+// See https://tools.ietf.org/html/rfc5321 and https://tools.ietf.org/html/rfc3207
+func ProbeSMTP(conn net.Conn, serverName string) (*Report, error) {
+	text := textproto.NewConn(conn)
+
+	// (1) Read the Greeting
+
+	_, banner, err := text.ReadResponse(220)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	report := &Report{
+		Banner: banner,
+	}
+
+	// (2) Send EHLO
+	//		Servers that don't support ESMTP refuse EHLO, in which case there are no extensions to report.
+
+	extensions, err := smtpEhlo(text)
+	if err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) {
+			return nil, wrapError(err)
+		}
+		extensions = []string{}
+	}
+
+	report.Capabilities = extensions
+	report.AuthMechanisms = smtpAuthMechanisms(extensions)
+
+	for _, ext := range extensions {
+		keyword, param := splitKeyword(ext)
+		switch keyword {
+		case "SIZE":
+			if size, err := strconv.ParseUint(param, 10, 64); err == nil {
+				report.Size = &size
+			}
+		case "PIPELINING":
+			report.Pipelining = true
+		case "STARTTLS":
+			report.StartTLS = true
+		}
+	}
+
+	// (3) Upgrade with STARTTLS
+
+	if report.StartTLS {
+		text = smtpStartTLS(text, conn, serverName, report)
+	}
+
+	// Best effort QUIT.
+	_ = text.PrintfLine("QUIT")
+
+	return report, nil
+}
+
+// Upgrades the connection and sends EHLO again, recording the results in the report.
+// The connection to continue the conversation on is returned.
+// Failing to upgrade is not fatal, the reason is recorded instead.
+func smtpStartTLS(text *textproto.Conn, conn net.Conn, serverName string, report *Report) *textproto.Conn {
+	id, err := text.Cmd("STARTTLS")
+	if err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+	text.StartResponse(id)
+	_, _, err = text.ReadResponse(220)
+	text.EndResponse(id)
+	if err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+
+	tlsConn, tlsReport, err := tlsinfo.Client(conn, serverName)
+	if err != nil {
+		report.TLSError = err.Error()
+		return text
+	}
+	report.TLS = tlsReport
+
+	text = textproto.NewConn(tlsConn)
+
+	extensions, err := smtpEhlo(text)
+	if err != nil {
+		return text
+	}
+	report.TLSCapabilities = extensions
+	report.TLSAuthMechanisms = smtpAuthMechanisms(extensions)
+
+	return text
+}
+
+// Sends EHLO and returns the advertised extensions, one per line.
+func smtpEhlo(text *textproto.Conn) ([]string, error) {
+	id, err := text.Cmd("EHLO %s", clientHostname)
+	if err != nil {
+		return nil, err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	_, msg, err := text.ReadResponse(250)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first line is the server's greeting, the remaining lines are the extensions.
+	lines := strings.Split(msg, "\n")
+	return lines[1:], nil
+}
+
+// Returns the mechanisms listed by the AUTH extension.
+func smtpAuthMechanisms(extensions []string) []string {
+	for _, ext := range extensions {
+		keyword, param := splitKeyword(ext)
+		if keyword == "AUTH" {
+			return strings.Fields(param)
+		}
+	}
+	return nil
+}
+
+// Splits an extension line into its upper case keyword and parameters.
+func splitKeyword(line string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	keyword := strings.ToUpper(parts[0])
+	if len(parts) == 1 {
+		return keyword, ""
+	}
+	return keyword, strings.TrimSpace(parts[1])
+}
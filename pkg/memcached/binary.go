@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrBinaryDecode = fmt.Errorf("binary decode")
+var ErrBinaryTruncated = fmt.Errorf("%w: truncated packet or connection is not memcached", ErrBinaryDecode)
+
+// Binary Protocol Magic Bytes
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+)
+
+// Binary Protocol Opcodes
+const (
+	OpcodeVersion       = 0x0b
+	OpcodeSASLListMechs = 0x20
+)
+
+// Binary Protocol Status Codes
+const (
+	StatusNoError        = 0x0000
+	StatusAuthError      = 0x0020
+	StatusUnknownCommand = 0x0081
+	StatusNotSupported   = 0x0083
+)
+
+// Size of the binary protocol header.
+const headerSize = 24
+
+// Maximum body length accepted for a single response.
+const maxBodyLength = 1 << 20
+
+// Response represents a binary protocol response packet.
+//
+// See https://github.com/memcached/memcached/wiki/BinaryProtocolRevamped
+type Response struct {
+	// Opcode is the command the response is for.
+	Opcode uint8
+
+	// Status is the response status, 0 on success.
+	Status uint16
+
+	// Opaque is echoed back from the request.
+	Opaque uint32
+
+	// Value is the response value (the body after any extras and key).
+	Value []byte
+}
+
+// EncodeRequest encodes a binary protocol request without extras, key or value.
+func EncodeRequest(opcode uint8, opaque uint32) []byte {
+	// Header Format
+	//	1 Byte: Magic
+	//	1 Byte: Opcode
+	//	2 Bytes: Key Length
+	//	1 Byte: Extras Length
+	//	1 Byte: Data Type
+	//	2 Bytes: VBucket ID
+	//	4 Bytes: Total Body Length
+	//	4 Bytes: Opaque
+	//	8 Bytes: CAS
+
+	buf := make([]byte, headerSize)
+	buf[0] = magicRequest
+	buf[1] = opcode
+	binary.BigEndian.PutUint32(buf[12:], opaque)
+	return buf
+}
+
+// ReadResponse attempts to read a binary protocol response from the given reader.
+func ReadResponse(r io.Reader) (*Response, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrBinaryTruncated, ErrBinaryDecode)
+	}
+	if header[0] != magicResponse {
+		return nil, fmt.Errorf("%w: invalid magic 0x%02x, connection is not memcached", ErrBinaryDecode, header[0])
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(header[2:]))
+	extrasLen := int(header[4])
+	bodyLen := int(binary.BigEndian.Uint32(header[8:]))
+
+	if bodyLen > maxBodyLength || keyLen+extrasLen > bodyLen {
+		return nil, fmt.Errorf("%w: invalid body length, connection is not memcached", ErrBinaryDecode)
+	}
+
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, wire.ReadError(err, ErrBinaryTruncated, ErrBinaryDecode)
+	}
+
+	return &Response{
+		Opcode: header[1],
+		Status: binary.BigEndian.Uint16(header[6:]),
+		Opaque: binary.BigEndian.Uint32(header[12:]),
+		Value:  body[keyLen+extrasLen:],
+	}, nil
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcached
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Report contains the information gathered from a memcached server.
+type Report struct {
+	// Version is the server version.
+	Version string `json:"version,omitempty"`
+
+	// TextProtocol indicates if the server answered over the text protocol.
+	TextProtocol bool `json:"text_protocol"`
+
+	// TextError contains the reason the text protocol failed, if it did.
+	TextError string `json:"text_error,omitempty"`
+
+	// BinaryProtocol indicates if the server answered over the binary protocol.
+	BinaryProtocol bool `json:"binary_protocol"`
+
+	// BinaryError contains the reason the binary protocol failed, if it did.
+	BinaryError string `json:"binary_error,omitempty"`
+
+	// Uptime is the number of seconds the server has been running.
+	Uptime *uint64 `json:"uptime,omitempty"`
+
+	// SASLEnabled indicates if SASL authentication is enabled.
+	SASLEnabled *bool `json:"sasl_enabled,omitempty"`
+
+	// SASLMechanisms lists the SASL mechanisms offered over the binary protocol.
+	SASLMechanisms []string `json:"sasl_mechanisms,omitempty"`
+
+	// UDPEnabled indicates if the server listens for UDP requests (udpport is not 0).
+	UDPEnabled *bool `json:"udp_enabled,omitempty"`
+
+	// UDPPort is the UDP port the server listens on.
+	UDPPort *int `json:"udp_port,omitempty"`
+
+	// Stats contains the output of the "stats" command.
+	Stats map[string]string `json:"stats,omitempty"`
+
+	// Settings contains the output of the "stats settings" command.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Probe inspects the server over both the text and binary protocols.
+// memcached detects the protocol from the first byte sent on a connection, so a separate connection is made for each.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{}
+
+	// (1) Binary Protocol
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	binErr := probeBinary(conn, report)
+	_ = conn.Close()
+
+	if binErr != nil {
+		report.BinaryError = binErr.Error()
+	}
+
+	// (2) Text Protocol
+
+	conn, err = dial()
+	if err != nil {
+		return nil, err
+	}
+	textErr := probeText(conn, report)
+	_ = conn.Close()
+
+	if textErr != nil {
+		report.TextError = textErr.Error()
+	}
+
+	if binErr != nil && textErr != nil {
+		return nil, textErr
+	}
+
+	return report, nil
+}
+
+// Sends the binary version and SASL list mechanisms commands.
+func probeBinary(rw io.ReadWriter, report *Report) error {
+	resp, err := binaryCommand(rw, OpcodeVersion)
+	if err != nil {
+		return err
+	}
+	if resp.Status != StatusNoError {
+		return fmt.Errorf("%w: version failed with status 0x%04x", ErrBinaryDecode, resp.Status)
+	}
+
+	report.BinaryProtocol = true
+	report.Version = string(resp.Value)
+
+	// Servers without SASL support respond with an unknown command status.
+
+	resp, err = binaryCommand(rw, OpcodeSASLListMechs)
+	if err != nil {
+		return err
+	}
+
+	enabled := resp.Status == StatusNoError
+	report.SASLEnabled = &enabled
+	if enabled {
+		report.SASLMechanisms = strings.Fields(string(resp.Value))
+	}
+
+	return nil
+}
+
+// Writes a binary request for the opcode and reads its response.
+func binaryCommand(rw io.ReadWriter, opcode uint8) (*Response, error) {
+	_, err := rw.Write(EncodeRequest(opcode, uint32(opcode)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ReadResponse(rw)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Opcode != opcode {
+		return nil, fmt.Errorf("%w: unexpected response opcode 0x%02x", ErrBinaryDecode, resp.Opcode)
+	}
+
+	return resp, nil
+}
+
+// Sends the text version, stats and stats settings commands.
+func probeText(rw io.ReadWriter, report *Report) error {
+	conn := NewTextConn(rw)
+
+	version, err := conn.Version()
+	if err != nil {
+		return err
+	}
+
+	report.TextProtocol = true
+	report.Version = version
+
+	report.Stats, err = conn.Stats("")
+	if err != nil {
+		return err
+	}
+	if uptime, err := strconv.ParseUint(report.Stats["uptime"], 10, 64); err == nil {
+		report.Uptime = &uptime
+	}
+
+	report.Settings, err = conn.Stats("settings")
+	if err != nil {
+		return err
+	}
+	if port, err := strconv.Atoi(report.Settings["udpport"]); err == nil {
+		enabled := port != 0
+		report.UDPPort = &port
+		report.UDPEnabled = &enabled
+	}
+	if sasl, ok := report.Settings["sasl"]; ok && report.SASLEnabled == nil {
+		enabled := sasl == "yes"
+		report.SASLEnabled = &enabled
+	}
+
+	return nil
+}
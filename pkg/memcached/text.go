@@ -0,0 +1,125 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memcached provides facilities for decoding and inspecting memcached text and binary protocol information.
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrTextDecode = fmt.Errorf("text decode")
+var ErrTextTruncated = fmt.Errorf("%w: truncated response or connection is not memcached", ErrTextDecode)
+
+// Maximum number of lines read for a single text command response.
+const maxLines = 1024
+
+// TextConn sends commands over the memcached text protocol.
+//
+// See https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+type TextConn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+// NewTextConn creates a TextConn on the given connection.
+func NewTextConn(rw io.ReadWriter) *TextConn {
+	return &TextConn{
+		w: rw,
+		r: bufio.NewReader(rw),
+	}
+}
+
+// Version sends the "version" command and returns the server version.
+func (c *TextConn) Version() (string, error) {
+	if err := c.send("version"); err != nil {
+		return "", err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "VERSION ") {
+		return "", fmt.Errorf("%w: unexpected response %q", ErrTextDecode, line)
+	}
+
+	return strings.TrimPrefix(line, "VERSION "), nil
+}
+
+// Stats sends the "stats" command with the given (optional) group, for example "settings",
+// and returns the reported statistics.
+func (c *TextConn) Stats(group string) (map[string]string, error) {
+	if err := c.send(strings.TrimSpace("stats " + group)); err != nil {
+		return nil, err
+	}
+
+	// Response Format
+	//	STAT <name> <value>\r\n
+	//	...
+	//	END\r\n
+
+	stats := map[string]string{}
+	for i := 0; i < maxLines; i++ {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return stats, nil
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 || fields[0] != "STAT" {
+			return nil, fmt.Errorf("%w: unexpected response %q", ErrTextDecode, line)
+		}
+
+		value := ""
+		if len(fields) == 3 {
+			value = fields[2]
+		}
+		stats[fields[1]] = value
+	}
+
+	return nil, fmt.Errorf("%w: too many stats", ErrTextDecode)
+}
+
+// Sends a single command line.
+func (c *TextConn) send(command string) error {
+	_, err := io.WriteString(c.w, command+"\r\n")
+	return err
+}
+
+// Reads a single response line, without the line terminator.
+// Error responses are returned as errors.
+func (c *TextConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", wire.ReadError(err, ErrTextTruncated, ErrTextDecode)
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", fmt.Errorf("%w: server responded %q", ErrTextDecode, line)
+	}
+
+	return line, nil
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package modbus
+
+import (
+	"fmt"
+)
+
+// MEI Type of the Read Device Identification request.
+const meiReadDeviceID = 0x0E
+
+// Read Device ID Codes
+const (
+	ReadDeviceIDBasic    = 0x01
+	ReadDeviceIDRegular  = 0x02
+	ReadDeviceIDExtended = 0x03
+)
+
+// Object IDs
+const (
+	ObjectVendorName          = 0x00
+	ObjectProductCode         = 0x01
+	ObjectMajorMinorRevision  = 0x02
+	ObjectVendorURL           = 0x03
+	ObjectProductName         = 0x04
+	ObjectModelName           = 0x05
+	ObjectUserApplicationName = 0x06
+)
+
+// DeviceIdentification is a decoded Read Device Identification response.
+type DeviceIdentification struct {
+	// ConformityLevel is the identification conformity level of the device.
+	ConformityLevel uint8
+
+	// MoreFollows indicates if further objects must be requested, starting at NextObjectID.
+	MoreFollows  bool
+	NextObjectID uint8
+
+	// Objects contains the object values keyed by object ID.
+	Objects map[uint8]string
+}
+
+// EncodeReadDeviceIdentification encodes the PDU data of a Read Device Identification request (function 43,
+// MEI type 14) for the given category of objects, starting at the given object.
+//
+// See https://modbus.org/docs/Modbus_Application_Protocol_V1_1b3.pdf section 6.21
+func EncodeReadDeviceIdentification(code, objectID uint8) []byte {
+	// Request
+	//	1 Byte: MEI Type (0x0E)
+	//	1 Byte: Read Device ID Code
+	//	1 Byte: Object ID
+
+	return []byte{meiReadDeviceID, code, objectID}
+}
+
+// DecodeDeviceIdentification decodes the PDU data of a Read Device Identification response.
+func DecodeDeviceIdentification(data []byte) (*DeviceIdentification, error) {
+	// Response
+	//	1 Byte: MEI Type (0x0E)
+	//	1 Byte: Read Device ID Code
+	//	1 Byte: Conformity Level
+	//	1 Byte: More Follows (0x00 / 0xFF)
+	//	1 Byte: Next Object ID
+	//	1 Byte: Number of Objects
+	//	Variable: Objects, each being
+	//		1 Byte: Object ID
+	//		1 Byte: Object Length
+	//		n Bytes: Object Value
+
+	if len(data) < 6 {
+		return nil, ErrFrameTruncated
+	}
+	if data[0] != meiReadDeviceID {
+		return nil, fmt.Errorf("%w: unexpected mei type 0x%02x", ErrFrameDecode, data[0])
+	}
+
+	id := &DeviceIdentification{
+		ConformityLevel: data[2],
+		MoreFollows:     data[3] == 0xFF,
+		NextObjectID:    data[4],
+		Objects:         map[uint8]string{},
+	}
+
+	pos := 6
+	for i := 0; i < int(data[5]); i++ {
+		if pos+2 > len(data) || pos+2+int(data[pos+1]) > len(data) {
+			return nil, ErrFrameTruncated
+		}
+		id.Objects[data[pos]] = string(data[pos+2 : pos+2+int(data[pos+1])])
+		pos += 2 + int(data[pos+1])
+	}
+
+	return id, nil
+}
+
+// ConformityLevelName returns the name of the conformity level, for example "regular (individual access)".
+func ConformityLevelName(level uint8) string {
+	var name string
+	switch level &^ 0x80 {
+	case ReadDeviceIDBasic:
+		name = "basic"
+	case ReadDeviceIDRegular:
+		name = "regular"
+	case ReadDeviceIDExtended:
+		name = "extended"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", level)
+	}
+
+	if level&0x80 != 0 {
+		name += " (individual access)"
+	}
+	return name
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package modbus provides facilities for probing Modbus/TCP devices and reading their identification.
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrFrameDecode = fmt.Errorf("frame decode")
+var ErrFrameTruncated = fmt.Errorf("%w: truncated frame or not a modbus device", ErrFrameDecode)
+
+// Size of the MBAP header.
+const mbapHeaderSize = 7
+
+// Largest PDU defined by the specification, limiting the length accepted from the MBAP header.
+const maxPDUSize = 253
+
+// Function Codes
+const (
+	FunctionEncapsulatedInterface = 0x2B
+)
+
+// Flag set on the function code of an exception response.
+const exceptionFlag = 0x80
+
+// ExceptionError is returned when the device responds with an exception.
+type ExceptionError struct {
+	Function uint8
+	Code     uint8
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("exception %s in response to function 0x%02x", ExceptionName(e.Code), e.Function)
+}
+
+// ExceptionName returns the name of the exception code, for example "ILLEGAL FUNCTION".
+func ExceptionName(code uint8) string {
+	switch code {
+	case 0x01:
+		return "ILLEGAL FUNCTION"
+	case 0x02:
+		return "ILLEGAL DATA ADDRESS"
+	case 0x03:
+		return "ILLEGAL DATA VALUE"
+	case 0x04:
+		return "SERVER DEVICE FAILURE"
+	case 0x05:
+		return "ACKNOWLEDGE"
+	case 0x06:
+		return "SERVER DEVICE BUSY"
+	case 0x08:
+		return "MEMORY PARITY ERROR"
+	case 0x0A:
+		return "GATEWAY PATH UNAVAILABLE"
+	case 0x0B:
+		return "GATEWAY TARGET DEVICE FAILED TO RESPOND"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", code)
+	}
+}
+
+// Frame is a Modbus/TCP application data unit.
+type Frame struct {
+	TransactionID uint16
+	UnitID        uint8
+	Function      uint8
+	Data          []byte
+}
+
+// EncodeFrame encodes a request with the given function code and data.
+//
+// See https://modbus.org/docs/Modbus_Messaging_Implementation_Guide_V1_0b.pdf
+func EncodeFrame(f Frame) []byte {
+	// MBAP Header
+	//	2 Bytes: Transaction Identifier
+	//	2 Bytes: Protocol Identifier (0)
+	//	2 Bytes: Length (Unit Identifier and PDU)
+	//	1 Byte: Unit Identifier
+	// PDU
+	//	1 Byte: Function Code
+	//	Variable: Data
+
+	buf := make([]byte, mbapHeaderSize+1, mbapHeaderSize+1+len(f.Data))
+	binary.BigEndian.PutUint16(buf[0:], f.TransactionID)
+	binary.BigEndian.PutUint16(buf[4:], uint16(2+len(f.Data)))
+	buf[6] = f.UnitID
+	buf[7] = f.Function
+	return append(buf, f.Data...)
+}
+
+// ReadFrame reads a response frame from the reader.
+// An ExceptionError is returned if the response is an exception.
+func ReadFrame(r io.Reader) (*Frame, error) {
+	header := make([]byte, mbapHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	// Anything other than Modbus is unlikely to have both a zero protocol identifier and a sane length.
+
+	if binary.BigEndian.Uint16(header[2:]) != 0 {
+		return nil, fmt.Errorf("%w: invalid protocol identifier, device is not modbus", ErrFrameDecode)
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || length > 1+maxPDUSize {
+		return nil, fmt.Errorf("%w: invalid length %d, device is not modbus", ErrFrameDecode, length)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(r, pdu); err != nil {
+		return nil, wire.ReadError(err, ErrFrameTruncated, ErrFrameDecode)
+	}
+
+	f := &Frame{
+		TransactionID: binary.BigEndian.Uint16(header[0:]),
+		UnitID:        header[6],
+		Function:      pdu[0],
+		Data:          pdu[1:],
+	}
+
+	if f.Function&exceptionFlag != 0 {
+		if len(f.Data) < 1 {
+			return nil, ErrFrameTruncated
+		}
+		return nil, &ExceptionError{Function: f.Function &^ exceptionFlag, Code: f.Data[0]}
+	}
+	return f, nil
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+// Maximum number of requests made for a single category of objects.
+const maxRequests = 8
+
+// Report contains the identification read from a Modbus/TCP device.
+type Report struct {
+	// UnitID is the unit identifier the requests were addressed to.
+	UnitID uint8 `json:"unit_id"`
+
+	// Exception is the exception the device responded to Read Device Identification with, if it did.
+	// Devices which don't implement the function still respond with ILLEGAL FUNCTION.
+	Exception string `json:"exception,omitempty"`
+
+	// ConformityLevel is the identification conformity level of the device, for example "regular".
+	ConformityLevel string `json:"conformity_level,omitempty"`
+
+	// VendorName, ProductCode and Revision are the basic device identification objects.
+	VendorName  string `json:"vendor_name,omitempty"`
+	ProductCode string `json:"product_code,omitempty"`
+	Revision    string `json:"revision,omitempty"`
+
+	// VendorURL, ProductName, ModelName and UserApplicationName are the regular device identification objects.
+	VendorURL           string `json:"vendor_url,omitempty"`
+	ProductName         string `json:"product_name,omitempty"`
+	ModelName           string `json:"model_name,omitempty"`
+	UserApplicationName string `json:"user_application_name,omitempty"`
+
+	// RegularError contains the reason reading the regular objects failed, if it did.
+	RegularError string `json:"regular_error,omitempty"`
+}
+
+// Probe reads the basic device identification objects of the unit, followed by the regular objects if the
+// device's conformity level includes them. The delay is waited between each request, to avoid overloading
+// devices which are often unable to handle much traffic, after which the connection deadline is reset to the
+// timeout.
+func Probe(conn net.Conn, unitID uint8, delay, timeout time.Duration) (*Report, error) {
+	p := &prober{conn: conn, unitID: unitID, delay: delay, timeout: timeout}
+	report := &Report{UnitID: unitID}
+
+	// (1) Basic Device Identification
+
+	objects, level, err := p.readObjects(ReadDeviceIDBasic, ObjectVendorName)
+	if err != nil {
+		var exception *ExceptionError
+		if errors.As(err, &exception) {
+			report.Exception = ExceptionName(exception.Code)
+			return report, nil
+		}
+		return nil, err
+	}
+	report.ConformityLevel = ConformityLevelName(level)
+
+	// (2) Regular Device Identification
+
+	if _, ok := objects[ObjectVendorURL]; !ok && level&^0x80 >= ReadDeviceIDRegular {
+		regular, _, err := p.readObjects(ReadDeviceIDRegular, ObjectVendorURL)
+		if err != nil {
+			report.RegularError = err.Error()
+		}
+		for id, value := range regular {
+			objects[id] = value
+		}
+	}
+
+	report.VendorName = objects[ObjectVendorName]
+	report.ProductCode = objects[ObjectProductCode]
+	report.Revision = objects[ObjectMajorMinorRevision]
+	report.VendorURL = objects[ObjectVendorURL]
+	report.ProductName = objects[ObjectProductName]
+	report.ModelName = objects[ObjectModelName]
+	report.UserApplicationName = objects[ObjectUserApplicationName]
+
+	return report, nil
+}
+
+// Sends requests to a single unit, pacing them by the delay.
+type prober struct {
+	conn          net.Conn
+	unitID        uint8
+	delay         time.Duration
+	timeout       time.Duration
+	transactionID uint16
+}
+
+// Reads the objects of the category starting at the given object, following the more follows indication when
+// the objects don't fit in a single response. The objects read so far are returned along with any error.
+func (p *prober) readObjects(code, objectID uint8) (map[uint8]string, uint8, error) {
+	objects := map[uint8]string{}
+	var level uint8
+
+	for i := 0; i < maxRequests; i++ {
+		data, err := p.request(FunctionEncapsulatedInterface, EncodeReadDeviceIdentification(code, objectID))
+		if err != nil {
+			return objects, level, err
+		}
+		id, err := DecodeDeviceIdentification(data)
+		if err != nil {
+			return objects, level, err
+		}
+
+		level = id.ConformityLevel
+		for id, value := range id.Objects {
+			objects[id] = value
+		}
+
+		if !id.MoreFollows || id.NextObjectID <= objectID {
+			return objects, level, nil
+		}
+		objectID = id.NextObjectID
+	}
+
+	return objects, level, nil
+}
+
+// Sends a request, after the delay if it isn't the first, and returns the data of the response.
+func (p *prober) request(function uint8, data []byte) ([]byte, error) {
+	if p.transactionID > 0 {
+		if err := wire.Pause(p.conn, p.delay, p.timeout); err != nil {
+			return nil, err
+		}
+	}
+	p.transactionID++
+
+	_, err := p.conn.Write(EncodeFrame(Frame{
+		TransactionID: p.transactionID,
+		UnitID:        p.unitID,
+		Function:      function,
+		Data:          data,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ReadFrame(p.conn)
+	if err != nil {
+		return nil, err
+	}
+	if f.TransactionID != p.transactionID || f.Function != function {
+		return nil, fmt.Errorf("%w: unexpected response to transaction %d", ErrFrameDecode, p.transactionID)
+	}
+	return f.Data, nil
+}
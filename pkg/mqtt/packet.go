@@ -0,0 +1,330 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mqtt provides facilities for probing and inspecting MQTT brokers.
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or not an mqtt connack", ErrPacketDecode)
+
+// Protocol Levels
+const (
+	Level311 = 4
+	Level5   = 5
+)
+
+// Control Packet Types (upper nibble of the fixed header)
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetDisconnect = 0xE0
+)
+
+// Connect Flags
+const (
+	flagCleanSession = 0x02
+)
+
+// Keep alive (in seconds) sent in the CONNECT packet.
+const keepAlive = 60
+
+// Maximum remaining length accepted for a CONNACK.
+const maxRemainingLength = 1 << 16
+
+// Connack represents a CONNACK packet.
+type Connack struct {
+	// SessionPresent indicates if the broker resumed an existing session.
+	SessionPresent bool `json:"session_present"`
+
+	// Code is the return code (3.1.1) or reason code (5.0).
+	Code uint8 `json:"code"`
+
+	// Properties contains the CONNACK properties (5.0 only).
+	Properties *Properties `json:"properties,omitempty"`
+}
+
+// Properties contains the decoded MQTT 5.0 CONNACK properties.
+// Properties not sent by the broker are left nil.
+type Properties struct {
+	SessionExpiryInterval    *uint32           `json:"session_expiry_interval,omitempty"`
+	ReceiveMaximum           *uint16           `json:"receive_maximum,omitempty"`
+	MaximumQoS               *uint8            `json:"maximum_qos,omitempty"`
+	RetainAvailable          *bool             `json:"retain_available,omitempty"`
+	MaximumPacketSize        *uint32           `json:"maximum_packet_size,omitempty"`
+	AssignedClientIdentifier string            `json:"assigned_client_identifier,omitempty"`
+	TopicAliasMaximum        *uint16           `json:"topic_alias_maximum,omitempty"`
+	ReasonString             string            `json:"reason_string,omitempty"`
+	UserProperties           map[string]string `json:"user_properties,omitempty"`
+	WildcardSubscription     *bool             `json:"wildcard_subscription_available,omitempty"`
+	SubscriptionIdentifiers  *bool             `json:"subscription_identifiers_available,omitempty"`
+	SharedSubscription       *bool             `json:"shared_subscription_available,omitempty"`
+	ServerKeepAlive          *uint16           `json:"server_keep_alive,omitempty"`
+	ResponseInformation      string            `json:"response_information,omitempty"`
+	ServerReference          string            `json:"server_reference,omitempty"`
+	AuthenticationMethod     string            `json:"authentication_method,omitempty"`
+}
+
+// EncodeConnect encodes a CONNECT packet for the given protocol level without credentials.
+//
+// See http://docs.oasis-open.org/mqtt/mqtt/v3.1.1/os/mqtt-v3.1.1-os.html#_Toc398718028
+// and https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901033
+func EncodeConnect(level uint8, clientID string) []byte {
+	// Variable Header
+	//	Protocol Name ("MQTT"), Protocol Level, Connect Flags, Keep Alive
+	//	Properties (5.0 only, none sent)
+
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, level, flagCleanSession, 0, keepAlive)
+	if level >= Level5 {
+		body = appendVarint(body, 0)
+	}
+
+	// Payload
+	//	Client Identifier
+
+	body = appendString(body, clientID)
+
+	packet := []byte{packetConnect}
+	packet = appendVarint(packet, len(body))
+	return append(packet, body...)
+}
+
+// EncodeDisconnect encodes a DISCONNECT packet.
+func EncodeDisconnect() []byte {
+	return []byte{packetDisconnect, 0}
+}
+
+// ReadConnack reads a CONNACK packet from the reader.
+// The packet is decoded as a 5.0 CONNACK if the broker sends properties, regardless of the level requested,
+// since brokers refusing the level respond in the format of the level they support.
+func ReadConnack(r io.Reader) (*Connack, error) {
+	// Fixed Header
+	//	1 Byte: Packet Type + Flags
+	//	Variable: Remaining Length
+
+	typ := make([]byte, 1)
+	if _, err := io.ReadFull(r, typ); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	if typ[0]&0xF0 != packetConnack {
+		return nil, fmt.Errorf("%w: unexpected packet type 0x%02x, connection is not mqtt", ErrPacketDecode, typ[0])
+	}
+
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length < 2 || length > maxRemainingLength {
+		return nil, fmt.Errorf("%w: invalid remaining length, connection is not mqtt", ErrPacketDecode)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+
+	// Variable Header
+	//	1 Byte: Acknowledge Flags
+	//	1 Byte: Return / Reason Code
+	//	Properties (5.0 only)
+
+	ack := &Connack{
+		SessionPresent: body[0]&0x01 != 0,
+		Code:           body[1],
+	}
+
+	if len(body) > 2 {
+		ack.Properties, err = decodeProperties(body[2:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ack, nil
+}
+
+// Decodes the 5.0 CONNACK properties: a variable byte integer length followed by identifier/value pairs.
+func decodeProperties(b []byte) (*Properties, error) {
+	length, n := decodeVarint(b)
+	if n == 0 || n+length > len(b) {
+		return nil, ErrPacketTruncated
+	}
+	b = b[n : n+length]
+
+	props := &Properties{}
+	for len(b) > 0 {
+		id := b[0]
+		b = b[1:]
+
+		var err error
+		switch id {
+		case 0x11:
+			props.SessionExpiryInterval, b, err = readUint32(b)
+		case 0x21:
+			props.ReceiveMaximum, b, err = readUint16(b)
+		case 0x24:
+			props.MaximumQoS, b, err = readUint8(b)
+		case 0x25:
+			props.RetainAvailable, b, err = readBool(b)
+		case 0x27:
+			props.MaximumPacketSize, b, err = readUint32(b)
+		case 0x12:
+			props.AssignedClientIdentifier, b, err = readString(b)
+		case 0x22:
+			props.TopicAliasMaximum, b, err = readUint16(b)
+		case 0x1F:
+			props.ReasonString, b, err = readString(b)
+		case 0x26:
+			var k, v string
+			k, b, err = readString(b)
+			if err == nil {
+				v, b, err = readString(b)
+			}
+			if props.UserProperties == nil {
+				props.UserProperties = map[string]string{}
+			}
+			props.UserProperties[k] = v
+		case 0x28:
+			props.WildcardSubscription, b, err = readBool(b)
+		case 0x29:
+			props.SubscriptionIdentifiers, b, err = readBool(b)
+		case 0x2A:
+			props.SharedSubscription, b, err = readBool(b)
+		case 0x13:
+			props.ServerKeepAlive, b, err = readUint16(b)
+		case 0x1A:
+			props.ResponseInformation, b, err = readString(b)
+		case 0x1C:
+			props.ServerReference, b, err = readString(b)
+		case 0x15:
+			props.AuthenticationMethod, b, err = readString(b)
+		case 0x16:
+			// Authentication Data: binary data, not reported.
+			_, b, err = readString(b)
+		default:
+			return nil, fmt.Errorf("%w: unknown property 0x%02x", ErrPacketDecode, id)
+		}
+		if err != nil {
+			return nil, ErrPacketTruncated
+		}
+	}
+
+	return props, nil
+}
+
+// Appends a UTF-8 encoded string: a 2 byte length followed by the bytes.
+func appendString(b []byte, s string) []byte {
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(s)))
+	return append(append(b, l...), s...)
+}
+
+// Appends a variable byte integer.
+func appendVarint(b []byte, v int) []byte {
+	for {
+		digit := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// Reads a variable byte integer (at most 4 bytes) from the reader.
+func readVarint(r io.Reader) (int, error) {
+	buf := make([]byte, 1)
+	value, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+		}
+		value += int(buf[0]&0x7F) * multiplier
+		if buf[0]&0x80 == 0 {
+			return value, nil
+		}
+		multiplier *= 128
+	}
+	return 0, fmt.Errorf("%w: malformed remaining length, connection is not mqtt", ErrPacketDecode)
+}
+
+// Decodes a variable byte integer, returning the value and number of bytes read (0 if malformed).
+func decodeVarint(b []byte) (int, int) {
+	value, multiplier := 0, 1
+	for i := 0; i < 4 && i < len(b); i++ {
+		value += int(b[i]&0x7F) * multiplier
+		if b[i]&0x80 == 0 {
+			return value, i + 1
+		}
+		multiplier *= 128
+	}
+	return 0, 0
+}
+
+func readUint8(b []byte) (*uint8, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, ErrPacketTruncated
+	}
+	v := b[0]
+	return &v, b[1:], nil
+}
+
+func readBool(b []byte) (*bool, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, ErrPacketTruncated
+	}
+	v := b[0] != 0
+	return &v, b[1:], nil
+}
+
+func readUint16(b []byte) (*uint16, []byte, error) {
+	if len(b) < 2 {
+		return nil, nil, ErrPacketTruncated
+	}
+	v := binary.BigEndian.Uint16(b)
+	return &v, b[2:], nil
+}
+
+func readUint32(b []byte) (*uint32, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, ErrPacketTruncated
+	}
+	v := binary.BigEndian.Uint32(b)
+	return &v, b[4:], nil
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, ErrPacketTruncated
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, ErrPacketTruncated
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
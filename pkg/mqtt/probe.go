@@ -0,0 +1,211 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// ClientID is the client identifier sent in the CONNECT packet.
+// An empty identifier is valid, but rejected by many brokers, so a fixed one is used instead.
+const ClientID = "protoscan"
+
+// Report contains the information gathered from an MQTT broker.
+type Report struct {
+	// Anonymous indicates if the broker accepted a connection without credentials at any protocol level.
+	Anonymous bool `json:"anonymous"`
+
+	// MQTT311 contains the MQTT 3.1.1 results, if the broker responded.
+	MQTT311 *Result `json:"mqtt_3_1_1,omitempty"`
+
+	// MQTT311Error contains the reason the MQTT 3.1.1 probe failed, if it did.
+	MQTT311Error string `json:"mqtt_3_1_1_error,omitempty"`
+
+	// MQTT5 contains the MQTT 5.0 results, if the broker responded.
+	MQTT5 *Result `json:"mqtt_5,omitempty"`
+
+	// MQTT5Error contains the reason the MQTT 5.0 probe failed, if it did.
+	MQTT5Error string `json:"mqtt_5_error,omitempty"`
+}
+
+// Result contains the broker's response to a single CONNECT.
+type Result struct {
+	// Accepted indicates if the broker accepted the connection.
+	Accepted bool `json:"accepted"`
+
+	// Code is the return code (3.1.1) or reason code (5.0).
+	Code uint8 `json:"code"`
+
+	// Reason is the name of the return or reason code.
+	Reason string `json:"reason"`
+
+	// SessionPresent indicates if the broker resumed an existing session.
+	SessionPresent bool `json:"session_present"`
+
+	// Properties contains the CONNACK properties (5.0 only).
+	Properties *Properties `json:"properties,omitempty"`
+}
+
+// Probe sends an MQTT 3.1.1 and an MQTT 5.0 CONNECT without credentials, each on its own connection since
+// brokers close the connection after refusing a CONNECT.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{}
+
+	// (1) MQTT 3.1.1
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	report.MQTT311, err = probeLevel(conn, Level311)
+	_ = conn.Close()
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, err
+		}
+		report.MQTT311Error = err.Error()
+	}
+
+	// (2) MQTT 5.0
+
+	conn, err = dial()
+	if err != nil {
+		return nil, err
+	}
+	report.MQTT5, err = probeLevel(conn, Level5)
+	_ = conn.Close()
+	if err != nil {
+		report.MQTT5Error = err.Error()
+	}
+
+	if report.MQTT311 == nil && report.MQTT5 == nil {
+		return nil, err
+	}
+
+	report.Anonymous = (report.MQTT311 != nil && report.MQTT311.Accepted) ||
+		(report.MQTT5 != nil && report.MQTT5.Accepted)
+
+	return report, nil
+}
+
+// Sends a CONNECT at the given protocol level and decodes the CONNACK.
+// Accepted connections are closed cleanly with a DISCONNECT.
+func probeLevel(conn net.Conn, level uint8) (*Result, error) {
+	if _, err := conn.Write(EncodeConnect(level, ClientID)); err != nil {
+		return nil, err
+	}
+
+	ack, err := ReadConnack(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Brokers which don't support 5.0 respond in the 3.1.1 format, without properties,
+	// so the codes are named according to the format of the response rather than the level requested.
+	result := &Result{
+		Accepted:       ack.Code == 0,
+		Code:           ack.Code,
+		SessionPresent: ack.SessionPresent,
+		Properties:     ack.Properties,
+	}
+	if level >= Level5 && ack.Properties != nil {
+		result.Reason = ReasonCodeName(ack.Code)
+	} else {
+		result.Reason = ReturnCodeName(ack.Code)
+	}
+
+	if result.Accepted {
+		_, _ = conn.Write(EncodeDisconnect())
+	}
+
+	return result, nil
+}
+
+// ReturnCodeName returns the name of an MQTT 3.1.1 CONNACK return code.
+func ReturnCodeName(code uint8) string {
+	switch code {
+	case 0x00:
+		return "Connection Accepted"
+	case 0x01:
+		return "Unacceptable Protocol Version"
+	case 0x02:
+		return "Identifier Rejected"
+	case 0x03:
+		return "Server Unavailable"
+	case 0x04:
+		return "Bad User Name or Password"
+	case 0x05:
+		return "Not Authorized"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", code)
+	}
+}
+
+// ReasonCodeName returns the name of an MQTT 5.0 CONNACK reason code.
+func ReasonCodeName(code uint8) string {
+	switch code {
+	case 0x00:
+		return "Success"
+	case 0x80:
+		return "Unspecified Error"
+	case 0x81:
+		return "Malformed Packet"
+	case 0x82:
+		return "Protocol Error"
+	case 0x83:
+		return "Implementation Specific Error"
+	case 0x84:
+		return "Unsupported Protocol Version"
+	case 0x85:
+		return "Client Identifier Not Valid"
+	case 0x86:
+		return "Bad User Name or Password"
+	case 0x87:
+		return "Not Authorized"
+	case 0x88:
+		return "Server Unavailable"
+	case 0x89:
+		return "Server Busy"
+	case 0x8A:
+		return "Banned"
+	case 0x8C:
+		return "Bad Authentication Method"
+	case 0x90:
+		return "Topic Name Invalid"
+	case 0x95:
+		return "Packet Too Large"
+	case 0x97:
+		return "Quota Exceeded"
+	case 0x99:
+		return "Payload Format Invalid"
+	case 0x9A:
+		return "Retain Not Supported"
+	case 0x9B:
+		return "QoS Not Supported"
+	case 0x9C:
+		return "Use Another Server"
+	case 0x9D:
+		return "Server Moved"
+	case 0x9F:
+		return "Connection Rate Exceeded"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", code)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mssql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrBrowserDecode = fmt.Errorf("browser decode")
+var ErrBrowserTruncated = fmt.Errorf("%w: truncated payload or not a sql browser response", ErrBrowserDecode)
+
+// SQL Server Resolution Protocol Message Types
+const (
+	browserClientUnicastEx = 0x03
+	browserServerResponse  = 0x05
+)
+
+// Instance describes a single named instance reported by the SQL Server Browser service.
+type Instance struct {
+	// ServerName is the name of the server hosting the instance.
+	ServerName string `json:"server_name"`
+
+	// InstanceName is the name of the instance, MSSQLSERVER for the default instance.
+	InstanceName string `json:"instance_name"`
+
+	// IsClustered indicates if the instance is part of a failover cluster.
+	IsClustered bool `json:"is_clustered"`
+
+	// Version is the instance version string.
+	Version string `json:"version"`
+
+	// TCPPort is the TCP port the instance listens on, if the TCP protocol is enabled.
+	TCPPort string `json:"tcp_port,omitempty"`
+
+	// NamedPipe is the named pipe the instance listens on, if the named pipes protocol is enabled.
+	NamedPipe string `json:"named_pipe,omitempty"`
+
+	// Properties contains every key/value pair reported for the instance.
+	Properties map[string]string `json:"properties"`
+}
+
+// EncodeBrowserRequest encodes a CLNT_UCAST_EX request, asking the browser service to enumerate all instances.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-sqlr/ee0e41b0-204c-40d9-9fe3-d1b6c44e6571
+func EncodeBrowserRequest() []byte {
+	return []byte{browserClientUnicastEx}
+}
+
+// DecodeBrowserResponse attempts to decode the given datagram as a SVR_RESP message.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-sqlr/2e1560c9-5097-4023-9f5e-72b9ff1ec3b1
+func DecodeBrowserResponse(datagram []byte) ([]Instance, error) {
+	// 1 Byte: Message Type
+
+	sub, pos, err := wire.ReadBuffer(datagram, 0, 1)
+	if err != nil {
+		return nil, ErrBrowserTruncated
+	}
+	if sub[0] != browserServerResponse {
+		return nil, fmt.Errorf("%w: unexpected message type 0x%02x", ErrBrowserDecode, sub[0])
+	}
+
+	// 2 Bytes: Response Size (Little Endian)
+
+	sub, pos, err = wire.ReadBuffer(datagram, pos, 2)
+	if err != nil {
+		return nil, ErrBrowserTruncated
+	}
+	size := int(binary.LittleEndian.Uint16(sub))
+
+	// Variable: Response Data
+	//	Instances are separated by ";;", each one being a list of ";" separated key/value pairs.
+
+	sub, _, err = wire.ReadBuffer(datagram, pos, size)
+	if err != nil {
+		return nil, ErrBrowserTruncated
+	}
+
+	instances := []Instance{}
+	for _, record := range strings.Split(string(sub), ";;") {
+		if record == "" {
+			continue
+		}
+
+		fields := strings.Split(record, ";")
+		inst := Instance{
+			Properties: map[string]string{},
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			inst.Properties[fields[i]] = fields[i+1]
+		}
+
+		inst.ServerName = inst.Properties["ServerName"]
+		inst.InstanceName = inst.Properties["InstanceName"]
+		inst.IsClustered = strings.EqualFold(inst.Properties["IsClustered"], "Yes")
+		inst.Version = inst.Properties["Version"]
+		inst.TCPPort = inst.Properties["tcp"]
+		inst.NamedPipe = inst.Properties["np"]
+
+		instances = append(instances, inst)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mssql provides facilities for decoding and inspecting Microsoft SQL Server
+// Tabular Data Stream (TDS) and SQL Server Browser protocol information.
+package mssql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or connection is not tds", ErrPacketDecode)
+
+// PacketType identifies the kind of message carried by a TDS packet.
+type PacketType uint8
+
+// Packet Types
+const (
+	PacketTypeTabularResult PacketType = 0x04
+	PacketTypePrelogin      PacketType = 0x12
+)
+
+// Packet Status Flags
+const (
+	statusEOM = 0x01
+)
+
+// Size of the TDS packet header.
+const headerSize = 8
+
+// Packet represents a (possibly reassembled) TDS message.
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/7af53667-1b72-4703-8258-7984e838f746
+type Packet struct {
+	// Type is the packet type.
+	Type PacketType
+
+	// Payload of the message.
+	Payload []byte
+}
+
+// Encode encodes the packet as a single TDS packet, ready to be written to a connection.
+func (p *Packet) Encode() []byte {
+	// Packet Format
+	//	1 Byte: Type
+	//	1 Byte: Status
+	//	2 Bytes: Length (Big Endian, including header)
+	//	2 Bytes: SPID
+	//	1 Byte: Packet ID
+	//	1 Byte: Window
+	//	PAYLOAD
+
+	buf := make([]byte, headerSize, headerSize+len(p.Payload))
+	buf[0] = byte(p.Type)
+	buf[1] = statusEOM
+	binary.BigEndian.PutUint16(buf[2:], uint16(headerSize+len(p.Payload)))
+	buf[6] = 1
+
+	return append(buf, p.Payload...)
+}
+
+// ReadPacket attempts to read a TDS message from the given reader.
+// Messages split across several packets are reassembled until the end of message status is seen.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	p := &Packet{}
+
+	for {
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+		}
+
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < headerSize {
+			return nil, fmt.Errorf("%w: invalid packet length, connection is not tds", ErrPacketDecode)
+		}
+
+		buf := make([]byte, length-headerSize)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+		}
+
+		p.Type = PacketType(header[0])
+		p.Payload = append(p.Payload, buf...)
+
+		if header[1]&statusEOM != 0 {
+			return p, nil
+		}
+	}
+}
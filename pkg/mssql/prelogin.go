@@ -0,0 +1,226 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mssql
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPreloginDecode = fmt.Errorf("prelogin decode")
+var ErrPreloginTruncated = fmt.Errorf("%w: truncated payload or not a tds prelogin response", ErrPreloginDecode)
+
+// PRELOGIN Option Tokens
+const (
+	optionVersion         = 0x00
+	optionEncryption      = 0x01
+	optionInstOpt         = 0x02
+	optionThreadID        = 0x03
+	optionMARS            = 0x04
+	optionTraceID         = 0x05
+	optionFedAuthRequired = 0x06
+	optionTerminator      = 0xFF
+)
+
+// Encryption is the encryption mode negotiated during PRELOGIN.
+type Encryption uint8
+
+// Encryption Modes
+const (
+	EncryptionOff          Encryption = 0x00
+	EncryptionOn           Encryption = 0x01
+	EncryptionNotSupported Encryption = 0x02
+	EncryptionRequired     Encryption = 0x03
+)
+
+func (e Encryption) String() string {
+	switch e {
+	case EncryptionOff:
+		return "ENCRYPT_OFF"
+	case EncryptionOn:
+		return "ENCRYPT_ON"
+	case EncryptionNotSupported:
+		return "ENCRYPT_NOT_SUP"
+	case EncryptionRequired:
+		return "ENCRYPT_REQ"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(e))
+	}
+}
+
+func (e Encryption) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// Version is the SQL Server version reported in the PRELOGIN response.
+type Version struct {
+	Major    uint8  `json:"major"`
+	Minor    uint8  `json:"minor"`
+	Build    uint16 `json:"build"`
+	SubBuild uint16 `json:"sub_build"`
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Build)
+}
+
+// Prelogin represents the server's PRELOGIN response.
+// Options not sent by the server are left nil.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/60f56408-0188-4cd5-8b90-25c6f2423868
+type Prelogin struct {
+	// Version is the server version.
+	Version *Version `json:"version,omitempty"`
+
+	// Encryption is the encryption mode the server selected.
+	Encryption *Encryption `json:"encryption,omitempty"`
+
+	// InstOpt is the instance validation result, 0 if the requested instance matched.
+	InstOpt *uint8 `json:"instopt,omitempty"`
+
+	// ThreadID is the server thread ID, rarely sent by servers.
+	ThreadID *uint32 `json:"thread_id,omitempty"`
+
+	// MARS indicates if Multiple Active Result Sets are enabled.
+	MARS *bool `json:"mars,omitempty"`
+
+	// FedAuthRequired indicates if the server requires federated authentication.
+	FedAuthRequired *bool `json:"fed_auth_required,omitempty"`
+}
+
+// EncodePrelogin encodes a client PRELOGIN message.
+// The client requests no encryption and no MARS, so the server's response reflects its own configuration.
+func EncodePrelogin() *Packet {
+	type option struct {
+		token byte
+		data  []byte
+	}
+
+	options := []option{
+		{optionVersion, make([]byte, 6)},
+		{optionEncryption, []byte{byte(EncryptionOff)}},
+		{optionInstOpt, []byte{0}},
+		{optionThreadID, make([]byte, 4)},
+		{optionMARS, []byte{0}},
+		{optionFedAuthRequired, []byte{1}},
+	}
+
+	// Option Tokens
+	//	1 Byte: Token
+	//	2 Bytes: Data Offset (Big Endian, relative to payload start)
+	//	2 Bytes: Data Length (Big Endian)
+	//	... followed by a single terminator byte and the option data.
+
+	offset := len(options)*5 + 1
+
+	var tokens, data []byte
+	for _, opt := range options {
+		tok := make([]byte, 5)
+		tok[0] = opt.token
+		binary.BigEndian.PutUint16(tok[1:], uint16(offset+len(data)))
+		binary.BigEndian.PutUint16(tok[3:], uint16(len(opt.data)))
+
+		tokens = append(tokens, tok...)
+		data = append(data, opt.data...)
+	}
+	tokens = append(tokens, optionTerminator)
+
+	return &Packet{
+		Type:    PacketTypePrelogin,
+		Payload: append(tokens, data...),
+	}
+}
+
+// DecodePrelogin attempts to decode the given series of bytes as a PRELOGIN response payload.
+func DecodePrelogin(payload []byte) (*Prelogin, error) {
+	pl := &Prelogin{}
+
+	pos := 0
+	for {
+		// 1 Byte: Token
+
+		sub, next, err := wire.ReadBuffer(payload, pos, 1)
+		if err != nil {
+			return nil, ErrPreloginTruncated
+		}
+		token := sub[0]
+		if token == optionTerminator {
+			return pl, nil
+		}
+
+		// 4 Bytes: Offset + Length
+
+		sub, next, err = wire.ReadBuffer(payload, next, 4)
+		if err != nil {
+			return nil, ErrPreloginTruncated
+		}
+		pos = next
+
+		offset := int(binary.BigEndian.Uint16(sub))
+		length := int(binary.BigEndian.Uint16(sub[2:]))
+
+		data, _, err := wire.ReadBuffer(payload, offset, length)
+		if err != nil {
+			return nil, ErrPreloginTruncated
+		}
+
+		// Option Data
+		//	Options with an unexpected length are skipped rather than rejected.
+
+		switch token {
+		case optionVersion:
+			if length >= 6 {
+				pl.Version = &Version{
+					Major:    data[0],
+					Minor:    data[1],
+					Build:    binary.BigEndian.Uint16(data[2:]),
+					SubBuild: binary.BigEndian.Uint16(data[4:]),
+				}
+			}
+		case optionEncryption:
+			if length >= 1 {
+				enc := Encryption(data[0])
+				pl.Encryption = &enc
+			}
+		case optionInstOpt:
+			if length >= 1 {
+				opt := data[0]
+				pl.InstOpt = &opt
+			}
+		case optionThreadID:
+			if length >= 4 {
+				id := binary.BigEndian.Uint32(data)
+				pl.ThreadID = &id
+			}
+		case optionMARS:
+			if length >= 1 {
+				mars := data[0] != 0
+				pl.MARS = &mars
+			}
+		case optionFedAuthRequired:
+			if length >= 1 {
+				required := data[0] != 0
+				pl.FedAuthRequired = &required
+			}
+		case optionTraceID:
+			// Client only, ignored.
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mssql
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/udp"
+)
+
+// Report contains the information gathered from a SQL Server PRELOGIN exchange.
+type Report struct {
+	// Version is the human readable server version (major.minor.build).
+	Version string `json:"version,omitempty"`
+
+	// Prelogin is the decoded PRELOGIN response.
+	Prelogin *Prelogin `json:"prelogin"`
+}
+
+// Probe sends a PRELOGIN message over the connection and decodes the server's response.
+func Probe(conn io.ReadWriter) (*Report, error) {
+	_, err := conn.Write(EncodePrelogin().Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := ReadPacket(conn)
+	if err != nil {
+		return nil, err
+	}
+	if packet.Type != PacketTypeTabularResult {
+		return nil, fmt.Errorf("%w: unexpected packet type 0x%02x", ErrPreloginDecode, uint8(packet.Type))
+	}
+
+	pl, err := DecodePrelogin(packet.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Prelogin: pl,
+	}
+	if pl.Version != nil {
+		report.Version = pl.Version.String()
+	}
+
+	return report, nil
+}
+
+// BrowserReport contains the instances enumerated from the SQL Server Browser service.
+type BrowserReport struct {
+	// Instances lists every instance reported by the service.
+	Instances []Instance `json:"instances"`
+}
+
+// ProbeBrowser sends an enumeration request over the UDP transport and decodes the response.
+func ProbeBrowser(t *udp.Transport) (*BrowserReport, error) {
+	datagram, err := t.Exchange(EncodeBrowserRequest(), func(datagram []byte) bool {
+		return len(datagram) > 0 && datagram[0] == browserServerResponse
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := DecodeBrowserResponse(datagram)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BrowserReport{
+		Instances: instances,
+	}, nil
+}
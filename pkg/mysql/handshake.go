@@ -20,6 +20,8 @@ package mysql
 import (
 	"encoding/binary"
 	"fmt"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
 )
 
 var ErrHandshakeDecode = fmt.Errorf("handshake decode")
@@ -122,7 +124,7 @@ func decodeHandshakeV9(payload []byte) (*HandshakeV9, error) {
 
 	// Variable: Server Version (NULL-Terminated)
 
-	sub, pos, err := readBuffer(payload, 1, nullTermStringLen(payload[1:]))
+	sub, pos, err := wire.ReadBuffer(payload, 1, nullTermStringLen(payload[1:]))
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -131,7 +133,7 @@ func decodeHandshakeV9(payload []byte) (*HandshakeV9, error) {
 
 	// 4 Bytes: Thread ID
 
-	sub, pos, err = readBuffer(payload, pos, 4)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 4)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -139,7 +141,7 @@ func decodeHandshakeV9(payload []byte) (*HandshakeV9, error) {
 
 	// Variable: Scramble (NULL-Terminated)
 
-	sub, _, err = readBuffer(payload, pos, nullTermStringLen(payload[pos:]))
+	sub, _, err = wire.ReadBuffer(payload, pos, nullTermStringLen(payload[pos:]))
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -157,7 +159,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// Variable: Server Version (NULL-Terminated)
 
-	sub, pos, err := readBuffer(payload, 1, nullTermStringLen(payload[1:]))
+	sub, pos, err := wire.ReadBuffer(payload, 1, nullTermStringLen(payload[1:]))
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -166,7 +168,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 4 Bytes: Thread ID
 
-	sub, pos, err = readBuffer(payload, pos, 4)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 4)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -174,7 +176,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 8 Bytes: Part 1 of Auth Scramble
 
-	sub, pos, err = readBuffer(payload, pos, 8)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 8)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -183,7 +185,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 2 Bytes: Lower Bytes of Capability Flags
 
-	sub, pos, err = readBuffer(payload, pos, 2)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 2)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -191,7 +193,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 1 Byte: Character Set ID
 
-	sub, pos, err = readBuffer(payload, pos, 1)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 1)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -199,7 +201,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 2 Bytes: Server Status Flags
 
-	sub, pos, err = readBuffer(payload, pos, 2)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 2)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -207,7 +209,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 2 Bytes: Upper Byes of Capability Flags
 
-	sub, pos, err = readBuffer(payload, pos, 2)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 2)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -216,7 +218,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 
 	// 1 Byte: Plugin Data Length
 
-	sub, pos, err = readBuffer(payload, pos, 1)
+	sub, pos, err = wire.ReadBuffer(payload, pos, 1)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -236,7 +238,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 		}
 	}
 
-	sub, pos, err = readBuffer(payload, pos, offset)
+	sub, pos, err = wire.ReadBuffer(payload, pos, offset)
 	if err != nil {
 		return nil, ErrHandshakeTruncated
 	}
@@ -246,7 +248,7 @@ func decodeHandshakeV10(payload []byte) (*HandshakeV10, error) {
 	//	Only present if CLIENT_PLUGIN_AUTH capability is set.
 
 	if hs.CapabilityFlags.Has(CapabilityPluginAuth) {
-		sub, _, err = readBuffer(payload, pos, nullTermStringLen(payload[pos:]))
+		sub, _, err = wire.ReadBuffer(payload, pos, nullTermStringLen(payload[pos:]))
 		if err != nil {
 			return nil, ErrHandshakeTruncated
 		}
@@ -267,13 +269,3 @@ func nullTermStringLen(n []byte) int {
 	}
 	return 0
 }
-
-// Reads the buffer at the given position up to the offset.
-// The resulting sub-slice of bytes is returned, along with the new cursor position.
-// If the given position + offset extend past the slice, out of bounds, an errors is returned.
-func readBuffer(b []byte, pos, offset int) ([]byte, int, error) {
-	if pos+offset > len(b) {
-		return nil, 0, fmt.Errorf("out of bounds")
-	}
-	return b[pos : pos+offset], pos + offset, nil
-}
@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package nats provides facilities for decoding and inspecting the NATS server greeting.
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrInfoDecode = fmt.Errorf("info decode")
+var ErrInfoTruncated = fmt.Errorf("%w: truncated greeting or connection is not nats", ErrInfoDecode)
+
+// Maximum length of the INFO line accepted.
+const maxInfoLength = 64 * 1024
+
+// Info is the INFO message a NATS server greets each client with.
+//
+// See https://docs.nats.io/nats-protocol/nats-protocol#info
+type Info struct {
+	ServerID     string   `json:"server_id"`
+	ServerName   string   `json:"server_name,omitempty"`
+	Version      string   `json:"version"`
+	Go           string   `json:"go"`
+	Host         string   `json:"host"`
+	Port         int      `json:"port"`
+	Proto        int      `json:"proto"`
+	Headers      bool     `json:"headers"`
+	MaxPayload   int64    `json:"max_payload"`
+	ClientID     uint64   `json:"client_id,omitempty"`
+	ClientIP     string   `json:"client_ip,omitempty"`
+	AuthRequired bool     `json:"auth_required"`
+	TLSRequired  bool     `json:"tls_required"`
+	TLSVerify    bool     `json:"tls_verify"`
+	TLSAvailable bool     `json:"tls_available"`
+	JetStream    bool     `json:"jetstream"`
+	Cluster      string   `json:"cluster,omitempty"`
+	Domain       string   `json:"domain,omitempty"`
+	ConnectURLs  []string `json:"connect_urls,omitempty"`
+	LameDuckMode bool     `json:"ldm,omitempty"`
+	GitCommit    string   `json:"git_commit,omitempty"`
+
+	// Nonce is sent when the server expects the client to sign it with an NKey, it is reported as present or not.
+	Nonce string `json:"-"`
+}
+
+// ReadInfo reads and decodes the INFO greeting.
+func ReadInfo(r io.Reader) (*Info, error) {
+	// Greeting Format
+	//	INFO {"server_id":"...","version":"2.2.0",...}\r\n
+
+	line, err := bufio.NewReaderSize(io.LimitReader(r, maxInfoLength), 4096).ReadString('\n')
+	if err != nil {
+		return nil, wire.ReadError(err, ErrInfoTruncated, ErrInfoDecode)
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 || strings.ToUpper(parts[0]) != "INFO" {
+		return nil, fmt.Errorf("%w: unexpected greeting, connection is not nats", ErrInfoDecode)
+	}
+
+	// The nonce is excluded from the Info's JSON, so it is decoded alongside it.
+	var msg struct {
+		Info
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal([]byte(parts[1]), &msg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInfoDecode, err)
+	}
+
+	info := msg.Info
+	info.Nonce = msg.Nonce
+	return &info, nil
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nats
+
+import (
+	"io"
+)
+
+// Report contains the information gathered from a NATS server.
+type Report struct {
+	*Info
+
+	// NKeyChallenge indicates if the server sent a nonce for NKey or JWT authentication.
+	NKeyChallenge bool `json:"nkey_challenge"`
+}
+
+// Probe reads the INFO greeting the server sends as soon as a client connects.
+func Probe(r io.Reader) (*Report, error) {
+	info, err := ReadInfo(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		Info:          info,
+		NKeyChallenge: info.Nonce != "",
+	}, nil
+}
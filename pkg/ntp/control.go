@@ -0,0 +1,139 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ntp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Mode 6 Control Message Opcodes
+const (
+	opReadVariables = 2
+)
+
+// Mode 6 Response Flags (second header byte)
+const (
+	controlResponse = 0x80
+	controlError    = 0x40
+	controlMore     = 0x20
+)
+
+// Size of the mode 6 control message header.
+const controlHeaderSize = 12
+
+// Control is a decoded mode 6 control response fragment.
+//
+// See https://tools.ietf.org/html/rfc1305#appendix-B (NTP Control Messages)
+type Control struct {
+	// Sequence is the sequence number echoed from the request.
+	Sequence uint16
+
+	// Error indicates the server responded with an error.
+	Error bool
+
+	// More indicates more fragments follow.
+	More bool
+
+	// Offset is the offset of this fragment's data in the complete response.
+	Offset uint16
+
+	// Data is the fragment's data.
+	Data []byte
+}
+
+// EncodeReadVariables encodes a mode 6 READVAR request for the system variables with a random sequence number,
+// returning the message and the sequence number.
+func EncodeReadVariables() ([]byte, uint16) {
+	// Control Message Header
+	//	1 Byte: Leap Indicator (2 bits), Version (3 bits), Mode (3 bits)
+	//	1 Byte: Response (1 bit), Error (1 bit), More (1 bit), Opcode (5 bits)
+	//	2 Bytes: Sequence
+	//	2 Bytes: Status
+	//	2 Bytes: Association ID (0 for the system variables)
+	//	2 Bytes: Offset
+	//	2 Bytes: Count
+
+	buf := make([]byte, controlHeaderSize)
+	buf[0] = 2<<3 | ModeControl
+	buf[1] = opReadVariables
+	_, _ = rand.Read(buf[2:4])
+	return buf, binary.BigEndian.Uint16(buf[2:])
+}
+
+// DecodeControl attempts to decode the given datagram as a mode 6 control response fragment to a READVAR request.
+func DecodeControl(datagram []byte) (*Control, error) {
+	if len(datagram) < controlHeaderSize {
+		return nil, ErrPacketTruncated
+	}
+	if datagram[0]&0x07 != ModeControl || datagram[1]&controlResponse == 0 {
+		return nil, fmt.Errorf("%w: not a control response", ErrPacketDecode)
+	}
+	if datagram[1]&0x1F != opReadVariables {
+		return nil, fmt.Errorf("%w: unexpected opcode %d", ErrPacketDecode, datagram[1]&0x1F)
+	}
+
+	count := int(binary.BigEndian.Uint16(datagram[10:]))
+	if controlHeaderSize+count > len(datagram) {
+		return nil, ErrPacketTruncated
+	}
+
+	return &Control{
+		Sequence: binary.BigEndian.Uint16(datagram[2:]),
+		Error:    datagram[1]&controlError != 0,
+		More:     datagram[1]&controlMore != 0,
+		Offset:   binary.BigEndian.Uint16(datagram[8:]),
+		Data:     datagram[controlHeaderSize : controlHeaderSize+count],
+	}, nil
+}
+
+// ParseVariables parses a READVAR response, a comma separated list of name=value pairs with optionally quoted values.
+func ParseVariables(data string) map[string]string {
+	vars := map[string]string{}
+
+	var field strings.Builder
+	quoted := false
+	flush := func() {
+		kv := strings.SplitN(strings.TrimSpace(field.String()), "=", 2)
+		field.Reset()
+		if kv[0] == "" {
+			return
+		}
+		if len(kv) == 1 {
+			vars[kv[0]] = ""
+			return
+		}
+		vars[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+
+	for _, r := range data {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case r == ',' && !quoted:
+			flush()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	flush()
+
+	return vars
+}
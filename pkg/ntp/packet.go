@@ -0,0 +1,175 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ntp provides facilities for probing and inspecting NTP servers.
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or not an ntp response", ErrPacketDecode)
+
+// Association Modes
+const (
+	ModeClient  = 3
+	ModeServer  = 4
+	ModeControl = 6
+	ModePrivate = 7
+)
+
+// Protocol version sent in client requests.
+const version = 4
+
+// Size of the NTP packet header (without extension fields or MAC).
+const packetSize = 48
+
+// Number of seconds between the NTP epoch (1900) and the Unix epoch (1970).
+const epochDelta = 2208988800
+
+// Packet is a decoded NTP server response.
+//
+// See https://tools.ietf.org/html/rfc5905#section-7.3
+type Packet struct {
+	Leap      uint8
+	Version   uint8
+	Mode      uint8
+	Stratum   uint8
+	Poll      int8
+	Precision int8
+
+	// RootDelay is the total round-trip delay to the reference clock.
+	RootDelay time.Duration
+
+	// RootDispersion is the total dispersion to the reference clock.
+	RootDispersion time.Duration
+
+	// ReferenceID identifies the server's reference clock or upstream server.
+	ReferenceID []byte
+
+	ReferenceTime time.Time
+	OriginTime    uint64
+	ReceiveTime   time.Time
+	TransmitTime  time.Time
+}
+
+// EncodeClientRequest encodes a mode 3 client request with the given transmit timestamp,
+// which the server echoes back in the origin timestamp of its response.
+func EncodeClientRequest(transmit uint64) []byte {
+	// Packet Header
+	//	1 Byte: Leap Indicator (2 bits), Version (3 bits), Mode (3 bits)
+	//	1 Byte: Stratum
+	//	1 Byte: Poll
+	//	1 Byte: Precision
+	//	4 Bytes: Root Delay
+	//	4 Bytes: Root Dispersion
+	//	4 Bytes: Reference ID
+	//	8 Bytes: Reference Timestamp
+	//	8 Bytes: Origin Timestamp
+	//	8 Bytes: Receive Timestamp
+	//	8 Bytes: Transmit Timestamp
+
+	buf := make([]byte, packetSize)
+	buf[0] = version<<3 | ModeClient
+	binary.BigEndian.PutUint64(buf[40:], transmit)
+	return buf
+}
+
+// DecodePacket attempts to decode the given datagram as an NTP server response.
+func DecodePacket(datagram []byte) (*Packet, error) {
+	if len(datagram) < packetSize {
+		return nil, ErrPacketTruncated
+	}
+
+	p := &Packet{
+		Leap:           datagram[0] >> 6,
+		Version:        datagram[0] >> 3 & 0x07,
+		Mode:           datagram[0] & 0x07,
+		Stratum:        datagram[1],
+		Poll:           int8(datagram[2]),
+		Precision:      int8(datagram[3]),
+		RootDelay:      shortDuration(binary.BigEndian.Uint32(datagram[4:])),
+		RootDispersion: shortDuration(binary.BigEndian.Uint32(datagram[8:])),
+		ReferenceID:    datagram[12:16],
+		ReferenceTime:  Time(binary.BigEndian.Uint64(datagram[16:])),
+		OriginTime:     binary.BigEndian.Uint64(datagram[24:]),
+		ReceiveTime:    Time(binary.BigEndian.Uint64(datagram[32:])),
+		TransmitTime:   Time(binary.BigEndian.Uint64(datagram[40:])),
+	}
+	if p.Mode != ModeServer {
+		return nil, fmt.Errorf("%w: unexpected mode %d, not an ntp server response", ErrPacketDecode, p.Mode)
+	}
+
+	return p, nil
+}
+
+// ReferenceIDString formats the reference ID according to the stratum: a kiss code for stratum 0,
+// the reference clock's ASCII identifier for stratum 1, and the upstream server's IPv4 address (or a hash
+// of its IPv6 address) otherwise.
+func (p *Packet) ReferenceIDString() string {
+	if p.Stratum <= 1 {
+		id := make([]byte, 0, 4)
+		for _, b := range p.ReferenceID {
+			if b == 0 {
+				break
+			}
+			id = append(id, b)
+		}
+		return string(id)
+	}
+	return net.IP(p.ReferenceID).String()
+}
+
+// LeapName returns the description of a leap indicator.
+func LeapName(leap uint8) string {
+	switch leap {
+	case 0:
+		return "no warning"
+	case 1:
+		return "last minute has 61 seconds"
+	case 2:
+		return "last minute has 59 seconds"
+	default:
+		return "unsynchronized"
+	}
+}
+
+// Timestamp returns the NTP timestamp of the given time: seconds since 1900 in the upper 32 bits, and the
+// fraction of a second in the lower 32 bits.
+func Timestamp(t time.Time) uint64 {
+	secs := uint64(t.Unix() + epochDelta)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// Time returns the time of an NTP timestamp. Zero timestamps are returned as the zero time.
+func Time(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	secs := int64(ts>>32) - epochDelta
+	nanos := int64((ts & 0xFFFFFFFF) * uint64(time.Second) >> 32)
+	return time.Unix(secs, nanos).UTC()
+}
+
+// Converts an NTP short format value (16.16 fixed point seconds) to a duration.
+func shortDuration(v uint32) time.Duration {
+	return time.Duration(uint64(v) * uint64(time.Second) >> 16)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Mode 7 Implementation and Request Codes
+const (
+	implXNTPD      = 3
+	reqMonGetList1 = 42
+)
+
+// Mode 7 Response Flags (first header byte)
+const (
+	privateResponse = 0x80
+	privateMore     = 0x40
+)
+
+// Size of the mode 7 private message header.
+const privateHeaderSize = 8
+
+// Size of the mode 7 request sent, padded as ntpdc pads its requests.
+const privateRequestSize = 48
+
+// Private is a decoded mode 7 private response fragment.
+type Private struct {
+	// More indicates more fragments follow.
+	More bool
+
+	// Sequence is the fragment sequence number.
+	Sequence uint8
+
+	// Error is the error code, 0 for success.
+	Error uint8
+
+	// Items is the number of data items in this fragment.
+	Items int
+
+	// ItemSize is the size of each data item.
+	ItemSize int
+}
+
+// EncodeMonlist encodes a mode 7 MON_GETLIST_1 request, asking ntpd for the list of recent clients.
+// This is the request abused for amplification attacks (CVE-2013-5211).
+func EncodeMonlist() []byte {
+	// Private Message Header
+	//	1 Byte: Response (1 bit), More (1 bit), Version (3 bits), Mode (3 bits)
+	//	1 Byte: Authenticated (1 bit), Sequence (7 bits)
+	//	1 Byte: Implementation
+	//	1 Byte: Request Code
+	//	2 Bytes: Error (4 bits), Number of Items (12 bits)
+	//	2 Bytes: Must Be Zero (4 bits), Item Size (12 bits)
+
+	buf := make([]byte, privateRequestSize)
+	buf[0] = 2<<3 | ModePrivate
+	buf[2] = implXNTPD
+	buf[3] = reqMonGetList1
+	return buf
+}
+
+// DecodePrivate attempts to decode the given datagram as a mode 7 response fragment to a MON_GETLIST_1 request.
+func DecodePrivate(datagram []byte) (*Private, error) {
+	if len(datagram) < privateHeaderSize {
+		return nil, ErrPacketTruncated
+	}
+	if datagram[0]&0x07 != ModePrivate || datagram[0]&privateResponse == 0 {
+		return nil, fmt.Errorf("%w: not a private response", ErrPacketDecode)
+	}
+	if datagram[2] != implXNTPD {
+		return nil, fmt.Errorf("%w: unexpected implementation %d", ErrPacketDecode, datagram[2])
+	}
+	if datagram[3] != reqMonGetList1 {
+		return nil, fmt.Errorf("%w: unexpected request code %d", ErrPacketDecode, datagram[3])
+	}
+
+	items := binary.BigEndian.Uint16(datagram[4:])
+	size := binary.BigEndian.Uint16(datagram[6:])
+
+	return &Private{
+		More:     datagram[0]&privateMore != 0,
+		Sequence: datagram[1] & 0x7F,
+		Error:    uint8(items >> 12),
+		Items:    int(items & 0x0FFF),
+		ItemSize: int(size & 0x0FFF),
+	}, nil
+}
+
+// PrivateErrorName returns the name of a mode 7 error code.
+func PrivateErrorName(code uint8) string {
+	switch code {
+	case 0:
+		return "INFO_OKAY"
+	case 1:
+		return "INFO_ERR_IMPL"
+	case 2:
+		return "INFO_ERR_REQ"
+	case 3:
+		return "INFO_ERR_FMT"
+	case 4:
+		return "INFO_ERR_NODATA"
+	case 7:
+		return "INFO_ERR_AUTH"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", code)
+	}
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ntp
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/udp"
+)
+
+// Maximum number of fragments collected for a single mode 6 or mode 7 response.
+// ntpd returns at most 100 MONLIST packets (600 entries).
+const maxFragments = 100
+
+// Report contains the information gathered from an NTP server.
+type Report struct {
+	// Version is the protocol version of the server's response.
+	Version uint8 `json:"version"`
+
+	// Leap is the description of the leap indicator, "unsynchronized" if the server's clock isn't synchronized.
+	Leap string `json:"leap"`
+
+	// Stratum is the server's distance from its reference clock, 1 for a primary server and 0 for a kiss-o'-death.
+	Stratum uint8 `json:"stratum"`
+
+	// Poll is the log2 of the maximum polling interval in seconds.
+	Poll int8 `json:"poll"`
+
+	// Precision is the log2 of the server clock's precision in seconds.
+	Precision int8 `json:"precision"`
+
+	// RootDelay is the total round-trip delay to the reference clock in seconds.
+	RootDelay float64 `json:"root_delay"`
+
+	// RootDispersion is the total dispersion to the reference clock in seconds.
+	RootDispersion float64 `json:"root_dispersion"`
+
+	// ReferenceID is the reference clock identifier (stratum 1), kiss code (stratum 0) or upstream server address.
+	ReferenceID string `json:"reference_id"`
+
+	// ReferenceTime is the time the server's clock was last set or corrected.
+	ReferenceTime time.Time `json:"reference_time"`
+
+	// ServerTime is the server's time when it sent the response.
+	ServerTime time.Time `json:"server_time"`
+
+	// Offset is the estimated offset of the server's clock from the local clock in seconds.
+	Offset float64 `json:"offset"`
+
+	// RoundTripDelay is the round-trip delay of the exchange in seconds, excluding the server's processing time.
+	RoundTripDelay float64 `json:"round_trip_delay"`
+
+	// ReadVar contains the results of the mode 6 READVAR request, if tested and answered.
+	ReadVar *ReadVar `json:"readvar,omitempty"`
+
+	// ReadVarError contains the reason the READVAR request failed, if it did.
+	ReadVarError string `json:"readvar_error,omitempty"`
+
+	// Monlist contains the results of the mode 7 MONLIST request, if tested and answered.
+	Monlist *Monlist `json:"monlist,omitempty"`
+
+	// MonlistError contains the reason the MONLIST request failed, if it did.
+	MonlistError string `json:"monlist_error,omitempty"`
+
+	// AmplificationProne indicates if the server answered READVAR or returned MONLIST data, responses much larger
+	// than the request which can be abused for reflected amplification attacks.
+	AmplificationProne bool `json:"amplification_prone"`
+}
+
+// ReadVar contains the response to a mode 6 READVAR request.
+type ReadVar struct {
+	// Error indicates the server responded with an error rather than the variables.
+	Error bool `json:"error"`
+
+	// Variables contains the system variables returned, for example "version", "system" and "processor".
+	Variables map[string]string `json:"variables"`
+
+	// Amplification contains the size of the response relative to the request.
+	Amplification
+}
+
+// Monlist contains the response to a mode 7 MONLIST request.
+type Monlist struct {
+	// Error is the name of the error code returned, if the server doesn't serve the list.
+	Error string `json:"error,omitempty"`
+
+	// Entries is the number of recent client entries returned.
+	Entries int `json:"entries"`
+
+	// Amplification contains the size of the response relative to the request.
+	Amplification
+}
+
+// Amplification contains the size of a response relative to the request which elicited it.
+type Amplification struct {
+	// Packets is the number of response packets received.
+	Packets int `json:"packets"`
+
+	// Bytes is the total UDP payload size of the response packets.
+	Bytes int `json:"bytes"`
+
+	// Factor is the ratio of response bytes to request bytes.
+	Factor float64 `json:"factor"`
+
+	// Complete indicates if every response packet was received.
+	Complete bool `json:"complete"`
+}
+
+// Probe sends a mode 3 client request and reports the server's clock. If requested, mode 6 READVAR and
+// mode 7 MONLIST requests are then sent to determine whether the server is prone to amplification.
+func Probe(t *udp.Transport, amplification bool) (*Report, error) {
+	// (1) Mode 3 Client Request
+
+	t1 := time.Now()
+	transmit := Timestamp(t1)
+
+	var p *Packet
+	_, err := t.Exchange(EncodeClientRequest(transmit), func(datagram []byte) bool {
+		resp, err := DecodePacket(datagram)
+		if err != nil || resp.OriginTime != transmit {
+			return false
+		}
+		p = resp
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	t4 := time.Now()
+
+	report := &Report{
+		Version:        p.Version,
+		Leap:           LeapName(p.Leap),
+		Stratum:        p.Stratum,
+		Poll:           p.Poll,
+		Precision:      p.Precision,
+		RootDelay:      p.RootDelay.Seconds(),
+		RootDispersion: p.RootDispersion.Seconds(),
+		ReferenceID:    p.ReferenceIDString(),
+		ReferenceTime:  p.ReferenceTime,
+		ServerTime:     p.TransmitTime,
+	}
+
+	// Clock offset and round-trip delay, see https://tools.ietf.org/html/rfc5905#section-8
+	//	T1: client transmit, T2: server receive, T3: server transmit, T4: client receive
+	t2, t3 := p.ReceiveTime, p.TransmitTime
+	report.Offset = ((t2.Sub(t1) + t3.Sub(t4)) / 2).Seconds()
+	report.RoundTripDelay = (t4.Sub(t1) - t3.Sub(t2)).Seconds()
+
+	if !amplification {
+		return report, nil
+	}
+
+	// (2) Mode 6 READVAR Request
+
+	report.ReadVar, err = readVariables(t)
+	if err != nil {
+		report.ReadVarError = err.Error()
+	}
+
+	// (3) Mode 7 MONLIST Request
+
+	report.Monlist, err = monlist(t)
+	if err != nil {
+		report.MonlistError = err.Error()
+	}
+
+	report.AmplificationProne = (report.ReadVar != nil && !report.ReadVar.Error) ||
+		(report.Monlist != nil && report.Monlist.Entries > 0)
+
+	return report, nil
+}
+
+// Sends a mode 6 READVAR request and reassembles the system variables from the response fragments.
+func readVariables(t *udp.Transport) (*ReadVar, error) {
+	req, sequence := EncodeReadVariables()
+
+	// Late duplicates of fragments already received, answering an earlier retransmit, are skipped.
+	var fragments []*Control
+	offsets := map[uint16]bool{}
+	bytes := 0
+	match := func(datagram []byte) bool {
+		c, err := DecodeControl(datagram)
+		if err != nil || c.Sequence != sequence || offsets[c.Offset] {
+			return false
+		}
+		offsets[c.Offset] = true
+		fragments = append(fragments, c)
+		bytes += len(datagram)
+		return true
+	}
+
+	if _, err := t.Exchange(req, match); err != nil {
+		return nil, err
+	}
+	more := func() bool {
+		return fragments[len(fragments)-1].More && len(fragments) < maxFragments
+	}
+	if err := receiveFragments(t, match, more); err != nil {
+		return nil, err
+	}
+	complete := !fragments[len(fragments)-1].More
+
+	// Fragments may arrive out of order, so they are joined in the order of their offsets.
+	sort.SliceStable(fragments, func(i, j int) bool {
+		return fragments[i].Offset < fragments[j].Offset
+	})
+	var data strings.Builder
+	for _, c := range fragments {
+		data.Write(c.Data)
+	}
+
+	return &ReadVar{
+		Error:         fragments[0].Error,
+		Variables:     ParseVariables(data.String()),
+		Amplification: newAmplification(len(req), len(fragments), bytes, complete),
+	}, nil
+}
+
+// Sends a mode 7 MONLIST request and counts the entries in the response fragments.
+func monlist(t *udp.Transport) (*Monlist, error) {
+	req := EncodeMonlist()
+
+	// Late duplicates of fragments already received, answering an earlier retransmit, are skipped.
+	var fragments []*Private
+	sequences := map[uint8]bool{}
+	bytes := 0
+	match := func(datagram []byte) bool {
+		p, err := DecodePrivate(datagram)
+		if err != nil || sequences[p.Sequence] {
+			return false
+		}
+		sequences[p.Sequence] = true
+		fragments = append(fragments, p)
+		bytes += len(datagram)
+		return true
+	}
+
+	if _, err := t.Exchange(req, match); err != nil {
+		return nil, err
+	}
+	more := func() bool {
+		return fragments[len(fragments)-1].More && len(fragments) < maxFragments
+	}
+	if err := receiveFragments(t, match, more); err != nil {
+		return nil, err
+	}
+	complete := !fragments[len(fragments)-1].More
+
+	result := &Monlist{
+		Amplification: newAmplification(len(req), len(fragments), bytes, complete),
+	}
+	if code := fragments[0].Error; code != 0 {
+		result.Error = PrivateErrorName(code)
+	}
+	for _, p := range fragments {
+		result.Entries += p.Items
+	}
+	return result, nil
+}
+
+// Receives further response fragments while more are expected. A fragment that never arrives leaves the
+// response incomplete rather than failing it, since the fragments received so far still count towards the
+// amplification.
+func receiveFragments(t *udp.Transport, match func(datagram []byte) bool, more func() bool) error {
+	for more() {
+		if _, err := t.Receive(match); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Creates the amplification reported for a response.
+func newAmplification(requestBytes int, packets int, bytes int, complete bool) Amplification {
+	return Amplification{
+		Packets:  packets,
+		Bytes:    bytes,
+		Factor:   float64(bytes) / float64(requestBytes),
+		Complete: complete,
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oracle
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrConnectDecode = fmt.Errorf("connect decode")
+var ErrConnectTruncated = fmt.Errorf("%w: truncated payload or not a tns response", ErrConnectDecode)
+
+// Protocol versions proposed by the client in the CONNECT packet.
+const (
+	clientVersion           = 314
+	clientVersionCompatible = 300
+)
+
+// Offset of the connect data from the start of the CONNECT packet (including the header).
+const connectDataOffset = 58
+
+// Accept represents a TNS ACCEPT packet, sent when the listener accepts the connection.
+type Accept struct {
+	// Version is the TNS protocol version selected by the listener.
+	Version uint16 `json:"version"`
+
+	// ServiceOptions contains the global service options.
+	ServiceOptions uint16 `json:"service_options"`
+
+	// SDU is the session data unit size.
+	SDU uint16 `json:"sdu"`
+
+	// TDU is the maximum transmission data unit size.
+	TDU uint16 `json:"tdu"`
+
+	// Data contains the accept data, if any.
+	Data string `json:"data,omitempty"`
+}
+
+// Refuse represents a TNS REFUSE packet, sent when the listener refuses the connection.
+type Refuse struct {
+	// UserReason is the application refuse reason.
+	UserReason uint8 `json:"user_reason"`
+
+	// SystemReason is the system refuse reason.
+	SystemReason uint8 `json:"system_reason"`
+
+	// Data contains the refuse description, for example "(DESCRIPTION=(ERR=1189)...)".
+	Data string `json:"data,omitempty"`
+}
+
+// Redirect represents a TNS REDIRECT packet, sent when the listener hands the client off to another address.
+type Redirect struct {
+	// Data contains the redirect address description.
+	Data string `json:"data,omitempty"`
+}
+
+// EncodeConnect encodes a CONNECT packet carrying the given listener command, for example "version" or "status".
+func EncodeConnect(command string) *Packet {
+	data := []byte(fmt.Sprintf(
+		"(DESCRIPTION=(CONNECT_DATA=(CID=(PROGRAM=)(HOST=)(USER=))(COMMAND=%s)(ARGUMENTS=64)(SERVICE=LISTENER)(VERSION=169869568)))",
+		command,
+	))
+
+	payload := make([]byte, connectDataOffset-headerSize)
+	binary.BigEndian.PutUint16(payload[0:], clientVersion)
+	binary.BigEndian.PutUint16(payload[2:], clientVersionCompatible)
+	binary.BigEndian.PutUint16(payload[4:], 0x0000)  // Service Options
+	binary.BigEndian.PutUint16(payload[6:], 0x0800)  // SDU Size
+	binary.BigEndian.PutUint16(payload[8:], 0x7fff)  // TDU Size
+	binary.BigEndian.PutUint16(payload[10:], 0x7f08) // NT Protocol Characteristics
+	binary.BigEndian.PutUint16(payload[12:], 0x0000) // Line Turnaround
+	binary.BigEndian.PutUint16(payload[14:], 0x0001) // Value of 1 in Hardware
+	binary.BigEndian.PutUint16(payload[16:], uint16(len(data)))
+	binary.BigEndian.PutUint16(payload[18:], connectDataOffset)
+
+	return &Packet{
+		Type:    PacketTypeConnect,
+		Payload: append(payload, data...),
+	}
+}
+
+// DecodeAccept attempts to decode the given series of bytes as an ACCEPT packet payload.
+func DecodeAccept(payload []byte) (*Accept, error) {
+	// Fixed Fields
+	//	2 Bytes: Version
+	//	2 Bytes: Service Options
+	//	2 Bytes: SDU Size
+	//	2 Bytes: TDU Size
+	//	2 Bytes: Value of 1 in Hardware
+	//	2 Bytes: Accept Data Length
+	//	2 Bytes: Accept Data Offset (from start of packet)
+
+	sub, _, err := wire.ReadBuffer(payload, 0, 14)
+	if err != nil {
+		return nil, ErrConnectTruncated
+	}
+
+	acc := &Accept{
+		Version:        binary.BigEndian.Uint16(sub[0:]),
+		ServiceOptions: binary.BigEndian.Uint16(sub[2:]),
+		SDU:            binary.BigEndian.Uint16(sub[4:]),
+		TDU:            binary.BigEndian.Uint16(sub[6:]),
+	}
+
+	length := int(binary.BigEndian.Uint16(sub[10:]))
+	offset := int(binary.BigEndian.Uint16(sub[12:])) - headerSize
+
+	if length > 0 {
+		sub, _, err = wire.ReadBuffer(payload, offset, length)
+		if err != nil {
+			return nil, ErrConnectTruncated
+		}
+		acc.Data = string(sub)
+	}
+
+	return acc, nil
+}
+
+// DecodeRefuse attempts to decode the given series of bytes as a REFUSE packet payload.
+func DecodeRefuse(payload []byte) (*Refuse, error) {
+	// 1 Byte: User Reason
+	// 1 Byte: System Reason
+	// 2 Bytes: Data Length
+
+	sub, pos, err := wire.ReadBuffer(payload, 0, 4)
+	if err != nil {
+		return nil, ErrConnectTruncated
+	}
+
+	ref := &Refuse{
+		UserReason:   sub[0],
+		SystemReason: sub[1],
+	}
+
+	// Variable: Refuse Data
+
+	sub, _, err = wire.ReadBuffer(payload, pos, int(binary.BigEndian.Uint16(sub[2:])))
+	if err != nil {
+		return nil, ErrConnectTruncated
+	}
+	ref.Data = string(sub)
+
+	return ref, nil
+}
+
+// DecodeRedirect attempts to decode the given series of bytes as a REDIRECT packet payload.
+func DecodeRedirect(payload []byte) (*Redirect, error) {
+	// 2 Bytes: Data Length
+
+	sub, pos, err := wire.ReadBuffer(payload, 0, 2)
+	if err != nil {
+		return nil, ErrConnectTruncated
+	}
+
+	// Variable: Redirect Data
+	//	Some listeners send the data in a following DATA packet, in which case it is left empty.
+
+	data, _, err := wire.ReadBuffer(payload, pos, int(binary.BigEndian.Uint16(sub)))
+	if err != nil {
+		return &Redirect{}, nil
+	}
+
+	return &Redirect{
+		Data: string(data),
+	}, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package oracle provides facilities for decoding and inspecting Oracle TNS listener protocol information.
+package oracle
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or connection is not tns", ErrPacketDecode)
+
+// PacketType identifies the kind of TNS packet.
+type PacketType uint8
+
+// Packet Types
+const (
+	PacketTypeConnect  PacketType = 1
+	PacketTypeAccept   PacketType = 2
+	PacketTypeAck      PacketType = 3
+	PacketTypeRefuse   PacketType = 4
+	PacketTypeRedirect PacketType = 5
+	PacketTypeData     PacketType = 6
+	PacketTypeNull     PacketType = 7
+	PacketTypeAbort    PacketType = 9
+	PacketTypeResend   PacketType = 11
+	PacketTypeMarker   PacketType = 12
+	PacketTypeAttn     PacketType = 13
+	PacketTypeControl  PacketType = 14
+)
+
+func (t PacketType) String() string {
+	switch t {
+	case PacketTypeConnect:
+		return "CONNECT"
+	case PacketTypeAccept:
+		return "ACCEPT"
+	case PacketTypeAck:
+		return "ACK"
+	case PacketTypeRefuse:
+		return "REFUSE"
+	case PacketTypeRedirect:
+		return "REDIRECT"
+	case PacketTypeData:
+		return "DATA"
+	case PacketTypeNull:
+		return "NULL"
+	case PacketTypeAbort:
+		return "ABORT"
+	case PacketTypeResend:
+		return "RESEND"
+	case PacketTypeMarker:
+		return "MARKER"
+	case PacketTypeAttn:
+		return "ATTN"
+	case PacketTypeControl:
+		return "CONTROL"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+	}
+}
+
+func (t PacketType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// Size of the TNS packet header.
+const headerSize = 8
+
+// Packet represents the basic TNS packet.
+type Packet struct {
+	// Type is the packet type.
+	Type PacketType
+
+	// Flags contains the packet flags.
+	Flags uint8
+
+	// Payload of the packet.
+	Payload []byte
+}
+
+// Encode encodes the packet, ready to be written to a connection.
+func (p *Packet) Encode() []byte {
+	// Packet Format
+	//	2 Bytes: Packet Length (Big Endian, including header)
+	//	2 Bytes: Packet Checksum
+	//	1 Byte: Type
+	//	1 Byte: Flags
+	//	2 Bytes: Header Checksum
+	//	PAYLOAD
+
+	buf := make([]byte, headerSize, headerSize+len(p.Payload))
+	binary.BigEndian.PutUint16(buf, uint16(headerSize+len(p.Payload)))
+	buf[4] = byte(p.Type)
+	buf[5] = p.Flags
+
+	return append(buf, p.Payload...)
+}
+
+// ReadPacket attempts to read a TNS packet from the given reader.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+
+	length := int(binary.BigEndian.Uint16(header))
+	if length < headerSize {
+		return nil, fmt.Errorf("%w: invalid packet length, connection is not tns", ErrPacketDecode)
+	}
+
+	payload := make([]byte, length-headerSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+
+	return &Packet{
+		Type:    PacketType(header[4]),
+		Flags:   header[5],
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oracle
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of times the CONNECT packet is resent when the listener asks for it.
+const maxResends = 3
+
+// Listener Commands
+const (
+	CommandVersion = "version"
+	CommandStatus  = "status"
+)
+
+// Well known listener error messages, keyed by error code.
+var errorMessages = map[int]string{
+	1169:  "The listener has not recognized the password",
+	1189:  "The listener could not authenticate the user",
+	1194:  "The listener command must be issued from a local connection",
+	12500: "TNS:listener failed to start a dedicated server process",
+	12505: "TNS:listener does not currently know of SID given in connect descriptor",
+	12514: "TNS:listener does not currently know of service requested in connect descriptor",
+	12516: "TNS:listener could not find available handler with matching protocol stack",
+	12518: "TNS:listener could not hand off client connection",
+	12519: "TNS:no appropriate service handler found",
+	12520: "TNS:listener could not find available handler for requested type of server",
+	12526: "TNS:listener: all appropriate instances are in restricted mode",
+	12528: "TNS:listener: all appropriate instances are blocking new connections",
+}
+
+var (
+	vsnnumPattern  = regexp.MustCompile(`(?i)\(VSNNUM=(\d+)\)`)
+	errPattern     = regexp.MustCompile(`(?i)\((?:ERR|CODE)=(\d+)\)`)
+	versionPattern = regexp.MustCompile(`(?i)Version\s+(\d+(?:\.\d+)+)`)
+)
+
+// Report contains the information gathered from a TNS listener.
+type Report struct {
+	// Command is the listener command sent in the CONNECT packet.
+	Command string `json:"command"`
+
+	// Response is the type of packet the listener responded with.
+	Response PacketType `json:"response"`
+
+	// ProtocolVersion is the TNS protocol version selected by the listener, only known if the connection is accepted.
+	ProtocolVersion uint16 `json:"protocol_version,omitempty"`
+
+	// ListenerVersion is the listener version, decoded from VSNNUM or the version banner.
+	ListenerVersion string `json:"listener_version,omitempty"`
+
+	// Banner contains the listener's version banner, for example "TNSLSNR for Linux: Version 11.2.0.2.0 - Production".
+	Banner string `json:"banner,omitempty"`
+
+	// Errors lists any error codes reported by the listener.
+	Errors []Error `json:"errors,omitempty"`
+
+	// Resends is the number of times the listener asked for the CONNECT packet to be resent.
+	Resends int `json:"resends,omitempty"`
+
+	// Accept is the decoded ACCEPT packet.
+	Accept *Accept `json:"accept,omitempty"`
+
+	// Refuse is the decoded REFUSE packet.
+	Refuse *Refuse `json:"refuse,omitempty"`
+
+	// Redirect is the decoded REDIRECT packet.
+	Redirect *Redirect `json:"redirect,omitempty"`
+
+	// Data contains the contents of any DATA packets sent after the listener accepted the connection.
+	Data string `json:"data,omitempty"`
+}
+
+// Error is a single listener error.
+type Error struct {
+	// Code is the numeric error code.
+	Code int `json:"code"`
+
+	// Name is the error name, for example "TNS-12514".
+	Name string `json:"name"`
+
+	// Message is a description of the error, if it is a well known one.
+	Message string `json:"message,omitempty"`
+}
+
+// Probe sends a CONNECT packet with the given command over the connection and decodes the listener's response.
+func Probe(conn io.ReadWriter, command string) (*Report, error) {
+	report := &Report{
+		Command: command,
+	}
+
+	connect := EncodeConnect(command).Encode()
+
+	// (1) Send the CONNECT Packet
+	//		The listener may ask for the packet to be resent, for example if it arrived in pieces.
+
+	var packet *Packet
+	for {
+		_, err := conn.Write(connect)
+		if err != nil {
+			return nil, err
+		}
+
+		packet, err = ReadPacket(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		if packet.Type != PacketTypeResend || report.Resends >= maxResends {
+			break
+		}
+		report.Resends++
+	}
+
+	report.Response = packet.Type
+
+	// (2) Decode the Response
+
+	var description string
+
+	switch packet.Type {
+	case PacketTypeAccept:
+		acc, err := DecodeAccept(packet.Payload)
+		if err != nil {
+			return nil, err
+		}
+		report.Accept = acc
+		report.ProtocolVersion = acc.Version
+
+		report.Data = readData(conn)
+		description = acc.Data + report.Data
+
+	case PacketTypeRefuse:
+		ref, err := DecodeRefuse(packet.Payload)
+		if err != nil {
+			return nil, err
+		}
+		report.Refuse = ref
+		description = ref.Data
+
+	case PacketTypeRedirect:
+		red, err := DecodeRedirect(packet.Payload)
+		if err != nil {
+			return nil, err
+		}
+		report.Redirect = red
+		description = red.Data
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s packet", ErrConnectDecode, packet.Type)
+	}
+
+	// (3) Parse the Description
+	//		Versions and errors are embedded in the "(KEY=VALUE)" description text.
+
+	report.Banner = parseBanner(description)
+	report.ListenerVersion = parseListenerVersion(description)
+	report.Errors = parseErrors(description)
+
+	return report, nil
+}
+
+// Reads any DATA packets following an ACCEPT and returns their contents.
+// Reading stops as soon as the listener closes the connection or stops sending.
+func readData(r io.Reader) string {
+	var data strings.Builder
+
+	for {
+		packet, err := ReadPacket(r)
+		if err != nil || packet.Type != PacketTypeData {
+			return data.String()
+		}
+
+		// 2 Bytes: Data Flags
+		if len(packet.Payload) > 2 {
+			data.Write(packet.Payload[2:])
+		}
+	}
+}
+
+// Extracts the listener version banner (e.g. "TNSLSNR for Linux: Version 11.2.0.2.0 - Production") from the description.
+func parseBanner(description string) string {
+	start := strings.Index(description, "TNSLSNR")
+	if start < 0 {
+		return ""
+	}
+
+	banner := description[start:]
+	if end := strings.IndexAny(banner, "\x00\n)"); end >= 0 {
+		banner = banner[:end]
+	}
+	return strings.TrimSpace(banner)
+}
+
+// Extracts the listener version from the description, preferring VSNNUM over the version banner.
+func parseListenerVersion(description string) string {
+	if m := vsnnumPattern.FindStringSubmatch(description); m != nil {
+		if vsnnum, err := strconv.ParseUint(m[1], 10, 32); err == nil && vsnnum != 0 {
+			return DecodeVSNNUM(uint32(vsnnum))
+		}
+	}
+	if m := versionPattern.FindStringSubmatch(description); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Extracts all non-zero error codes from the description.
+func parseErrors(description string) []Error {
+	var errs []Error
+	seen := map[int]bool{}
+
+	for _, m := range errPattern.FindAllStringSubmatch(description, -1) {
+		code, err := strconv.Atoi(m[1])
+		if err != nil || code == 0 || seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		errs = append(errs, Error{
+			Code:    code,
+			Name:    fmt.Sprintf("TNS-%05d", code),
+			Message: errorMessages[code],
+		})
+	}
+
+	return errs
+}
+
+// DecodeVSNNUM decodes the numeric version sent by listeners (VSNNUM) into the usual dotted format,
+// for example 186647552 decodes to "11.2.0.4.0".
+func DecodeVSNNUM(vsnnum uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d.%d",
+		vsnnum>>24&0xff,
+		vsnnum>>20&0x0f,
+		vsnnum>>12&0xff,
+		vsnnum>>8&0x0f,
+		vsnnum&0xff,
+	)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rdp
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// Report contains the information gathered from an RDP server.
+type Report struct {
+	// SelectedProtocol is the security protocol the server selected when offered every protocol.
+	SelectedProtocol Protocol `json:"selected_protocol"`
+
+	// Security describes the selected security protocol, for example "CredSSP (NLA)".
+	Security string `json:"security"`
+
+	// Legacy indicates if the server sent no negotiation response, as servers predating RDP 5.2 do.
+	Legacy bool `json:"legacy"`
+
+	// Flags contains the negotiation response flags.
+	Flags []string `json:"flags"`
+
+	// SupportedProtocols lists every security protocol the server accepted when offered on its own.
+	SupportedProtocols []Protocol `json:"supported_protocols"`
+
+	// RefusedProtocols contains the reason the server refused each protocol offered on its own.
+	RefusedProtocols map[string]string `json:"refused_protocols,omitempty"`
+}
+
+// Probe sends an X.224 Connection Request offering every enhanced security protocol and reports the
+// protocol the server selects. Each protocol is then offered on its own connection to determine which
+// the server supports, most importantly whether standard RDP security is still allowed.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	// (1) Offer Every Protocol
+	//		Standard RDP security has no flag, it's implied by the request.
+
+	cc, err := connect(dial, ProtocolSSL|ProtocolHybrid|ProtocolRDSTLS|ProtocolHybridEx)
+	if err != nil {
+		var failure *NegotiationFailureError
+		if !errors.As(err, &failure) {
+			return nil, err
+		}
+
+		// Servers which only support standard RDP security refuse the enhanced protocols.
+
+		cc, err = connect(dial, ProtocolRDP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{
+		SelectedProtocol:   cc.SelectedProtocol,
+		Security:           cc.SelectedProtocol.Description(),
+		Legacy:             !cc.Negotiated,
+		Flags:              ResponseFlags(cc.Flags),
+		SupportedProtocols: []Protocol{},
+		RefusedProtocols:   map[string]string{},
+	}
+
+	// (2) Offer Each Protocol on its Own
+	//		Legacy servers only support standard RDP security.
+
+	if report.Legacy {
+		report.SupportedProtocols = append(report.SupportedProtocols, ProtocolRDP)
+		return report, nil
+	}
+
+	for _, p := range Protocols {
+		cc, err := connect(dial, p)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return nil, err
+		case err != nil:
+			report.RefusedProtocols[p.String()] = err.Error()
+		case cc.SelectedProtocol == p:
+			report.SupportedProtocols = append(report.SupportedProtocols, p)
+		default:
+			report.RefusedProtocols[p.String()] = "server selected " + cc.SelectedProtocol.String()
+		}
+	}
+
+	return report, nil
+}
+
+// Makes a new connection and sends a Connection Request for the given protocols.
+func connect(dial func() (net.Conn, error), protocols Protocol) (*ConnectionConfirm, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.Write(EncodeConnectionRequest(protocols)); err != nil {
+		return nil, err
+	}
+	return ReadConnectionConfirm(conn)
+}
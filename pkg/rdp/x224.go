@@ -0,0 +1,285 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rdp provides facilities for probing and inspecting Remote Desktop Protocol servers.
+package rdp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or not an rdp connection confirm", ErrPacketDecode)
+
+// TPKT Version
+const tpktVersion = 3
+
+// Size of the TPKT header.
+const tpktHeaderSize = 4
+
+// X.224 TPDU Codes
+const (
+	tpduConnectionRequest = 0xE0
+	tpduConnectionConfirm = 0xD0
+)
+
+// RDP Negotiation Types
+const (
+	negTypeRequest  = 0x01
+	negTypeResponse = 0x02
+	negTypeFailure  = 0x03
+)
+
+// Size of the RDP negotiation structures.
+const negSize = 8
+
+// Cookie sent in the connection request, servers log it as the requested user name.
+const cookie = "Cookie: mstshash=protoscan\r\n"
+
+// Protocol is an RDP security protocol composite flag field.
+type Protocol uint32
+
+// Security Protocols
+const (
+	ProtocolRDP      Protocol = 0x00000000
+	ProtocolSSL      Protocol = 0x00000001
+	ProtocolHybrid   Protocol = 0x00000002
+	ProtocolRDSTLS   Protocol = 0x00000004
+	ProtocolHybridEx Protocol = 0x00000008
+)
+
+// Protocols lists each security protocol offered on its own to determine which the server supports.
+var Protocols = []Protocol{ProtocolRDP, ProtocolSSL, ProtocolHybrid, ProtocolRDSTLS, ProtocolHybridEx}
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolRDP:
+		return "PROTOCOL_RDP"
+	case ProtocolSSL:
+		return "PROTOCOL_SSL"
+	case ProtocolHybrid:
+		return "PROTOCOL_HYBRID"
+	case ProtocolRDSTLS:
+		return "PROTOCOL_RDSTLS"
+	case ProtocolHybridEx:
+		return "PROTOCOL_HYBRID_EX"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%08x)", uint32(p))
+	}
+}
+
+func (p Protocol) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// Description returns a human readable description of the protocol, for example "CredSSP (NLA)".
+func (p Protocol) Description() string {
+	switch p {
+	case ProtocolRDP:
+		return "Standard RDP Security"
+	case ProtocolSSL:
+		return "TLS"
+	case ProtocolHybrid:
+		return "CredSSP (NLA)"
+	case ProtocolRDSTLS:
+		return "RDSTLS"
+	case ProtocolHybridEx:
+		return "CredSSP with Early User Authorization Result (NLA)"
+	default:
+		return p.String()
+	}
+}
+
+// NegotiationFailureError is returned when the server responds with an RDP_NEG_FAILURE.
+type NegotiationFailureError struct {
+	Code uint32
+}
+
+func (e *NegotiationFailureError) Error() string {
+	switch e.Code {
+	case 0x01:
+		return "negotiation failure: SSL_REQUIRED_BY_SERVER"
+	case 0x02:
+		return "negotiation failure: SSL_NOT_ALLOWED_BY_SERVER"
+	case 0x03:
+		return "negotiation failure: SSL_CERT_NOT_ON_SERVER"
+	case 0x04:
+		return "negotiation failure: INCONSISTENT_FLAGS"
+	case 0x05:
+		return "negotiation failure: HYBRID_REQUIRED_BY_SERVER"
+	case 0x06:
+		return "negotiation failure: SSL_WITH_USER_AUTH_REQUIRED_BY_SERVER"
+	default:
+		return fmt.Sprintf("negotiation failure: UNKNOWN(0x%08x)", e.Code)
+	}
+}
+
+// ConnectionConfirm represents the server's X.224 Connection Confirm.
+type ConnectionConfirm struct {
+	// Negotiated indicates if the server sent an RDP_NEG_RSP.
+	// Servers predating RDP 5.2 send no negotiation response and only support standard RDP security.
+	Negotiated bool
+
+	// Flags contains the RDP_NEG_RSP flags.
+	Flags uint8
+
+	// SelectedProtocol is the security protocol selected by the server.
+	SelectedProtocol Protocol
+}
+
+// EncodeConnectionRequest encodes an X.224 Connection Request carrying an RDP_NEG_REQ for the given protocols.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rdpbcgr/18a27ef9-6f9a-4501-b000-94b1fe3c2c10
+func EncodeConnectionRequest(protocols Protocol) []byte {
+	// X.224 Connection Request TPDU
+	//	1 Byte: Length Indicator (excluding itself)
+	//	1 Byte: CR Code
+	//	2 Bytes: DST-REF
+	//	2 Bytes: SRC-REF
+	//	1 Byte: Class Option
+	//	Variable: Cookie
+	//	8 Bytes: RDP_NEG_REQ
+
+	tpdu := []byte{0, tpduConnectionRequest, 0, 0, 0, 0, 0}
+	tpdu = append(tpdu, cookie...)
+
+	// RDP_NEG_REQ
+	//	1 Byte: Type
+	//	1 Byte: Flags
+	//	2 Bytes: Length (Little Endian, 8)
+	//	4 Bytes: Requested Protocols (Little Endian)
+
+	neg := make([]byte, negSize)
+	neg[0] = negTypeRequest
+	binary.LittleEndian.PutUint16(neg[2:], negSize)
+	binary.LittleEndian.PutUint32(neg[4:], uint32(protocols))
+	tpdu = append(tpdu, neg...)
+	tpdu[0] = byte(len(tpdu) - 1)
+
+	return encodeTPKT(tpdu)
+}
+
+// ReadConnectionConfirm reads an X.224 Connection Confirm from the reader.
+// A NegotiationFailureError is returned if the server responded with an RDP_NEG_FAILURE.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-rdpbcgr/13757f8f-66db-4273-9d2c-385c33b1e483
+func ReadConnectionConfirm(r io.Reader) (*ConnectionConfirm, error) {
+	tpdu, err := readTPKT(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// X.224 Connection Confirm TPDU
+	//	1 Byte: Length Indicator
+	//	1 Byte: CC Code
+	//	2 Bytes: DST-REF
+	//	2 Bytes: SRC-REF
+	//	1 Byte: Class Option
+
+	sub, pos, err := wire.ReadBuffer(tpdu, 0, 7)
+	if err != nil {
+		return nil, ErrPacketTruncated
+	}
+	if sub[1]&0xF0 != tpduConnectionConfirm {
+		return nil, fmt.Errorf("%w: unexpected tpdu code 0x%02x, connection is not rdp", ErrPacketDecode, sub[1])
+	}
+
+	cc := &ConnectionConfirm{}
+
+	// RDP_NEG_RSP / RDP_NEG_FAILURE (Optional)
+	//	1 Byte: Type
+	//	1 Byte: Flags
+	//	2 Bytes: Length (Little Endian, 8)
+	//	4 Bytes: Selected Protocol / Failure Code (Little Endian)
+
+	neg, _, err := wire.ReadBuffer(tpdu, pos, negSize)
+	if err != nil {
+		return cc, nil
+	}
+
+	switch neg[0] {
+	case negTypeResponse:
+		cc.Negotiated = true
+		cc.Flags = neg[1]
+		cc.SelectedProtocol = Protocol(binary.LittleEndian.Uint32(neg[4:]))
+	case negTypeFailure:
+		return nil, &NegotiationFailureError{Code: binary.LittleEndian.Uint32(neg[4:])}
+	default:
+		return nil, fmt.Errorf("%w: unexpected negotiation type 0x%02x", ErrPacketDecode, neg[0])
+	}
+
+	return cc, nil
+}
+
+// ResponseFlags returns the names of the RDP_NEG_RSP flags.
+func ResponseFlags(flags uint8) []string {
+	names := []string{}
+
+	if flags&0x01 != 0 {
+		names = append(names, "EXTENDED_CLIENT_DATA_SUPPORTED")
+	}
+	if flags&0x02 != 0 {
+		names = append(names, "DYNVC_GFX_PROTOCOL_SUPPORTED")
+	}
+	if flags&0x08 != 0 {
+		names = append(names, "RESTRICTED_ADMIN_MODE_SUPPORTED")
+	}
+	if flags&0x10 != 0 {
+		names = append(names, "REDIRECTED_AUTHENTICATION_MODE_SUPPORTED")
+	}
+
+	return names
+}
+
+// Wraps the TPDU in a TPKT header.
+func encodeTPKT(tpdu []byte) []byte {
+	// TPKT Header
+	//	1 Byte: Version (3)
+	//	1 Byte: Reserved
+	//	2 Bytes: Length (Big Endian, including header)
+
+	buf := make([]byte, tpktHeaderSize, tpktHeaderSize+len(tpdu))
+	buf[0] = tpktVersion
+	binary.BigEndian.PutUint16(buf[2:], uint16(tpktHeaderSize+len(tpdu)))
+	return append(buf, tpdu...)
+}
+
+// Reads a TPKT packet, returning the TPDU it carries.
+func readTPKT(r io.Reader) ([]byte, error) {
+	header := make([]byte, tpktHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	if header[0] != tpktVersion {
+		return nil, fmt.Errorf("%w: invalid tpkt version, connection is not rdp", ErrPacketDecode)
+	}
+
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < tpktHeaderSize {
+		return nil, fmt.Errorf("%w: invalid tpkt length, connection is not rdp", ErrPacketDecode)
+	}
+
+	tpdu := make([]byte, length-tpktHeaderSize)
+	if _, err := io.ReadFull(r, tpdu); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	return tpdu, nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package s7 provides facilities for probing Siemens S7 PLCs over S7comm and reading their identification.
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrPacketDecode = fmt.Errorf("packet decode")
+var ErrPacketTruncated = fmt.Errorf("%w: truncated packet or not an s7 device", ErrPacketDecode)
+
+// TPKT Version
+const tpktVersion = 3
+
+// Size of the TPKT header.
+const tpktHeaderSize = 4
+
+// COTP TPDU Codes
+const (
+	tpduConnectionRequest = 0xE0
+	tpduConnectionConfirm = 0xD0
+	tpduData              = 0xF0
+)
+
+// COTP Parameter Codes
+const (
+	paramTPDUSize = 0xC0
+	paramSrcTSAP  = 0xC1
+	paramDstTSAP  = 0xC2
+)
+
+// Flag set on the last data TPDU of a message.
+const tpduEOT = 0x80
+
+// Maximum number of data TPDUs reassembled into a single message.
+const maxTPDUs = 16
+
+// EncodeConnectionRequest encodes a COTP Connection Request for the given transport service access points.
+// The destination TSAP selects the connection type and the rack and slot of the CPU.
+//
+// See https://tools.ietf.org/html/rfc905 and https://tools.ietf.org/html/rfc1006
+func EncodeConnectionRequest(srcTSAP, dstTSAP uint16) []byte {
+	// COTP Connection Request TPDU
+	//	1 Byte: Length Indicator (excluding itself)
+	//	1 Byte: CR Code
+	//	2 Bytes: DST-REF
+	//	2 Bytes: SRC-REF
+	//	1 Byte: Class Option
+	//	Variable: Parameters, each being
+	//		1 Byte: Code
+	//		1 Byte: Length
+	//		n Bytes: Value
+
+	tpdu := []byte{0, tpduConnectionRequest, 0, 0, 0, 1, 0}
+	tpdu = append(tpdu, paramTPDUSize, 1, 0x0A) // 1024 bytes
+	tpdu = append(tpdu, paramSrcTSAP, 2, byte(srcTSAP>>8), byte(srcTSAP))
+	tpdu = append(tpdu, paramDstTSAP, 2, byte(dstTSAP>>8), byte(dstTSAP))
+	tpdu[0] = byte(len(tpdu) - 1)
+
+	return encodeTPKT(tpdu)
+}
+
+// ReadConnectionConfirm reads the COTP Connection Confirm from the reader.
+func ReadConnectionConfirm(r io.Reader) error {
+	tpdu, err := readTPKT(r)
+	if err != nil {
+		return err
+	}
+	if len(tpdu) < 7 {
+		return ErrPacketTruncated
+	}
+	if tpdu[1]&0xF0 != tpduConnectionConfirm {
+		return fmt.Errorf("%w: unexpected tpdu code 0x%02x, connection refused", ErrPacketDecode, tpdu[1])
+	}
+	return nil
+}
+
+// EncodeData wraps a message in a COTP Data TPDU.
+func EncodeData(payload []byte) []byte {
+	// COTP Data TPDU
+	//	1 Byte: Length Indicator (2)
+	//	1 Byte: DT Code
+	//	1 Byte: TPDU Number and EOT Flag
+
+	return encodeTPKT(append([]byte{2, tpduData, tpduEOT}, payload...))
+}
+
+// ReadData reads COTP Data TPDUs from the reader until the last of the message, returning the message.
+func ReadData(r io.Reader) ([]byte, error) {
+	var payload []byte
+	for i := 0; i < maxTPDUs; i++ {
+		tpdu, err := readTPKT(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(tpdu) < 3 || int(tpdu[0])+1 > len(tpdu) {
+			return nil, ErrPacketTruncated
+		}
+		if tpdu[1]&0xF0 != tpduData {
+			return nil, fmt.Errorf("%w: unexpected tpdu code 0x%02x", ErrPacketDecode, tpdu[1])
+		}
+
+		payload = append(payload, tpdu[tpdu[0]+1:]...)
+		if tpdu[2]&tpduEOT != 0 {
+			return payload, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: too many data tpdus", ErrPacketDecode)
+}
+
+// Wraps the TPDU in a TPKT header.
+func encodeTPKT(tpdu []byte) []byte {
+	// TPKT Header
+	//	1 Byte: Version (3)
+	//	1 Byte: Reserved
+	//	2 Bytes: Length (Big Endian, including header)
+
+	buf := make([]byte, tpktHeaderSize, tpktHeaderSize+len(tpdu))
+	buf[0] = tpktVersion
+	binary.BigEndian.PutUint16(buf[2:], uint16(tpktHeaderSize+len(tpdu)))
+	return append(buf, tpdu...)
+}
+
+// Reads a TPKT packet, returning the TPDU it carries.
+func readTPKT(r io.Reader) ([]byte, error) {
+	header := make([]byte, tpktHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	if header[0] != tpktVersion {
+		return nil, fmt.Errorf("%w: invalid tpkt version, device is not s7", ErrPacketDecode)
+	}
+
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < tpktHeaderSize {
+		return nil, fmt.Errorf("%w: invalid tpkt length, device is not s7", ErrPacketDecode)
+	}
+
+	tpdu := make([]byte, length-tpktHeaderSize)
+	if _, err := io.ReadFull(r, tpdu); err != nil {
+		return nil, wire.ReadError(err, ErrPacketTruncated, ErrPacketDecode)
+	}
+	return tpdu, nil
+}
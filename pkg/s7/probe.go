@@ -0,0 +1,252 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package s7
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+// Local TSAP sent in the connection request.
+const srcTSAP = 0x0100
+
+// TSAPs lists the destination TSAPs tried in turn: a PG connection to the CPU in rack 0 slot 2, where S7-300
+// CPUs sit, followed by rack 0 slot 0, where S7-400, S7-1200 and S7-1500 CPUs accept connections.
+var TSAPs = []uint16{0x0102, 0x0200}
+
+// PDU size proposed in the setup communication job.
+const proposedPDUSize = 480
+
+// Report contains the identification read from an S7 PLC.
+type Report struct {
+	// TSAP is the destination TSAP the PLC accepted the connection on.
+	TSAP string `json:"tsap"`
+
+	// ConnectErrors contains the reason the connection failed for each TSAP refused before it.
+	ConnectErrors map[string]string `json:"connect_errors,omitempty"`
+
+	// PDUSize is the PDU size negotiated with the PLC.
+	PDUSize uint16 `json:"pdu_size"`
+
+	// Module is the order number of the module, for example "6ES7 315-2EH14-0AB0".
+	Module string `json:"module,omitempty"`
+
+	// Hardware is the order number of the basic hardware.
+	Hardware string `json:"hardware,omitempty"`
+
+	// FirmwareVersion is the version of the basic firmware, for example "V3.2.6".
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+
+	// ModuleError contains the reason the module identification (SZL 0x0011) couldn't be read, if it couldn't.
+	ModuleError string `json:"module_error,omitempty"`
+
+	// SystemName, ModuleName, PlantIdentification, Copyright, SerialNumber, ModuleType, MemorySerialNumber,
+	// OEMID and Location are the component identification values.
+	SystemName          string `json:"system_name,omitempty"`
+	ModuleName          string `json:"module_name,omitempty"`
+	PlantIdentification string `json:"plant_identification,omitempty"`
+	Copyright           string `json:"copyright,omitempty"`
+	SerialNumber        string `json:"serial_number,omitempty"`
+	ModuleType          string `json:"module_type,omitempty"`
+	MemorySerialNumber  string `json:"memory_serial_number,omitempty"`
+	OEMID               string `json:"oem_id,omitempty"`
+	Location            string `json:"location,omitempty"`
+
+	// ComponentError contains the reason the component identification (SZL 0x001C) couldn't be read, if it couldn't.
+	ComponentError string `json:"component_error,omitempty"`
+}
+
+// Probe connects to the PLC on each TSAP in turn until one is accepted, sets up communication and reads the
+// module and component identification system status lists. The delay is waited between each request and
+// connection, to avoid overloading PLCs which are often unable to handle much traffic, after which the
+// connection deadline is reset to the timeout.
+func Probe(dial func() (net.Conn, error), delay, timeout time.Duration) (*Report, error) {
+	report := &Report{
+		ConnectErrors: map[string]string{},
+	}
+
+	// (1) COTP Connection and Setup Communication
+
+	var conn net.Conn
+	var err error
+	for i, tsap := range TSAPs {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+
+		conn, err = dial()
+		if err != nil {
+			return nil, err
+		}
+
+		report.PDUSize, err = connect(conn, tsap, delay, timeout)
+		if err == nil {
+			report.TSAP = fmt.Sprintf("0x%04x", tsap)
+			break
+		}
+
+		_ = conn.Close()
+		report.ConnectErrors[fmt.Sprintf("0x%04x", tsap)] = err.Error()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// (2) Module Identification
+
+	if err := wire.Pause(conn, delay, timeout); err != nil {
+		return nil, err
+	}
+	records, err := readSZL(conn, 2, SZLModuleIdentification)
+	if err != nil {
+		report.ModuleError = err.Error()
+	}
+
+	// Module Identification Record
+	//	2 Bytes: Index
+	//	20 Bytes: Order Number (MlfB)
+	//	2 Bytes: Module Type ID (BGTyp)
+	//	2 Bytes: Version (Ausbg)
+	//	2 Bytes: Version (Ausbe)
+
+	for _, r := range records {
+		switch r.Index() {
+		case 0x0001:
+			report.Module = r.Text(20)
+		case 0x0006:
+			report.Hardware = r.Text(20)
+		case 0x0007:
+			// Firmware versions are encoded as 'V', major, minor and patch.
+			if len(r) >= 28 && r[24] == 'V' {
+				report.FirmwareVersion = fmt.Sprintf("V%d.%d.%d", r[25], r[26], r[27])
+			}
+		}
+	}
+
+	// (3) Component Identification
+
+	if err := wire.Pause(conn, delay, timeout); err != nil {
+		return nil, err
+	}
+	records, err = readSZL(conn, 3, SZLComponentIdentification)
+	if err != nil {
+		report.ComponentError = err.Error()
+	}
+
+	// Component Identification Record
+	//	2 Bytes: Index
+	//	32 Bytes: Name
+
+	for _, r := range records {
+		switch r.Index() {
+		case 0x0001:
+			report.SystemName = r.Text(32)
+		case 0x0002:
+			report.ModuleName = r.Text(32)
+		case 0x0003:
+			report.PlantIdentification = r.Text(32)
+		case 0x0004:
+			report.Copyright = r.Text(32)
+		case 0x0005:
+			report.SerialNumber = r.Text(32)
+		case 0x0007:
+			report.ModuleType = r.Text(32)
+		case 0x0008:
+			report.MemorySerialNumber = r.Text(32)
+		case 0x000A:
+			report.OEMID = r.Text(32)
+		case 0x000B:
+			report.Location = r.Text(32)
+		}
+	}
+
+	return report, nil
+}
+
+// Makes the COTP connection on the TSAP and sets up communication, returning the negotiated PDU size.
+func connect(conn net.Conn, tsap uint16, delay, timeout time.Duration) (uint16, error) {
+	if _, err := conn.Write(EncodeConnectionRequest(srcTSAP, tsap)); err != nil {
+		return 0, err
+	}
+	if err := ReadConnectionConfirm(conn); err != nil {
+		return 0, err
+	}
+
+	if err := wire.Pause(conn, delay, timeout); err != nil {
+		return 0, err
+	}
+	p, err := request(conn, PDU{
+		ROSCTR:     rosctrJob,
+		Reference:  1,
+		Parameters: EncodeSetupCommunication(proposedPDUSize),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if p.ROSCTR != rosctrAckData {
+		return 0, fmt.Errorf("%w: unexpected message type 0x%02x", ErrPacketDecode, p.ROSCTR)
+	}
+	if err := p.Err(); err != nil {
+		return 0, fmt.Errorf("setup communication failed: %w", err)
+	}
+
+	return DecodeSetupCommunication(p.Parameters)
+}
+
+// Reads the whole system status list with the given ID, using the PDU reference.
+func readSZL(rw io.ReadWriter, reference, id uint16) ([]SZLRecord, error) {
+	params, data := EncodeReadSZL(id, 0x0000)
+	p, err := request(rw, PDU{
+		ROSCTR:     rosctrUserData,
+		Reference:  reference,
+		Parameters: params,
+		Data:       data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.ROSCTR != rosctrUserData {
+		return nil, fmt.Errorf("%w: unexpected message type 0x%02x", ErrPacketDecode, p.ROSCTR)
+	}
+
+	return DecodeReadSZL(p.Parameters, p.Data)
+}
+
+// Sends the PDU and reads the response with the same reference.
+func request(rw io.ReadWriter, req PDU) (*PDU, error) {
+	if _, err := rw.Write(EncodePDU(req)); err != nil {
+		return nil, err
+	}
+
+	b, err := ReadData(rw)
+	if err != nil {
+		return nil, err
+	}
+	p, err := DecodePDU(b)
+	if err != nil {
+		return nil, err
+	}
+	if p.Reference != req.Reference {
+		return nil, fmt.Errorf("%w: unexpected pdu reference %d", ErrPacketDecode, p.Reference)
+	}
+	return p, nil
+}
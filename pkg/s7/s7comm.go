@@ -0,0 +1,277 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// S7comm Protocol ID
+const protocolID = 0x32
+
+// S7comm+ Protocol ID, spoken by newer S7-1200 and S7-1500 CPUs.
+const protocolIDPlus = 0x72
+
+// Message Types (ROSCTR)
+const (
+	rosctrJob      = 0x01
+	rosctrAck      = 0x02
+	rosctrAckData  = 0x03
+	rosctrUserData = 0x07
+)
+
+// Function Codes
+const functionSetupCommunication = 0xF0
+
+// SZL IDs
+const (
+	SZLModuleIdentification    = 0x0011
+	SZLComponentIdentification = 0x001C
+)
+
+// Return code of a successful data item.
+const returnCodeSuccess = 0xFF
+
+// PDU is an S7comm protocol data unit.
+type PDU struct {
+	ROSCTR     uint8
+	Reference  uint16
+	ErrorClass uint8
+	ErrorCode  uint8
+	Parameters []byte
+	Data       []byte
+}
+
+// Err returns the error of an acknowledgement, or nil if it reports success.
+func (p *PDU) Err() error {
+	if p.ErrorClass == 0 && p.ErrorCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("error class 0x%02x code 0x%02x", p.ErrorClass, p.ErrorCode)
+}
+
+// SZLRecord is a single data record of a system status list.
+type SZLRecord []byte
+
+// Index returns the index of the record, identifying what the record describes.
+func (r SZLRecord) Index() uint16 {
+	if len(r) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(r)
+}
+
+// Text returns the text following the index, without the padding.
+func (r SZLRecord) Text(length int) string {
+	if len(r) < 2+length {
+		length = len(r) - 2
+	}
+	if length < 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(r[2:2+length]), "\x00"))
+}
+
+// EncodePDU encodes an S7comm PDU, wrapped in a COTP Data TPDU.
+//
+// See https://wiki.wireshark.org/S7comm
+func EncodePDU(p PDU) []byte {
+	// Header
+	//	1 Byte: Protocol ID (0x32)
+	//	1 Byte: Message Type (ROSCTR)
+	//	2 Bytes: Reserved
+	//	2 Bytes: PDU Reference
+	//	2 Bytes: Parameter Length
+	//	2 Bytes: Data Length
+	//	2 Bytes: Error Class and Code (Acknowledgements Only)
+
+	buf := make([]byte, 10, 10+len(p.Parameters)+len(p.Data))
+	buf[0] = protocolID
+	buf[1] = p.ROSCTR
+	binary.BigEndian.PutUint16(buf[4:], p.Reference)
+	binary.BigEndian.PutUint16(buf[6:], uint16(len(p.Parameters)))
+	binary.BigEndian.PutUint16(buf[8:], uint16(len(p.Data)))
+	buf = append(buf, p.Parameters...)
+	buf = append(buf, p.Data...)
+
+	return EncodeData(buf)
+}
+
+// DecodePDU decodes an S7comm PDU.
+func DecodePDU(b []byte) (*PDU, error) {
+	if len(b) < 10 {
+		return nil, ErrPacketTruncated
+	}
+	switch b[0] {
+	case protocolID:
+	case protocolIDPlus:
+		return nil, fmt.Errorf("%w: device speaks s7comm-plus", ErrPacketDecode)
+	default:
+		return nil, fmt.Errorf("%w: unexpected protocol id 0x%02x, device is not s7", ErrPacketDecode, b[0])
+	}
+
+	p := &PDU{
+		ROSCTR:    b[1],
+		Reference: binary.BigEndian.Uint16(b[4:]),
+	}
+
+	pos := 10
+	if p.ROSCTR == rosctrAck || p.ROSCTR == rosctrAckData {
+		if len(b) < 12 {
+			return nil, ErrPacketTruncated
+		}
+		p.ErrorClass = b[10]
+		p.ErrorCode = b[11]
+		pos = 12
+	}
+
+	paramLength := int(binary.BigEndian.Uint16(b[6:]))
+	dataLength := int(binary.BigEndian.Uint16(b[8:]))
+	if pos+paramLength+dataLength > len(b) {
+		return nil, ErrPacketTruncated
+	}
+	p.Parameters = b[pos : pos+paramLength]
+	p.Data = b[pos+paramLength : pos+paramLength+dataLength]
+
+	return p, nil
+}
+
+// EncodeSetupCommunication encodes the parameters of a Setup Communication job, proposing the PDU size.
+func EncodeSetupCommunication(pduSize uint16) []byte {
+	// Parameters
+	//	1 Byte: Function (0xF0)
+	//	1 Byte: Reserved
+	//	2 Bytes: Max AmQ Calling
+	//	2 Bytes: Max AmQ Called
+	//	2 Bytes: PDU Length
+
+	return []byte{functionSetupCommunication, 0, 0, 1, 0, 1, byte(pduSize >> 8), byte(pduSize)}
+}
+
+// DecodeSetupCommunication decodes the parameters of a Setup Communication acknowledgement,
+// returning the negotiated PDU size.
+func DecodeSetupCommunication(params []byte) (uint16, error) {
+	if len(params) < 8 {
+		return 0, ErrPacketTruncated
+	}
+	if params[0] != functionSetupCommunication {
+		return 0, fmt.Errorf("%w: unexpected function 0x%02x", ErrPacketDecode, params[0])
+	}
+	return binary.BigEndian.Uint16(params[6:]), nil
+}
+
+// EncodeReadSZL encodes the parameters and data of a user data request reading the system status list (SZL)
+// with the given ID and index.
+func EncodeReadSZL(id, index uint16) ([]byte, []byte) {
+	// Parameters
+	//	3 Bytes: Parameter Head (0x000112)
+	//	1 Byte: Parameter Length (4)
+	//	1 Byte: Method (0x11, Request)
+	//	1 Byte: Type (4, Request) and Function Group (4, CPU Functions)
+	//	1 Byte: Subfunction (1, Read SZL)
+	//	1 Byte: Sequence Number
+	//
+	// Data
+	//	1 Byte: Return Code (0xFF)
+	//	1 Byte: Transport Size (0x09, Octet String)
+	//	2 Bytes: Length
+	//	2 Bytes: SZL ID
+	//	2 Bytes: SZL Index
+
+	params := []byte{0x00, 0x01, 0x12, 0x04, 0x11, 0x44, 0x01, 0x00}
+	data := []byte{returnCodeSuccess, 0x09, 0x00, 0x04, byte(id >> 8), byte(id), byte(index >> 8), byte(index)}
+	return params, data
+}
+
+// DecodeReadSZL decodes the parameters and data of a read SZL response, returning the records of the list.
+func DecodeReadSZL(params, data []byte) ([]SZLRecord, error) {
+	// Parameters
+	//	3 Bytes: Parameter Head
+	//	1 Byte: Parameter Length (8)
+	//	1 Byte: Method (0x12, Response)
+	//	1 Byte: Type (8, Response) and Function Group
+	//	1 Byte: Subfunction
+	//	1 Byte: Sequence Number
+	//	1 Byte: Data Unit Reference
+	//	1 Byte: Last Data Unit
+	//	2 Bytes: Error Code
+
+	if len(params) < 12 {
+		return nil, ErrPacketTruncated
+	}
+	if code := binary.BigEndian.Uint16(params[10:]); code != 0 {
+		return nil, fmt.Errorf("read szl failed with error code 0x%04x", code)
+	}
+
+	// Data
+	//	1 Byte: Return Code
+	//	1 Byte: Transport Size
+	//	2 Bytes: Length
+	//	2 Bytes: SZL ID
+	//	2 Bytes: SZL Index
+	//	2 Bytes: Record Length
+	//	2 Bytes: Record Count
+	//	Variable: Records
+
+	if len(data) < 4 {
+		return nil, ErrPacketTruncated
+	}
+	if data[0] != returnCodeSuccess {
+		return nil, fmt.Errorf("read szl failed with return code %s", ReturnCodeName(data[0]))
+	}
+	if len(data) < 12 {
+		return nil, ErrPacketTruncated
+	}
+
+	length := int(binary.BigEndian.Uint16(data[8:]))
+	count := int(binary.BigEndian.Uint16(data[10:]))
+	if length == 0 {
+		return nil, fmt.Errorf("%w: zero record length", ErrPacketDecode)
+	}
+
+	// Records which didn't fit in the PDU would need further requests, only those received are returned.
+
+	records := make([]SZLRecord, 0, count)
+	for pos := 12; len(records) < count && pos+length <= len(data); pos += length {
+		records = append(records, SZLRecord(data[pos:pos+length]))
+	}
+	return records, nil
+}
+
+// ReturnCodeName returns the name of a data item return code, for example "OBJECT_DOES_NOT_EXIST".
+func ReturnCodeName(code uint8) string {
+	switch code {
+	case 0x01:
+		return "HARDWARE_FAULT"
+	case 0x03:
+		return "ACCESS_DENIED"
+	case 0x05:
+		return "INVALID_ADDRESS"
+	case 0x06:
+		return "DATA_TYPE_NOT_SUPPORTED"
+	case 0x07:
+		return "DATA_TYPE_INCONSISTENT"
+	case 0x0A:
+		return "OBJECT_DOES_NOT_EXIST"
+	case returnCodeSuccess:
+		return "SUCCESS"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", code)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package smb
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+)
+
+// Report contains the information gathered from an SMB server.
+type Report struct {
+	// SMB2 contains the response to a NEGOTIATE offering every SMB2 dialect.
+	SMB2 *Negotiate `json:"smb2,omitempty"`
+
+	// SMB2Error contains the reason the SMB2 negotiation failed, if it did.
+	SMB2Error string `json:"smb2_error,omitempty"`
+
+	// Dialects lists every SMB2 dialect the server accepted when offered on its own.
+	Dialects []Dialect `json:"dialects"`
+
+	// SMB1 indicates if the server still accepts SMB1.
+	SMB1 bool `json:"smb1"`
+
+	// SMB1Negotiate contains the SMB1 NEGOTIATE response, if the server accepts SMB1.
+	SMB1Negotiate *Negotiate1 `json:"smb1_negotiate,omitempty"`
+
+	// SMB1Error contains the reason the SMB1 negotiation failed, typically because SMB1 is disabled.
+	SMB1Error string `json:"smb1_error,omitempty"`
+}
+
+// Probe negotiates with the server over SMB2 and SMB1, each negotiation on its own connection since
+// a connection can only be negotiated once.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{
+		Dialects: []Dialect{},
+	}
+
+	// (1) SMB2 NEGOTIATE, Offering Every Dialect
+	//		The server selects the highest dialect it supports.
+
+	neg, err := negotiate(dial, Dialects)
+	if err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, err
+		}
+		report.SMB2Error = err.Error()
+	}
+	report.SMB2 = neg
+
+	// (2) SMB2 NEGOTIATE, Offering Each Lower Dialect on its Own
+	//		Servers may disable older dialects, so each is confirmed individually.
+
+	if neg != nil {
+		for _, d := range Dialects {
+			if d == neg.Dialect {
+				report.Dialects = append(report.Dialects, d)
+				continue
+			}
+			if d > neg.Dialect {
+				continue
+			}
+			if _, err := negotiate(dial, []Dialect{d}); err == nil {
+				report.Dialects = append(report.Dialects, d)
+			}
+		}
+	}
+
+	// (3) SMB1 NEGOTIATE
+
+	report.SMB1Negotiate, err = negotiate1(dial)
+	if err != nil {
+		report.SMB1Error = err.Error()
+	}
+	report.SMB1 = report.SMB1Negotiate != nil
+
+	if report.SMB2 == nil && report.SMB1Negotiate == nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Makes a new connection and sends an SMB2 NEGOTIATE for the given dialects.
+func negotiate(dial func() (net.Conn, error), dialects []Dialect) (*Negotiate, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	msg, err := roundTrip(conn, EncodeNegotiate(dialects))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeNegotiate(msg)
+}
+
+// Makes a new connection and sends an SMB1 NEGOTIATE.
+// Servers with SMB1 disabled typically close the connection without responding.
+func negotiate1(dial func() (net.Conn, error)) (*Negotiate1, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	dialects := []string{DialectNTLM012}
+	msg, err := roundTrip(conn, EncodeNegotiate1(dialects))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeNegotiate1(msg, dialects)
+}
+
+// Writes the message and reads the response.
+func roundTrip(rw io.ReadWriter, msg []byte) ([]byte, error) {
+	if err := WriteMessage(rw, msg); err != nil {
+		return nil, err
+	}
+	return ReadMessage(rw)
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package smb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+// SMB1 Protocol ID
+var protocolID1 = []byte{0xFF, 'S', 'M', 'B'}
+
+// SMB1 Commands
+const (
+	commandNegotiate1 = 0x72
+)
+
+// Size of the SMB1 header.
+const headerSize1 = 32
+
+// SMB1 Header Flags
+const (
+	flagsCaseInsensitive = 0x08
+	flagsCanonicalized   = 0x10
+
+	flags2LongNames        = 0x0001
+	flags2ExtendedSecurity = 0x0800
+	flags2NTStatus         = 0x4000
+	flags2Unicode          = 0x8000
+)
+
+// SMB1 Security Mode Flags
+const (
+	securityMode1SigningEnabled  = 0x04
+	securityMode1SigningRequired = 0x08
+)
+
+// DialectNTLM012 is the SMB1 dialect offered, the only one modern SMB1 servers implement.
+const DialectNTLM012 = "NT LM 0.12"
+
+// Negotiate1 represents the server's SMB1 NEGOTIATE response.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-cifs/a4229e1a-8a4e-489a-a2eb-11b7f360e60c
+type Negotiate1 struct {
+	// Dialect is the dialect selected by the server.
+	Dialect string `json:"dialect"`
+
+	// SigningEnabled indicates if the server supports message signing.
+	SigningEnabled bool `json:"signing_enabled"`
+
+	// SigningRequired indicates if the server requires message signing.
+	SigningRequired bool `json:"signing_required"`
+
+	// Capabilities is the raw SMB1 capabilities field.
+	Capabilities uint32 `json:"capabilities"`
+
+	// SystemTime is the server's current system time.
+	SystemTime *time.Time `json:"system_time,omitempty"`
+}
+
+// EncodeNegotiate1 encodes an SMB1 SMB_COM_NEGOTIATE request offering only the given dialects.
+// SMB2 dialect strings are deliberately not offered, so a server only responds if it still accepts SMB1.
+func EncodeNegotiate1(dialects []string) []byte {
+	// SMB1 Header
+	//	4 Bytes: Protocol ID
+	//	1 Byte: Command
+	//	4 Bytes: Status
+	//	1 Byte: Flags
+	//	2 Bytes: Flags2
+	//	2 Bytes: PID High
+	//	8 Bytes: Security Features
+	//	2 Bytes: Reserved
+	//	2 Bytes: TID
+	//	2 Bytes: PID Low
+	//	2 Bytes: UID
+	//	2 Bytes: MID
+
+	msg := make([]byte, headerSize1)
+	copy(msg, protocolID1)
+	msg[4] = commandNegotiate1
+	msg[9] = flagsCaseInsensitive | flagsCanonicalized
+	binary.LittleEndian.PutUint16(msg[10:], flags2LongNames|flags2ExtendedSecurity|flags2NTStatus|flags2Unicode)
+
+	// SMB_COM_NEGOTIATE Request
+	//	1 Byte: Word Count (0)
+	//	2 Bytes: Byte Count
+	//	Variable: Dialects (0x02 followed by a null terminated name)
+
+	var data []byte
+	for _, d := range dialects {
+		data = append(data, 0x02)
+		data = append(data, d...)
+		data = append(data, 0)
+	}
+
+	msg = append(msg, 0, byte(len(data)), byte(len(data)>>8))
+	return append(msg, data...)
+}
+
+// DecodeNegotiate1 attempts to decode the given message as an SMB1 NEGOTIATE response to the given dialects.
+func DecodeNegotiate1(msg []byte, dialects []string) (*Negotiate1, error) {
+	header, pos, err := wire.ReadBuffer(msg, 0, headerSize1)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+	if string(header[:4]) != string(protocolID1) {
+		return nil, fmt.Errorf("%w: invalid protocol id, connection is not smb1", ErrMessageDecode)
+	}
+	if header[4] != commandNegotiate1 {
+		return nil, fmt.Errorf("%w: unexpected command 0x%02x", ErrMessageDecode, header[4])
+	}
+	if status := binary.LittleEndian.Uint32(header[5:]); status != 0 {
+		return nil, &StatusError{Status: status}
+	}
+
+	// SMB_COM_NEGOTIATE Response (NT LM 0.12)
+	//	1 Byte: Word Count (17, or 1 if no dialect was accepted)
+	//	2 Bytes: Dialect Index
+	//	1 Byte: Security Mode
+	//	2 Bytes: Max Mpx Count
+	//	2 Bytes: Max Number VCs
+	//	4 Bytes: Max Buffer Size
+	//	4 Bytes: Max Raw Size
+	//	4 Bytes: Session Key
+	//	4 Bytes: Capabilities
+	//	8 Bytes: System Time (FILETIME)
+	//	2 Bytes: Server Time Zone
+	//	1 Byte: Challenge Length
+
+	sub, pos, err := wire.ReadBuffer(msg, pos, 1)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+	words, _, err := wire.ReadBuffer(msg, pos, int(sub[0])*2)
+	if err != nil || len(words) < 2 {
+		return nil, ErrMessageTruncated
+	}
+
+	index := int(binary.LittleEndian.Uint16(words))
+	if index == 0xFFFF {
+		return nil, fmt.Errorf("server accepted none of the offered smb1 dialects")
+	}
+	if index >= len(dialects) {
+		return nil, fmt.Errorf("%w: invalid dialect index %d", ErrMessageDecode, index)
+	}
+
+	neg := &Negotiate1{
+		Dialect: dialects[index],
+	}
+	if len(words) >= 34 {
+		neg.SigningEnabled = words[2]&securityMode1SigningEnabled != 0
+		neg.SigningRequired = words[2]&securityMode1SigningRequired != 0
+		neg.Capabilities = binary.LittleEndian.Uint32(words[19:])
+		neg.SystemTime = decodeFiletime(words[23:])
+	}
+
+	return neg, nil
+}
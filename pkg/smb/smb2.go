@@ -0,0 +1,473 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package smb
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+// SMB2 Protocol ID
+var protocolID2 = []byte{0xFE, 'S', 'M', 'B'}
+
+// SMB2 Commands
+const (
+	commandNegotiate = 0x0000
+)
+
+// Size of the SMB2 header.
+const headerSize2 = 64
+
+// SMB2 Security Mode Flags
+const (
+	securityModeSigningEnabled  = 0x0001
+	securityModeSigningRequired = 0x0002
+)
+
+// SMB2 Negotiate Context Types
+const (
+	contextPreauthIntegrity = 0x0001
+	contextEncryption       = 0x0002
+	contextSigning          = 0x0008
+)
+
+// Dialect is an SMB2 dialect revision.
+type Dialect uint16
+
+// Dialects
+const (
+	Dialect202 Dialect = 0x0202
+	Dialect210 Dialect = 0x0210
+	Dialect300 Dialect = 0x0300
+	Dialect302 Dialect = 0x0302
+	Dialect311 Dialect = 0x0311
+)
+
+// Dialects lists every SMB2 dialect offered, in ascending order.
+var Dialects = []Dialect{Dialect202, Dialect210, Dialect300, Dialect302, Dialect311}
+
+func (d Dialect) String() string {
+	switch d {
+	case Dialect202:
+		return "2.0.2"
+	case Dialect210:
+		return "2.1"
+	case Dialect300:
+		return "3.0"
+	case Dialect302:
+		return "3.0.2"
+	case Dialect311:
+		return "3.1.1"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%04x)", uint16(d))
+	}
+}
+
+func (d Dialect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Capability is an SMB2 capabilities composite flag field.
+type Capability uint32
+
+// Capability Flags
+const (
+	CapabilityDFS               Capability = 0x00000001
+	CapabilityLeasing           Capability = 0x00000002
+	CapabilityLargeMTU          Capability = 0x00000004
+	CapabilityMultiChannel      Capability = 0x00000008
+	CapabilityPersistentHandles Capability = 0x00000010
+	CapabilityDirectoryLeasing  Capability = 0x00000020
+	CapabilityEncryption        Capability = 0x00000040
+)
+
+// Has determines if the Capability contains the given Capability flag.
+func (c Capability) Has(cap Capability) bool {
+	return (c & cap) == cap
+}
+
+func (c Capability) MarshalJSON() ([]byte, error) {
+	names := []string{}
+
+	if c.Has(CapabilityDFS) {
+		names = append(names, "SMB2_GLOBAL_CAP_DFS")
+	}
+	if c.Has(CapabilityLeasing) {
+		names = append(names, "SMB2_GLOBAL_CAP_LEASING")
+	}
+	if c.Has(CapabilityLargeMTU) {
+		names = append(names, "SMB2_GLOBAL_CAP_LARGE_MTU")
+	}
+	if c.Has(CapabilityMultiChannel) {
+		names = append(names, "SMB2_GLOBAL_CAP_MULTI_CHANNEL")
+	}
+	if c.Has(CapabilityPersistentHandles) {
+		names = append(names, "SMB2_GLOBAL_CAP_PERSISTENT_HANDLES")
+	}
+	if c.Has(CapabilityDirectoryLeasing) {
+		names = append(names, "SMB2_GLOBAL_CAP_DIRECTORY_LEASING")
+	}
+	if c.Has(CapabilityEncryption) {
+		names = append(names, "SMB2_GLOBAL_CAP_ENCRYPTION")
+	}
+
+	return json.Marshal(names)
+}
+
+// StatusError is returned when the server responds with a failure NTSTATUS code.
+type StatusError struct {
+	Status uint32
+}
+
+func (e *StatusError) Error() string {
+	switch e.Status {
+	case 0xC0000002:
+		return "server responded with STATUS_NOT_IMPLEMENTED"
+	case 0xC000000D:
+		return "server responded with STATUS_INVALID_PARAMETER"
+	case 0xC0000022:
+		return "server responded with STATUS_ACCESS_DENIED"
+	case 0xC00000BB:
+		return "server responded with STATUS_NOT_SUPPORTED"
+	default:
+		return fmt.Sprintf("server responded with NTSTATUS 0x%08x", e.Status)
+	}
+}
+
+// Negotiate represents the server's SMB2 NEGOTIATE response.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/63abf97c-0d09-47e2-88d6-6bfa552949a5
+type Negotiate struct {
+	// Dialect is the dialect selected by the server.
+	Dialect Dialect `json:"dialect"`
+
+	// SigningEnabled indicates if the server supports message signing.
+	SigningEnabled bool `json:"signing_enabled"`
+
+	// SigningRequired indicates if the server requires message signing.
+	SigningRequired bool `json:"signing_required"`
+
+	// ServerGUID is the server's GUID.
+	ServerGUID string `json:"server_guid"`
+
+	// Capabilities are the server's global capabilities.
+	Capabilities Capability `json:"capabilities"`
+
+	// MaxTransactSize is the maximum transaction buffer size.
+	MaxTransactSize uint32 `json:"max_transact_size"`
+
+	// MaxReadSize is the maximum read size.
+	MaxReadSize uint32 `json:"max_read_size"`
+
+	// MaxWriteSize is the maximum write size.
+	MaxWriteSize uint32 `json:"max_write_size"`
+
+	// SystemTime is the server's current system time.
+	SystemTime *time.Time `json:"system_time,omitempty"`
+
+	// ServerStartTime is the time the server started, rarely sent by modern servers.
+	ServerStartTime *time.Time `json:"server_start_time,omitempty"`
+
+	// PreauthIntegrityHash is the pre-authentication integrity hash algorithm (3.1.1 only).
+	PreauthIntegrityHash string `json:"preauth_integrity_hash,omitempty"`
+
+	// Cipher is the encryption cipher selected by the server (3.1.1 only).
+	Cipher string `json:"cipher,omitempty"`
+
+	// SigningAlgorithm is the signing algorithm selected by the server (3.1.1 only).
+	SigningAlgorithm string `json:"signing_algorithm,omitempty"`
+}
+
+// EncodeNegotiate encodes an SMB2 NEGOTIATE request offering the given dialects.
+// Negotiate contexts are included when 3.1.1 is offered, since servers reject a 3.1.1 negotiation without them.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/e14db7ff-763a-4263-8b10-0c3944f52fc5
+func EncodeNegotiate(dialects []Dialect) []byte {
+	msg := encodeHeader2(commandNegotiate)
+
+	// NEGOTIATE Request
+	//	2 Bytes: Structure Size (36)
+	//	2 Bytes: Dialect Count
+	//	2 Bytes: Security Mode
+	//	2 Bytes: Reserved
+	//	4 Bytes: Capabilities
+	//	16 Bytes: Client GUID
+	//	4 Bytes: Negotiate Context Offset (3.1.1) / Client Start Time
+	//	2 Bytes: Negotiate Context Count (3.1.1) / Client Start Time
+	//	2 Bytes: Reserved / Client Start Time
+	//	Variable: Dialects (2 Bytes each)
+
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(body[4:], securityModeSigningEnabled)
+	binary.LittleEndian.PutUint32(body[8:], uint32(CapabilityEncryption|CapabilityLargeMTU|CapabilityLeasing))
+	_, _ = rand.Read(body[12:28])
+	for _, d := range dialects {
+		body = append(body, byte(d), byte(d>>8))
+	}
+	msg = append(msg, body...)
+
+	offer311 := false
+	for _, d := range dialects {
+		if d == Dialect311 {
+			offer311 = true
+		}
+	}
+	if !offer311 {
+		return msg
+	}
+
+	// Negotiate Contexts
+	//	Each context is 8 byte aligned, relative to the start of the SMB2 header.
+
+	salt := make([]byte, 32)
+	_, _ = rand.Read(salt)
+
+	contexts := [][]byte{
+		encodeContext(contextPreauthIntegrity, append([]byte{1, 0, 32, 0, 1, 0}, salt...)),
+		encodeContext(contextEncryption, []byte{4, 0, 2, 0, 1, 0, 4, 0, 3, 0}),
+		encodeContext(contextSigning, []byte{3, 0, 2, 0, 1, 0, 0, 0}),
+	}
+
+	msg = pad8(msg)
+	binary.LittleEndian.PutUint32(msg[headerSize2+28:], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[headerSize2+32:], uint16(len(contexts)))
+	for i, ctx := range contexts {
+		if i > 0 {
+			msg = pad8(msg)
+		}
+		msg = append(msg, ctx...)
+	}
+
+	return msg
+}
+
+// DecodeNegotiate attempts to decode the given message as an SMB2 NEGOTIATE response.
+func DecodeNegotiate(msg []byte) (*Negotiate, error) {
+	if err := decodeHeader2(msg, commandNegotiate); err != nil {
+		return nil, err
+	}
+
+	// NEGOTIATE Response
+	//	2 Bytes: Structure Size (65)
+	//	2 Bytes: Security Mode
+	//	2 Bytes: Dialect Revision
+	//	2 Bytes: Negotiate Context Count (3.1.1)
+	//	16 Bytes: Server GUID
+	//	4 Bytes: Capabilities
+	//	4 Bytes: Max Transact Size
+	//	4 Bytes: Max Read Size
+	//	4 Bytes: Max Write Size
+	//	8 Bytes: System Time (FILETIME)
+	//	8 Bytes: Server Start Time (FILETIME)
+	//	2 Bytes: Security Buffer Offset
+	//	2 Bytes: Security Buffer Length
+	//	4 Bytes: Negotiate Context Offset (3.1.1)
+
+	body, _, err := wire.ReadBuffer(msg, headerSize2, 64)
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+	if binary.LittleEndian.Uint16(body) != 65 {
+		return nil, fmt.Errorf("%w: invalid negotiate response structure size", ErrMessageDecode)
+	}
+
+	securityMode := binary.LittleEndian.Uint16(body[2:])
+	neg := &Negotiate{
+		Dialect:         Dialect(binary.LittleEndian.Uint16(body[4:])),
+		SigningEnabled:  securityMode&securityModeSigningEnabled != 0,
+		SigningRequired: securityMode&securityModeSigningRequired != 0,
+		ServerGUID:      formatGUID(body[8:24]),
+		Capabilities:    Capability(binary.LittleEndian.Uint32(body[24:])),
+		MaxTransactSize: binary.LittleEndian.Uint32(body[28:]),
+		MaxReadSize:     binary.LittleEndian.Uint32(body[32:]),
+		MaxWriteSize:    binary.LittleEndian.Uint32(body[36:]),
+		SystemTime:      decodeFiletime(body[40:]),
+		ServerStartTime: decodeFiletime(body[48:]),
+	}
+
+	if neg.Dialect != Dialect311 {
+		return neg, nil
+	}
+
+	// Negotiate Contexts
+	//	2 Bytes: Context Type
+	//	2 Bytes: Data Length
+	//	4 Bytes: Reserved
+	//	Variable: Data
+
+	count := int(binary.LittleEndian.Uint16(body[6:]))
+	pos := int(binary.LittleEndian.Uint32(body[60:]))
+	for i := 0; i < count; i++ {
+		pos = (pos + 7) &^ 7
+
+		sub, next, err := wire.ReadBuffer(msg, pos, 8)
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+		typ := binary.LittleEndian.Uint16(sub)
+		data, next, err := wire.ReadBuffer(msg, next, int(binary.LittleEndian.Uint16(sub[2:])))
+		if err != nil {
+			return nil, ErrMessageTruncated
+		}
+		pos = next
+
+		// Each context the server selects from carries a count followed by the single selected value.
+
+		switch typ {
+		case contextPreauthIntegrity:
+			if len(data) >= 6 {
+				neg.PreauthIntegrityHash = hashName(binary.LittleEndian.Uint16(data[4:]))
+			}
+		case contextEncryption:
+			if len(data) >= 4 {
+				neg.Cipher = cipherName(binary.LittleEndian.Uint16(data[2:]))
+			}
+		case contextSigning:
+			if len(data) >= 4 {
+				neg.SigningAlgorithm = signingName(binary.LittleEndian.Uint16(data[2:]))
+			}
+		}
+	}
+
+	return neg, nil
+}
+
+// Encodes an SMB2 sync header for the given command.
+func encodeHeader2(command uint16) []byte {
+	// SMB2 Header
+	//	4 Bytes: Protocol ID
+	//	2 Bytes: Structure Size (64)
+	//	2 Bytes: Credit Charge
+	//	4 Bytes: Status
+	//	2 Bytes: Command
+	//	2 Bytes: Credit Request
+	//	4 Bytes: Flags
+	//	4 Bytes: Next Command
+	//	8 Bytes: Message ID
+	//	4 Bytes: Reserved (Process ID)
+	//	4 Bytes: Tree ID
+	//	8 Bytes: Session ID
+	//	16 Bytes: Signature
+
+	header := make([]byte, headerSize2)
+	copy(header, protocolID2)
+	binary.LittleEndian.PutUint16(header[4:], headerSize2)
+	binary.LittleEndian.PutUint16(header[12:], command)
+	binary.LittleEndian.PutUint16(header[14:], 1)
+	return header
+}
+
+// Validates an SMB2 header, returning a StatusError if the server responded with a failure status.
+func decodeHeader2(msg []byte, command uint16) error {
+	header, _, err := wire.ReadBuffer(msg, 0, headerSize2)
+	if err != nil {
+		return ErrMessageTruncated
+	}
+	if string(header[:4]) != string(protocolID2) {
+		return fmt.Errorf("%w: invalid protocol id, connection is not smb2", ErrMessageDecode)
+	}
+	if binary.LittleEndian.Uint16(header[12:]) != command {
+		return fmt.Errorf("%w: unexpected command 0x%04x", ErrMessageDecode, binary.LittleEndian.Uint16(header[12:]))
+	}
+	if status := binary.LittleEndian.Uint32(header[8:]); status != 0 {
+		return &StatusError{Status: status}
+	}
+	return nil
+}
+
+// Encodes a single negotiate context.
+func encodeContext(typ uint16, data []byte) []byte {
+	ctx := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint16(ctx, typ)
+	binary.LittleEndian.PutUint16(ctx[2:], uint16(len(data)))
+	return append(ctx, data...)
+}
+
+// Pads the message with zeros to an 8 byte boundary.
+func pad8(b []byte) []byte {
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// Formats a GUID in its mixed endian string representation.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]), binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// Decodes a FILETIME, the number of 100 nanosecond intervals since January 1, 1601 UTC.
+// Zero is returned as nil, servers send it when the time is not available.
+func decodeFiletime(b []byte) *time.Time {
+	// Number of 100 nanosecond intervals between 1601 and the Unix epoch.
+	const epochDelta = 116444736000000000
+
+	ft := binary.LittleEndian.Uint64(b)
+	if ft == 0 {
+		return nil
+	}
+
+	t := time.Unix(0, 0).Add(time.Duration(int64(ft)-epochDelta) * 100).UTC()
+	return &t
+}
+
+func hashName(id uint16) string {
+	switch id {
+	case 0x0001:
+		return "SHA-512"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%04x)", id)
+	}
+}
+
+func cipherName(id uint16) string {
+	switch id {
+	case 0x0000:
+		return "NONE"
+	case 0x0001:
+		return "AES-128-CCM"
+	case 0x0002:
+		return "AES-128-GCM"
+	case 0x0003:
+		return "AES-256-CCM"
+	case 0x0004:
+		return "AES-256-GCM"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%04x)", id)
+	}
+}
+
+func signingName(id uint16) string {
+	switch id {
+	case 0x0000:
+		return "HMAC-SHA256"
+	case 0x0001:
+		return "AES-CMAC"
+	case 0x0002:
+		return "AES-GMAC"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%04x)", id)
+	}
+}
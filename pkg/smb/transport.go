@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package smb provides facilities for probing and inspecting SMB (Server Message Block) servers.
+package smb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated message or not an smb response", ErrMessageDecode)
+
+// Maximum size of a single SMB message accepted from the server.
+const maxMessageSize = 1 << 20
+
+// WriteMessage writes an SMB message using the Direct TCP transport framing.
+//
+// See https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-smb2/1dfacde4-b5c7-4494-8a14-a09d3ab4cc83
+func WriteMessage(w io.Writer, msg []byte) error {
+	// Direct TCP Transport Header
+	//	1 Byte: Zero
+	//	3 Bytes: Stream Protocol Length (Big Endian)
+
+	buf := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	buf[0] = 0
+
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
+
+// ReadMessage reads an SMB message using the Direct TCP transport framing.
+func ReadMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+	}
+	if header[0] != 0 {
+		return nil, fmt.Errorf("%w: invalid transport header, connection is not smb", ErrMessageDecode)
+	}
+
+	length := int(binary.BigEndian.Uint32(header) & 0xFFFFFF)
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("%w: message length %d exceeds maximum, connection is not smb", ErrMessageDecode, length)
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, wire.ReadError(err, ErrMessageTruncated, ErrMessageDecode)
+	}
+	return msg, nil
+}
@@ -0,0 +1,317 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package snmp provides facilities for probing and inspecting SNMP agents.
+package snmp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/seglberg/protoscan/pkg/ber"
+)
+
+var ErrMessageDecode = fmt.Errorf("message decode")
+var ErrMessageTruncated = fmt.Errorf("%w: truncated message or not an snmp response", ErrMessageDecode)
+
+// Version is the SNMP message version.
+type Version int
+
+// Versions
+const (
+	Version1  Version = 0
+	Version2c Version = 1
+	Version3  Version = 3
+)
+
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "v1"
+	case Version2c:
+		return "v2c"
+	case Version3:
+		return "v3"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int(v))
+	}
+}
+
+// PDU Types (CONTEXT tags)
+const (
+	pduGetRequest = 0
+	pduResponse   = 2
+	pduReport     = 8
+)
+
+// Application Types (APPLICATION tags)
+const (
+	typeIPAddress = 0
+	typeCounter32 = 1
+	typeGauge32   = 2
+	typeTimeTicks = 3
+	typeOpaque    = 4
+	typeCounter64 = 6
+)
+
+// Exception Values (CONTEXT tags, v2c only)
+const (
+	exceptionNoSuchObject   = 0
+	exceptionNoSuchInstance = 1
+	exceptionEndOfMibView   = 2
+)
+
+// USM Security Model
+const securityModelUSM = 3
+
+// Message Flags
+const flagReportable = 0x04
+
+// Maximum message size advertised in SNMPv3 requests.
+const maxMessageSize = 65507
+
+// VarBind is a decoded variable binding.
+type VarBind struct {
+	// OID is the object identifier.
+	OID string
+
+	// Value is the decoded value: a string for OCTET STRING, OBJECT IDENTIFIER and IpAddress, an int64 for INTEGER,
+	// a uint64 for the counter, gauge and time types, and nil for NULL and the v2c exceptions.
+	Value interface{}
+
+	// Exception is the name of the v2c exception returned instead of a value, for example "noSuchObject".
+	Exception string
+}
+
+// PDU is a decoded SNMP PDU.
+type PDU struct {
+	// Type is the PDU type tag.
+	Type uint8
+
+	// RequestID is the request ID.
+	RequestID int64
+
+	// ErrorStatus is the error status, 0 for noError.
+	ErrorStatus int64
+
+	// ErrorIndex is the index of the variable binding the error applies to.
+	ErrorIndex int64
+
+	// VarBinds contains the variable bindings.
+	VarBinds []VarBind
+}
+
+// Message is a decoded SNMPv1 or SNMPv2c message.
+type Message struct {
+	Version   Version
+	Community string
+	PDU       *PDU
+}
+
+// NewRequestID returns a random positive request ID.
+func NewRequestID() int64 {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return int64(binary.BigEndian.Uint32(b) & 0x7FFFFFFF)
+}
+
+// EncodeGetRequest encodes an SNMPv1 or SNMPv2c GetRequest for the given OIDs.
+//
+// See https://tools.ietf.org/html/rfc3416#section-3
+func EncodeGetRequest(version Version, community string, requestID int64, oids []string) ([]byte, error) {
+	pdu, err := encodePDU(pduGetRequest, requestID, oids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Message
+	//	version, community, data (PDU)
+
+	return ber.Sequence(
+		ber.Integer(int64(version)),
+		ber.OctetString(community),
+		pdu,
+	), nil
+}
+
+// DecodeMessage attempts to decode the given datagram as an SNMPv1 or SNMPv2c message.
+func DecodeMessage(datagram []byte) (*Message, error) {
+	children, err := decodeSequence(datagram)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) < 3 {
+		return nil, ErrMessageTruncated
+	}
+
+	version, err := children[0].Int()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid version", ErrMessageDecode)
+	}
+
+	pdu, err := decodePDU(children[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Version:   Version(version),
+		Community: string(children[1].Value),
+		PDU:       pdu,
+	}, nil
+}
+
+// Encodes a PDU requesting the given OIDs, each with a NULL value.
+func encodePDU(typ uint8, requestID int64, oids []string) ([]byte, error) {
+	// PDU
+	//	request-id, error-status, error-index, variable-bindings (SEQUENCE OF SEQUENCE { name, value })
+
+	varbinds := make([][]byte, len(oids))
+	for i, oid := range oids {
+		name, err := ber.ObjectIdentifier(oid)
+		if err != nil {
+			return nil, err
+		}
+		varbinds[i] = ber.Sequence(name, ber.Null())
+	}
+
+	return ber.Encode(ber.ClassContext, true, typ, concat(
+		ber.Integer(requestID),
+		ber.Integer(0),
+		ber.Integer(0),
+		ber.Sequence(varbinds...),
+	)), nil
+}
+
+// Decodes a PDU element.
+func decodePDU(e *ber.Element) (*PDU, error) {
+	if e.Class != ber.ClassContext || !e.Constructed {
+		return nil, fmt.Errorf("%w: invalid pdu", ErrMessageDecode)
+	}
+
+	children, err := e.Children()
+	if err != nil || len(children) < 4 {
+		return nil, ErrMessageTruncated
+	}
+
+	pdu := &PDU{Type: e.Tag}
+	for i, v := range []*int64{&pdu.RequestID, &pdu.ErrorStatus, &pdu.ErrorIndex} {
+		if *v, err = children[i].Int(); err != nil {
+			return nil, fmt.Errorf("%w: invalid pdu header", ErrMessageDecode)
+		}
+	}
+
+	varbinds, err := children[3].Children()
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+	for _, vb := range varbinds {
+		parts, err := vb.Children()
+		if err != nil || len(parts) < 2 {
+			return nil, ErrMessageTruncated
+		}
+		oid, err := parts[0].OID()
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid variable binding name", ErrMessageDecode)
+		}
+		value, exception, err := decodeValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		pdu.VarBinds = append(pdu.VarBinds, VarBind{OID: oid, Value: value, Exception: exception})
+	}
+
+	return pdu, nil
+}
+
+// Decodes a variable binding value, returning the value or the name of the v2c exception.
+func decodeValue(e *ber.Element) (interface{}, string, error) {
+	switch {
+	case e.Is(ber.ClassUniversal, ber.TagInteger):
+		v, err := e.Int()
+		return v, "", err
+	case e.Is(ber.ClassUniversal, ber.TagOctetString):
+		return string(e.Value), "", nil
+	case e.Is(ber.ClassUniversal, ber.TagOID):
+		v, err := e.OID()
+		return v, "", err
+	case e.Is(ber.ClassUniversal, ber.TagNull):
+		return nil, "", nil
+	case e.Is(ber.ClassApplication, typeIPAddress):
+		if len(e.Value) != 4 {
+			return nil, "", fmt.Errorf("%w: invalid ip address", ErrMessageDecode)
+		}
+		return net.IP(e.Value).String(), "", nil
+	case e.Is(ber.ClassApplication, typeCounter32), e.Is(ber.ClassApplication, typeGauge32),
+		e.Is(ber.ClassApplication, typeTimeTicks), e.Is(ber.ClassApplication, typeCounter64):
+		v, err := e.Uint()
+		return v, "", err
+	case e.Is(ber.ClassApplication, typeOpaque):
+		return fmt.Sprintf("%x", e.Value), "", nil
+	case e.Is(ber.ClassContext, exceptionNoSuchObject):
+		return nil, "noSuchObject", nil
+	case e.Is(ber.ClassContext, exceptionNoSuchInstance):
+		return nil, "noSuchInstance", nil
+	case e.Is(ber.ClassContext, exceptionEndOfMibView):
+		return nil, "endOfMibView", nil
+	default:
+		return fmt.Sprintf("%x", e.Value), "", nil
+	}
+}
+
+// Decodes the datagram as a single SEQUENCE, returning its elements.
+func decodeSequence(datagram []byte) ([]*ber.Element, error) {
+	e, _, err := ber.Decode(datagram)
+	if err != nil {
+		if errors.Is(err, ber.ErrTruncated) {
+			return nil, ErrMessageTruncated
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMessageDecode, err)
+	}
+	if !e.Is(ber.ClassUniversal, ber.TagSequence) {
+		return nil, fmt.Errorf("%w: message is not a sequence, not an snmp response", ErrMessageDecode)
+	}
+
+	children, err := e.Children()
+	if err != nil {
+		return nil, ErrMessageTruncated
+	}
+	return children, nil
+}
+
+// ErrorStatusName returns the name of a PDU error status.
+func ErrorStatusName(status int64) string {
+	names := []string{
+		"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr", "noAccess", "wrongType",
+		"wrongLength", "wrongEncoding", "wrongValue", "noCreation", "inconsistentValue", "resourceUnavailable",
+		"commitFailed", "undoFailed", "authorizationError", "notWritable", "inconsistentName",
+	}
+	if status >= 0 && status < int64(len(names)) {
+		return names[status]
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status)
+}
+
+func concat(elements ...[]byte) []byte {
+	var buf []byte
+	for _, e := range elements {
+		buf = append(buf, e...)
+	}
+	return buf
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snmp
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/seglberg/protoscan/pkg/udp"
+)
+
+// System Group OIDs
+const (
+	OIDSysDescr    = "1.3.6.1.2.1.1.1.0"
+	OIDSysObjectID = "1.3.6.1.2.1.1.2.0"
+	OIDSysUpTime   = "1.3.6.1.2.1.1.3.0"
+	OIDSysName     = "1.3.6.1.2.1.1.5.0"
+)
+
+// SystemOIDs lists the system group OIDs requested with each community.
+var SystemOIDs = []string{OIDSysDescr, OIDSysObjectID, OIDSysUpTime, OIDSysName}
+
+// Report contains the information gathered from an SNMP agent.
+type Report struct {
+	// Communities contains the results for each community the agent accepted.
+	Communities []Community `json:"communities"`
+
+	// CommunityErrors contains the reason each remaining community failed, typically no response
+	// since agents silently drop requests with an unknown community.
+	CommunityErrors map[string]string `json:"community_errors,omitempty"`
+
+	// V3 contains the SNMPv3 discovery results, if the agent speaks SNMPv3.
+	V3 *V3 `json:"v3,omitempty"`
+
+	// V3Error contains the reason the SNMPv3 discovery failed, if it did.
+	V3Error string `json:"v3_error,omitempty"`
+}
+
+// Community contains the system group values read with an accepted community.
+type Community struct {
+	// Community is the community string.
+	Community string `json:"community"`
+
+	// Version is the SNMP version the community was accepted with, "v2c" or "v1".
+	Version string `json:"version"`
+
+	// ErrorStatus is the error status of the response, if not noError.
+	ErrorStatus string `json:"error_status,omitempty"`
+
+	// SysDescr is the textual description of the device.
+	SysDescr string `json:"sys_descr,omitempty"`
+
+	// SysObjectID is the vendor's authoritative identification of the device.
+	SysObjectID string `json:"sys_object_id,omitempty"`
+
+	// SysName is the administratively assigned name of the device.
+	SysName string `json:"sys_name,omitempty"`
+
+	// SysUpTime is the time since the agent was last re-initialized, in hundredths of a second.
+	SysUpTime *uint64 `json:"sys_uptime,omitempty"`
+
+	// Uptime is SysUpTime in human readable form, for example "72h15m3.5s".
+	Uptime string `json:"uptime,omitempty"`
+}
+
+// V3 contains the SNMPv3 engine information revealed by discovery.
+type V3 struct {
+	// EngineID is the hex encoded authoritative engine ID.
+	EngineID string `json:"engine_id"`
+
+	// Engine contains the decoded engine ID fields, including the vendor's enterprise number.
+	Engine *EngineID `json:"engine,omitempty"`
+
+	// EngineBoots is the number of times the engine has (re)initialized.
+	EngineBoots int64 `json:"engine_boots"`
+
+	// EngineTime is the number of seconds since the engine last (re)initialized.
+	EngineTime int64 `json:"engine_time"`
+
+	// LastBoot is the approximate time the engine last (re)initialized.
+	LastBoot time.Time `json:"last_boot"`
+}
+
+// Probe sends an SNMPv3 discovery request, then a GetRequest for the system group with each community,
+// first as SNMPv2c, falling back to SNMPv1 if the agent doesn't respond.
+func Probe(t *udp.Transport, communities []string) (*Report, error) {
+	report := &Report{
+		Communities:     []Community{},
+		CommunityErrors: map[string]string{},
+	}
+
+	// (1) SNMPv3 Discovery
+
+	var err error
+	report.V3, err = discover(t)
+	if err != nil {
+		if errors.Is(err, udp.ErrPortUnreachable) {
+			return nil, err
+		}
+		report.V3Error = err.Error()
+	}
+
+	// (2) SNMPv2c / SNMPv1 GetRequest for each Community
+
+	for _, community := range communities {
+		var result *Community
+		for _, version := range []Version{Version2c, Version1} {
+			result, err = get(t, version, community)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, udp.ErrPortUnreachable) {
+				return nil, err
+			}
+		}
+
+		if err != nil {
+			report.CommunityErrors[community] = err.Error()
+			continue
+		}
+		report.Communities = append(report.Communities, *result)
+	}
+
+	if report.V3 == nil && len(report.Communities) == 0 {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Sends an SNMPv3 discovery request and decodes the engine information from the response.
+func discover(t *udp.Transport) (*V3, error) {
+	messageID := NewRequestID()
+
+	var d *Discovery
+	_, err := t.Exchange(EncodeDiscovery(messageID, NewRequestID()), func(datagram []byte) bool {
+		resp, err := DecodeDiscovery(datagram)
+		if err != nil || resp.MessageID != messageID {
+			return false
+		}
+		d = resp
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v3 := &V3{
+		EngineID:    hex.EncodeToString(d.EngineID),
+		EngineBoots: d.EngineBoots,
+		EngineTime:  d.EngineTime,
+		LastBoot:    time.Now().Add(-time.Duration(d.EngineTime) * time.Second).Truncate(time.Second),
+	}
+	v3.Engine, _ = DecodeEngineID(d.EngineID)
+
+	return v3, nil
+}
+
+// Sends a GetRequest for the system group with the given version and community.
+func get(t *udp.Transport, version Version, community string) (*Community, error) {
+	requestID := NewRequestID()
+	req, err := EncodeGetRequest(version, community, requestID, SystemOIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg *Message
+	_, err = t.Exchange(req, func(datagram []byte) bool {
+		resp, err := DecodeMessage(datagram)
+		if err != nil || resp.PDU.Type != pduResponse || resp.PDU.RequestID != requestID {
+			return false
+		}
+		msg = resp
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Community{
+		Community: community,
+		Version:   version.String(),
+	}
+	if msg.PDU.ErrorStatus != 0 {
+		result.ErrorStatus = ErrorStatusName(msg.PDU.ErrorStatus)
+	}
+
+	for _, vb := range msg.PDU.VarBinds {
+		switch vb.OID {
+		case OIDSysDescr:
+			result.SysDescr, _ = vb.Value.(string)
+		case OIDSysObjectID:
+			result.SysObjectID, _ = vb.Value.(string)
+		case OIDSysName:
+			result.SysName, _ = vb.Value.(string)
+		case OIDSysUpTime:
+			if ticks, ok := vb.Value.(uint64); ok {
+				result.SysUpTime = &ticks
+				result.Uptime = (time.Duration(ticks) * 10 * time.Millisecond).String()
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snmp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/seglberg/protoscan/pkg/ber"
+)
+
+// Discovery is the decoded response to an SNMPv3 discovery request.
+type Discovery struct {
+	// MessageID is the message ID of the response.
+	MessageID int64
+
+	// EngineID is the agent's authoritative engine ID.
+	EngineID []byte
+
+	// EngineBoots is the number of times the engine has (re)initialized.
+	EngineBoots int64
+
+	// EngineTime is the number of seconds since the engine last (re)initialized.
+	EngineTime int64
+
+	// PDU is the Report PDU, typically carrying usmStatsUnknownEngineIDs.
+	PDU *PDU
+}
+
+// EngineID describes the fields of an SNMP engine ID.
+//
+// See https://tools.ietf.org/html/rfc3411#section-5 (SnmpEngineID)
+type EngineID struct {
+	// Enterprise is the IANA private enterprise number of the agent vendor.
+	Enterprise uint32 `json:"enterprise"`
+
+	// EnterpriseName is the name of the vendor, if well known.
+	EnterpriseName string `json:"enterprise_name,omitempty"`
+
+	// Format describes how the remaining octets are formed, for example "mac".
+	Format string `json:"format"`
+
+	// Data is the decoded remaining octets: an address, MAC address or text depending on the format,
+	// hex encoded otherwise.
+	Data string `json:"data"`
+}
+
+// EncodeDiscovery encodes an SNMPv3 discovery request: a reportable, unauthenticated GetRequest with an
+// empty engine ID and user name, to which agents respond with a Report carrying their engine ID, boots and time.
+//
+// See https://tools.ietf.org/html/rfc3414#section-4
+func EncodeDiscovery(messageID, requestID int64) []byte {
+	// msgGlobalData
+	//	msgID, msgMaxSize, msgFlags, msgSecurityModel
+
+	global := ber.Sequence(
+		ber.Integer(messageID),
+		ber.Integer(maxMessageSize),
+		ber.Encode(ber.ClassUniversal, false, ber.TagOctetString, []byte{flagReportable}),
+		ber.Integer(securityModelUSM),
+	)
+
+	// UsmSecurityParameters (encoded within an OCTET STRING)
+	//	msgAuthoritativeEngineID, msgAuthoritativeEngineBoots, msgAuthoritativeEngineTime,
+	//	msgUserName, msgAuthenticationParameters, msgPrivacyParameters
+
+	usm := ber.Sequence(
+		ber.OctetString(""),
+		ber.Integer(0),
+		ber.Integer(0),
+		ber.OctetString(""),
+		ber.OctetString(""),
+		ber.OctetString(""),
+	)
+
+	// ScopedPDU
+	//	contextEngineID, contextName, data (PDU)
+
+	pdu, _ := encodePDU(pduGetRequest, requestID, nil)
+	scoped := ber.Sequence(
+		ber.OctetString(""),
+		ber.OctetString(""),
+		pdu,
+	)
+
+	return ber.Sequence(
+		ber.Integer(int64(Version3)),
+		global,
+		ber.Encode(ber.ClassUniversal, false, ber.TagOctetString, usm),
+		scoped,
+	)
+}
+
+// DecodeDiscovery attempts to decode the given datagram as an SNMPv3 response to a discovery request.
+func DecodeDiscovery(datagram []byte) (*Discovery, error) {
+	children, err := decodeSequence(datagram)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) < 4 {
+		return nil, ErrMessageTruncated
+	}
+	if version, err := children[0].Int(); err != nil || Version(version) != Version3 {
+		return nil, fmt.Errorf("%w: not an snmpv3 message", ErrMessageDecode)
+	}
+
+	// msgGlobalData
+
+	global, err := children[1].Children()
+	if err != nil || len(global) < 1 {
+		return nil, ErrMessageTruncated
+	}
+	messageID, err := global[0].Int()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid message id", ErrMessageDecode)
+	}
+
+	// UsmSecurityParameters
+
+	usm, err := decodeSequence(children[2].Value)
+	if err != nil {
+		return nil, err
+	}
+	if len(usm) < 3 {
+		return nil, ErrMessageTruncated
+	}
+
+	d := &Discovery{
+		MessageID: messageID,
+		EngineID:  usm[0].Value,
+	}
+	if d.EngineBoots, err = usm[1].Int(); err != nil {
+		return nil, fmt.Errorf("%w: invalid engine boots", ErrMessageDecode)
+	}
+	if d.EngineTime, err = usm[2].Int(); err != nil {
+		return nil, fmt.Errorf("%w: invalid engine time", ErrMessageDecode)
+	}
+
+	// ScopedPDU
+	//	Unencrypted, since the discovery request is neither authenticated nor encrypted.
+
+	scoped, err := children[3].Children()
+	if err == nil && len(scoped) >= 3 {
+		d.PDU, _ = decodePDU(scoped[2])
+	}
+
+	return d, nil
+}
+
+// DecodeEngineID decodes the fields of an engine ID.
+func DecodeEngineID(id []byte) (*EngineID, error) {
+	if len(id) < 5 {
+		return nil, fmt.Errorf("%w: engine id too short", ErrMessageDecode)
+	}
+
+	// 4 Bytes: Enterprise Number (the high bit set for the RFC 3411 format)
+
+	enterprise := binary.BigEndian.Uint32(id)
+	e := &EngineID{
+		Enterprise:     enterprise &^ 0x80000000,
+		EnterpriseName: EnterpriseName(enterprise &^ 0x80000000),
+	}
+
+	// Pre RFC 3411 engine IDs have no format octet, the remaining 8 octets are enterprise specific.
+
+	if enterprise&0x80000000 == 0 {
+		e.Format = "legacy"
+		e.Data = hex.EncodeToString(id[4:])
+		return e, nil
+	}
+
+	// 1 Byte: Format
+	// Variable: Data
+
+	data := id[5:]
+	switch id[4] {
+	case 1:
+		e.Format = "ipv4"
+		e.Data = formatIP(data, net.IPv4len)
+	case 2:
+		e.Format = "ipv6"
+		e.Data = formatIP(data, net.IPv6len)
+	case 3:
+		e.Format = "mac"
+		e.Data = net.HardwareAddr(data).String()
+	case 4:
+		e.Format = "text"
+		e.Data = string(data)
+	case 5:
+		e.Format = "octets"
+		e.Data = hex.EncodeToString(data)
+	default:
+		e.Format = fmt.Sprintf("enterprise(%d)", id[4])
+		e.Data = hex.EncodeToString(data)
+	}
+
+	return e, nil
+}
+
+// EnterpriseName returns the name of a well known IANA private enterprise number, or an empty string.
+func EnterpriseName(enterprise uint32) string {
+	switch enterprise {
+	case 2:
+		return "IBM"
+	case 9:
+		return "Cisco"
+	case 11:
+		return "Hewlett-Packard"
+	case 311:
+		return "Microsoft"
+	case 674:
+		return "Dell"
+	case 1991:
+		return "Foundry Networks"
+	case 2011:
+		return "Huawei"
+	case 2636:
+		return "Juniper Networks"
+	case 4526:
+		return "Netgear"
+	case 6876:
+		return "VMware"
+	case 8072:
+		return "Net-SNMP"
+	case 12356:
+		return "Fortinet"
+	case 14988:
+		return "MikroTik"
+	case 25461:
+		return "Palo Alto Networks"
+	case 25506:
+		return "H3C"
+	case 30065:
+		return "Arista Networks"
+	case 41112:
+		return "Ubiquiti Networks"
+	default:
+		return ""
+	}
+}
+
+// Formats an address of the expected length, falling back to hex for malformed data.
+func formatIP(data []byte, length int) string {
+	if len(data) != length {
+		return hex.EncodeToString(data)
+	}
+	return net.IP(data).String()
+}
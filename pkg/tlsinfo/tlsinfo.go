@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tlsinfo provides facilities for upgrading connections to TLS and inspecting the negotiated
+// session and server certificates.
+package tlsinfo
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+var ErrHandshake = fmt.Errorf("tls handshake")
+
+// Report describes a negotiated TLS session.
+type Report struct {
+	// Version is the negotiated TLS version, for example "TLS 1.3".
+	Version string `json:"version"`
+
+	// CipherSuite is the negotiated cipher suite.
+	CipherSuite string `json:"cipher_suite"`
+
+	// NegotiatedProtocol is the ALPN protocol selected by the server, if any.
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"`
+
+	// Certificates is the certificate chain presented by the server, leaf first.
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Certificate describes a single X.509 certificate.
+type Certificate struct {
+	// Subject is the certificate subject distinguished name.
+	Subject string `json:"subject"`
+
+	// Issuer is the certificate issuer distinguished name.
+	Issuer string `json:"issuer"`
+
+	// SerialNumber is the hex encoded serial number.
+	SerialNumber string `json:"serial_number"`
+
+	// NotBefore is the start of the validity period.
+	NotBefore time.Time `json:"not_before"`
+
+	// NotAfter is the end of the validity period.
+	NotAfter time.Time `json:"not_after"`
+
+	// DNSNames contains the DNS subject alternative names.
+	DNSNames []string `json:"dns_names,omitempty"`
+
+	// IPAddresses contains the IP address subject alternative names.
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+
+	// SignatureAlgorithm is the algorithm used to sign the certificate.
+	SignatureAlgorithm string `json:"signature_algorithm"`
+
+	// PublicKeyAlgorithm is the algorithm of the certificate's public key.
+	PublicKeyAlgorithm string `json:"public_key_algorithm"`
+
+	// SelfSigned indicates if the certificate's subject and issuer are the same.
+	SelfSigned bool `json:"self_signed"`
+
+	// Expired indicates if the certificate was expired at the time of inspection.
+	Expired bool `json:"expired"`
+
+	// SHA256 is the hex encoded SHA-256 fingerprint of the certificate.
+	SHA256 string `json:"sha256"`
+}
+
+// Client performs a TLS client handshake over the connection and returns the TLS connection
+// along with a report of the negotiated session.
+// The server certificate is not verified, since the goal is to inspect the server and not to trust it.
+// The server name is sent as SNI, unless it is empty or an IP address.
+func Client(conn net.Conn, serverName string, nextProtos ...string) (*tls.Conn, *Report, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec
+		NextProtos:         nextProtos,
+	}
+	if net.ParseIP(serverName) == nil {
+		config.ServerName = serverName
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrHandshake, err)
+	}
+
+	return tlsConn, Inspect(tlsConn.ConnectionState()), nil
+}
+
+// Inspect reports on the given TLS connection state.
+func Inspect(state tls.ConnectionState) *Report {
+	report := &Report{
+		Version:            VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		Certificates:       make([]Certificate, 0, len(state.PeerCertificates)),
+	}
+
+	now := time.Now()
+	for _, cert := range state.PeerCertificates {
+		report.Certificates = append(report.Certificates, inspectCertificate(cert, now))
+	}
+
+	return report
+}
+
+// VersionName returns the name of the TLS version, for example "TLS 1.2".
+func VersionName(version uint16) string {
+	switch version {
+	case tls.VersionSSL30: //nolint:staticcheck
+		return "SSL 3.0"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%04x)", version)
+	}
+}
+
+// Reports on a single certificate.
+func inspectCertificate(cert *x509.Certificate, now time.Time) Certificate {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	ips := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	return Certificate{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		DNSNames:           cert.DNSNames,
+		IPAddresses:        ips,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		SelfSigned:         cert.Subject.String() == cert.Issuer.String(),
+		Expired:            now.After(cert.NotAfter),
+		SHA256:             hex.EncodeToString(fingerprint[:]),
+	}
+}
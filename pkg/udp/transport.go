@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package udp provides a request/response transport for probing connectionless protocols over UDP.
+//
+// UDP offers no connection to tell an open port from a closed one: a probe is sent and the prober waits for a
+// datagram in response, retransmitting in case either was lost. A closed port is only detected through the ICMP
+// destination unreachable messages the kernel reports as socket errors on the connected socket.
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// MaxDatagramSize is the size of the largest datagram received.
+const MaxDatagramSize = 65535
+
+// NoResponseError is returned when no matching datagram was received after every attempt.
+// It unwraps to os.ErrDeadlineExceeded, the port is either open and ignoring the probe, or filtered.
+type NoResponseError struct {
+	Attempts int
+}
+
+func (e *NoResponseError) Error() string {
+	return fmt.Sprintf("no response after %d attempts", e.Attempts)
+}
+
+func (e *NoResponseError) Unwrap() error {
+	return os.ErrDeadlineExceeded
+}
+
+// UnreachableError is returned when an ICMP destination unreachable message was received in response to a probe.
+type UnreachableError struct {
+	// Reason describes what was unreachable, for example "port unreachable".
+	Reason string
+
+	// Err is the underlying socket error.
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("icmp destination unreachable: %s", e.Reason)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches UnreachableErrors with the same reason, see ErrPortUnreachable.
+func (e *UnreachableError) Is(target error) bool {
+	t, ok := target.(*UnreachableError)
+	return ok && t.Reason == e.Reason
+}
+
+// ErrPortUnreachable matches the UnreachableError returned for a closed port.
+var ErrPortUnreachable = &UnreachableError{Reason: "port unreachable"}
+
+// Transport exchanges datagrams with a single target over a connected UDP socket.
+type Transport struct {
+	conn net.Conn
+
+	// Wait is the amount of time to wait for a response to each attempt.
+	// If zero, the transport waits indefinitely and never retransmits.
+	Wait time.Duration
+
+	// Retransmits is the number of times the probe is resent when no response is received.
+	Retransmits int
+}
+
+// NewTransport creates a transport over the connected UDP socket.
+// The transport takes ownership of the socket and manages its deadline, replacing any deadline already set,
+// so a single transport can be used for any number of exchanges.
+func NewTransport(conn net.Conn, wait time.Duration, retransmits int) *Transport {
+	return &Transport{
+		conn:        conn,
+		Wait:        wait,
+		Retransmits: retransmits,
+	}
+}
+
+// Close closes the underlying socket.
+func (t *Transport) Close() error {
+	return t.conn.Close()
+}
+
+// RemoteAddr returns the target address.
+func (t *Transport) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// Exchange sends the probe and waits for a datagram the match function accepts, retransmitting the probe
+// each time the wait elapses. Datagrams the match function rejects (for example responses to an earlier
+// attempt) are ignored. A nil match function accepts any datagram.
+//
+// A NoResponseError is returned if every attempt goes unanswered, and an UnreachableError if the kernel
+// reports an ICMP destination unreachable message.
+func (t *Transport) Exchange(probe []byte, match func(datagram []byte) bool) ([]byte, error) {
+	attempts := t.Retransmits + 1
+	if t.Wait <= 0 {
+		attempts = 1
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	for attempt := 0; attempt < attempts; attempt++ {
+		var deadline time.Time
+		if t.Wait > 0 {
+			deadline = time.Now().Add(t.Wait)
+		}
+		if err := t.conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		if _, err := t.conn.Write(probe); err != nil {
+			return nil, interpret(err)
+		}
+
+		for {
+			n, err := t.conn.Read(buf)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return nil, interpret(err)
+			}
+			if match == nil || match(buf[:n]) {
+				datagram := make([]byte, n)
+				copy(datagram, buf[:n])
+				return datagram, nil
+			}
+		}
+	}
+
+	return nil, &NoResponseError{Attempts: attempts}
+}
+
+// Receive waits for a further datagram the match function accepts without sending anything, for protocols
+// which spread a response over several datagrams. Only a single wait is made, nothing is retransmitted.
+func (t *Transport) Receive(match func(datagram []byte) bool) ([]byte, error) {
+	var deadline time.Time
+	if t.Wait > 0 {
+		deadline = time.Now().Add(t.Wait)
+	}
+	if err := t.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		n, err := t.conn.Read(buf)
+		if err != nil {
+			return nil, interpret(err)
+		}
+		if match == nil || match(buf[:n]) {
+			datagram := make([]byte, n)
+			copy(datagram, buf[:n])
+			return datagram, nil
+		}
+	}
+}
+
+// Interprets a socket error, translating errors caused by ICMP destination unreachable messages.
+// On a connected UDP socket the kernel reports these on the next read or write.
+func interpret(err error) error {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return &UnreachableError{Reason: "port unreachable", Err: err}
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return &UnreachableError{Reason: "host unreachable", Err: err}
+	case errors.Is(err, syscall.ENETUNREACH):
+		return &UnreachableError{Reason: "network unreachable", Err: err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vnc
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// Report contains the information gathered from a VNC server.
+type Report struct {
+	// ProtocolVersion is the RFB protocol version sent by the server, for example "RFB 003.008".
+	ProtocolVersion string `json:"protocol_version"`
+
+	// Version is the RFB protocol version, for example "3.8".
+	Version string `json:"version"`
+
+	// SecurityTypes lists the security types offered by the server.
+	SecurityTypes []SecurityType `json:"security_types"`
+
+	// NoAuthentication indicates if the server offers the None security type, allowing anyone to connect.
+	NoAuthentication bool `json:"no_authentication"`
+
+	// Failure contains the reason the server refused the connection, for example after too many
+	// authentication failures.
+	Failure string `json:"failure,omitempty"`
+}
+
+// Probe reads the server's protocol version, responds with the highest mutually supported version and
+// reads the offered security types. The prober never selects a security type.
+func Probe(rw io.ReadWriter) (*Report, error) {
+	// (1) Read the Server's Protocol Version
+
+	version, err := ReadProtocolVersion(rw)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		ProtocolVersion: strings.TrimSpace(string(version.Encode())),
+		Version:         version.String(),
+		SecurityTypes:   []SecurityType{},
+	}
+
+	// (2) Respond with the Client Protocol Version
+	//		Only 3.3, 3.7 and 3.8 are defined, anything else (such as Apple's 3.889) is treated as the nearest lower version.
+
+	client := ProtocolVersion{Major: 3, Minor: 8}
+	switch {
+	case version.Major == 3 && version.Minor < 7:
+		client.Minor = 3
+	case version.Major == 3 && version.Minor == 7:
+		client.Minor = 7
+	}
+	if _, err := rw.Write(client.Encode()); err != nil {
+		return nil, err
+	}
+
+	// (3) Read the Security Types
+
+	report.SecurityTypes, err = ReadSecurityTypes(rw, client)
+	if err != nil {
+		var failure *FailureError
+		if !errors.As(err, &failure) {
+			return nil, err
+		}
+		report.SecurityTypes = []SecurityType{}
+		report.Failure = failure.Reason
+	}
+
+	for _, t := range report.SecurityTypes {
+		if t == SecurityNone {
+			report.NoAuthentication = true
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package vnc provides facilities for probing and inspecting VNC servers speaking the Remote Framebuffer (RFB) protocol.
+package vnc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrHandshakeDecode = fmt.Errorf("handshake decode")
+var ErrHandshakeTruncated = fmt.Errorf("%w: truncated handshake or connection is not rfb", ErrHandshakeDecode)
+
+// Size of the ProtocolVersion message, "RFB xxx.yyy\n".
+const protocolVersionSize = 12
+
+// Maximum length of a failure reason accepted from the server.
+const maxReasonLength = 4096
+
+var protocolVersionPattern = regexp.MustCompile(`^RFB (\d{3})\.(\d{3})\n$`)
+
+// ProtocolVersion is the RFB protocol version sent by the server.
+type ProtocolVersion struct {
+	Major int
+	Minor int
+}
+
+func (v ProtocolVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Encode encodes the version as a ProtocolVersion message.
+func (v ProtocolVersion) Encode() []byte {
+	return []byte(fmt.Sprintf("RFB %03d.%03d\n", v.Major, v.Minor))
+}
+
+// SecurityType is an RFB security type.
+type SecurityType uint8
+
+// Security Types
+const (
+	SecurityInvalid  SecurityType = 0
+	SecurityNone     SecurityType = 1
+	SecurityVNCAuth  SecurityType = 2
+	SecurityRA2      SecurityType = 5
+	SecurityRA2ne    SecurityType = 6
+	SecurityTight    SecurityType = 16
+	SecurityUltra    SecurityType = 17
+	SecurityTLS      SecurityType = 18
+	SecurityVeNCrypt SecurityType = 19
+	SecuritySASL     SecurityType = 20
+	SecurityMD5      SecurityType = 21
+	SecurityXVP      SecurityType = 22
+	SecurityAppleDH  SecurityType = 30
+)
+
+func (t SecurityType) String() string {
+	switch t {
+	case SecurityInvalid:
+		return "Invalid"
+	case SecurityNone:
+		return "None"
+	case SecurityVNCAuth:
+		return "VNC Authentication"
+	case SecurityRA2:
+		return "RA2"
+	case SecurityRA2ne:
+		return "RA2ne"
+	case SecurityTight:
+		return "Tight"
+	case SecurityUltra:
+		return "Ultra"
+	case SecurityTLS:
+		return "TLS"
+	case SecurityVeNCrypt:
+		return "VeNCrypt"
+	case SecuritySASL:
+		return "SASL"
+	case SecurityMD5:
+		return "MD5 Hash Authentication"
+	case SecurityXVP:
+		return "xvp"
+	case SecurityAppleDH:
+		return "Apple Remote Desktop"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+	}
+}
+
+func (t SecurityType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// FailureError is returned when the server refuses the connection and sends a reason.
+type FailureError struct {
+	Reason string
+}
+
+func (e *FailureError) Error() string {
+	return fmt.Sprintf("server refused connection: %s", e.Reason)
+}
+
+// ReadProtocolVersion reads the server's ProtocolVersion message.
+//
+// See https://tools.ietf.org/html/rfc6143#section-7.1.1
+func ReadProtocolVersion(r io.Reader) (*ProtocolVersion, error) {
+	buf := make([]byte, protocolVersionSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+	}
+
+	m := protocolVersionPattern.FindSubmatch(buf)
+	if m == nil {
+		return nil, fmt.Errorf("%w: invalid protocol version %q, connection is not rfb", ErrHandshakeDecode, buf)
+	}
+
+	major, _ := strconv.Atoi(string(m[1]))
+	minor, _ := strconv.Atoi(string(m[2]))
+	return &ProtocolVersion{Major: major, Minor: minor}, nil
+}
+
+// ReadSecurityTypes reads the security types offered by the server for the negotiated version.
+// RFB 3.3 servers decide the security type themselves, later versions send a list to choose from.
+// A FailureError is returned if the server refused the connection.
+//
+// See https://tools.ietf.org/html/rfc6143#section-7.1.2
+func ReadSecurityTypes(r io.Reader, version ProtocolVersion) ([]SecurityType, error) {
+	// RFB 3.3
+	//	4 Bytes: Security Type (0 for failure)
+
+	if version.Major == 3 && version.Minor < 7 {
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+		}
+		typ := binary.BigEndian.Uint32(buf)
+		if typ == 0 {
+			return nil, readFailure(r)
+		}
+		if typ > 0xFF {
+			return nil, fmt.Errorf("%w: invalid security type %d", ErrHandshakeDecode, typ)
+		}
+		return []SecurityType{SecurityType(typ)}, nil
+	}
+
+	// RFB 3.7+
+	//	1 Byte: Number of Security Types (0 for failure)
+	//	Variable: Security Types (1 Byte each)
+
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+	}
+	if buf[0] == 0 {
+		return nil, readFailure(r)
+	}
+
+	buf = make([]byte, buf[0])
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+	}
+
+	types := make([]SecurityType, len(buf))
+	for i, b := range buf {
+		types[i] = SecurityType(b)
+	}
+	return types, nil
+}
+
+// Reads the failure reason sent when the server refuses the connection.
+func readFailure(r io.Reader) error {
+	// 4 Bytes: Reason Length
+	// Variable: Reason
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+	}
+	length := binary.BigEndian.Uint32(buf)
+	if length > maxReasonLength {
+		return fmt.Errorf("%w: reason length %d exceeds maximum", ErrHandshakeDecode, length)
+	}
+
+	buf = make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return wire.ReadError(err, ErrHandshakeTruncated, ErrHandshakeDecode)
+	}
+	return &FailureError{Reason: string(buf)}
+}
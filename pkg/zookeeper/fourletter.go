@@ -0,0 +1,192 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package zookeeper provides facilities for probing and inspecting ZooKeeper servers.
+package zookeeper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrCommand = fmt.Errorf("four letter word")
+var ErrCommandDisabled = fmt.Errorf("%w: command not in whitelist", ErrCommand)
+
+// Four Letter Words
+const (
+	CommandServer      = "srvr"
+	CommandStat        = "stat"
+	CommandConf        = "conf"
+	CommandAreYouOkay  = "ruok"
+	responseImOkay     = "imok"
+	responseNotAllowed = "is not executed because it is not in the whitelist"
+)
+
+// Maximum number of bytes read for a single command's output.
+const maxOutputSize = 1 << 20
+
+// Stat is the parsed output of the "srvr" and "stat" commands.
+type Stat struct {
+	// Version is the server version, for example "3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715".
+	Version string `json:"version"`
+
+	// Built is the build date of the server.
+	Built string `json:"built,omitempty"`
+
+	// Mode is the server's role, "leader", "follower", "observer", "read-only" or "standalone".
+	Mode string `json:"mode"`
+
+	// Latency is the min/avg/max request latency in milliseconds.
+	Latency string `json:"latency,omitempty"`
+
+	// Received is the number of packets received.
+	Received *int64 `json:"received,omitempty"`
+
+	// Sent is the number of packets sent.
+	Sent *int64 `json:"sent,omitempty"`
+
+	// Connections is the number of client connections.
+	Connections *int64 `json:"connections,omitempty"`
+
+	// Outstanding is the number of queued requests.
+	Outstanding *int64 `json:"outstanding,omitempty"`
+
+	// Zxid is the last transaction ID, for example "0x100000003".
+	Zxid string `json:"zxid,omitempty"`
+
+	// NodeCount is the number of znodes in the data tree.
+	NodeCount *int64 `json:"node_count,omitempty"`
+
+	// Clients lists the connected clients, only reported by the "stat" command.
+	Clients []string `json:"clients,omitempty"`
+}
+
+// FourLetterWord sends a four letter word command and returns its output, read until the server closes the
+// connection. Each command requires its own connection.
+//
+// See https://zookeeper.apache.org/doc/current/zookeeperAdmin.html#sc_4lw
+func FourLetterWord(rw io.ReadWriter, command string) (string, error) {
+	if _, err := io.WriteString(rw, command); err != nil {
+		return "", err
+	}
+
+	output, err := ioutil.ReadAll(io.LimitReader(rw, maxOutputSize))
+	if err != nil {
+		return "", wire.ReadError(err, ErrCommand, ErrCommand)
+	}
+
+	s := string(output)
+	switch {
+	case s == "":
+		return "", fmt.Errorf("%w: %s: connection closed without output", ErrCommand, command)
+	case strings.Contains(s, responseNotAllowed):
+		return "", fmt.Errorf("%w: %s", ErrCommandDisabled, command)
+	}
+	return s, nil
+}
+
+// ParseStat parses the output of the "srvr" or "stat" command.
+func ParseStat(output string) (*Stat, error) {
+	// Output Format
+	//	Zookeeper version: 3.6.2--803c7f1a12f85978cb049af5e4ef23bd8b688715, built on 09/04/2020 12:44 GMT
+	//	Clients:                      ("stat" only)
+	//	 /127.0.0.1:54424[0](queued=0,recved=1,sent=0)
+	//
+	//	Latency min/avg/max: 0/0.0/0
+	//	Received: 3
+	//	...
+
+	stat := &Stat{}
+	clients := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if clients {
+			if strings.TrimSpace(line) == "" {
+				clients = false
+				continue
+			}
+			stat.Clients = append(stat.Clients, strings.TrimSpace(line))
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := parts[0], strings.TrimSpace(parts[1])
+
+		switch name {
+		case "Zookeeper version":
+			parts := strings.SplitN(value, ", built on ", 2)
+			stat.Version = parts[0]
+			if len(parts) == 2 {
+				stat.Built = parts[1]
+			}
+		case "Clients":
+			clients = true
+			stat.Clients = []string{}
+		case "Latency min/avg/max":
+			stat.Latency = value
+		case "Received":
+			stat.Received = parseInt(value)
+		case "Sent":
+			stat.Sent = parseInt(value)
+		case "Connections":
+			stat.Connections = parseInt(value)
+		case "Outstanding":
+			stat.Outstanding = parseInt(value)
+		case "Zxid":
+			stat.Zxid = value
+		case "Mode":
+			stat.Mode = value
+		case "Node count":
+			stat.NodeCount = parseInt(value)
+		}
+	}
+
+	if stat.Version == "" {
+		return nil, fmt.Errorf("%w: unexpected output, no version", ErrCommand)
+	}
+	return stat, nil
+}
+
+// ParseConf parses the output of the "conf" command, one name=value pair per line.
+func ParseConf(output string) map[string]string {
+	conf := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		conf[parts[0]] = parts[1]
+	}
+	return conf
+}
+
+// Parses an integer statistic, returning nil if it isn't a number.
+func parseInt(value string) *int64 {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
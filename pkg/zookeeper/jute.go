@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/seglberg/protoscan/pkg/internal/wire"
+)
+
+var ErrSessionDecode = fmt.Errorf("session decode")
+var ErrSessionTruncated = fmt.Errorf("%w: truncated response or connection is not zookeeper", ErrSessionDecode)
+
+// Session timeout requested in the ConnectRequest, in milliseconds.
+const requestedTimeout = 30000
+
+// Maximum length accepted for a ConnectResponse.
+const maxResponseLength = 1024
+
+// Operation code of the closeSession request, -11 as a two's complement int32.
+const opCloseSession = 0xFFFFFFF5
+
+// ConnectResponse is the server's response to a ConnectRequest, establishing a session.
+//
+// See https://github.com/apache/zookeeper/blob/master/zookeeper-jute/src/main/resources/zookeeper.jute
+type ConnectResponse struct {
+	// ProtocolVersion is the protocol version of the server.
+	ProtocolVersion int32
+
+	// Timeout is the negotiated session timeout in milliseconds.
+	Timeout int32
+
+	// SessionID is the ID of the session established, 0 if the server refused it.
+	SessionID int64
+
+	// ReadOnly indicates if the server only serves read-only sessions (it is partitioned from the quorum).
+	ReadOnly bool
+}
+
+// EncodeConnectRequest encodes a length prefixed ConnectRequest for a new read-only capable session.
+func EncodeConnectRequest() []byte {
+	// ConnectRequest
+	//	4 Bytes: Length
+	//	4 Bytes: Protocol Version
+	//	8 Bytes: Last Zxid Seen
+	//	4 Bytes: Timeout
+	//	8 Bytes: Session ID
+	//	4 Bytes: Password Length
+	//	16 Bytes: Password
+	//	1 Byte: Read Only
+
+	buf := make([]byte, 4+4+8+4+8+4+16+1)
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+	binary.BigEndian.PutUint32(buf[16:], requestedTimeout)
+	binary.BigEndian.PutUint32(buf[28:], 16)
+	buf[len(buf)-1] = 1
+	return buf
+}
+
+// EncodeCloseSession encodes a length prefixed closeSession request, ending the session established.
+func EncodeCloseSession() []byte {
+	// RequestHeader
+	//	4 Bytes: Length
+	//	4 Bytes: Xid
+	//	4 Bytes: Type
+
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf, 8)
+	binary.BigEndian.PutUint32(buf[4:], 1)
+	binary.BigEndian.PutUint32(buf[8:], opCloseSession)
+	return buf
+}
+
+// ReadConnectResponse reads and decodes a length prefixed ConnectResponse.
+func ReadConnectResponse(r io.Reader) (*ConnectResponse, error) {
+	// (1) Length
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, wire.ReadError(err, ErrSessionTruncated, ErrSessionDecode)
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < 20 || length > maxResponseLength {
+		return nil, fmt.Errorf("%w: invalid response length %d", ErrSessionDecode, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, wire.ReadError(err, ErrSessionTruncated, ErrSessionDecode)
+	}
+
+	// (2) ConnectResponse
+	//	4 Bytes: Protocol Version
+	//	4 Bytes: Timeout
+	//	8 Bytes: Session ID
+	//	4 Bytes: Password Length
+	//	n Bytes: Password
+	//	1 Byte: Read Only (optional, servers since 3.4)
+
+	resp := &ConnectResponse{
+		ProtocolVersion: int32(binary.BigEndian.Uint32(payload)),
+		Timeout:         int32(binary.BigEndian.Uint32(payload[4:])),
+		SessionID:       int64(binary.BigEndian.Uint64(payload[8:])),
+	}
+
+	passwordLength := int32(binary.BigEndian.Uint32(payload[16:]))
+	if passwordLength < 0 {
+		passwordLength = 0
+	}
+	pos := 20 + int(passwordLength)
+	if pos > len(payload) {
+		return nil, ErrSessionTruncated
+	}
+	if pos < len(payload) {
+		resp.ReadOnly = payload[pos] != 0
+	}
+
+	return resp, nil
+}
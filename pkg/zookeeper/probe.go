@@ -0,0 +1,169 @@
+/*
+ * Copyright © 2020 Matthew Ellison <seglberg+oss@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zookeeper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Commands lists the four letter words sent, each over its own connection.
+var Commands = []string{CommandServer, CommandStat, CommandConf, CommandAreYouOkay}
+
+// Report contains the information gathered from a ZooKeeper server.
+type Report struct {
+	// Stat contains the parsed output of the "stat" command, or "srvr" if "stat" is disabled.
+	Stat *Stat `json:"stat,omitempty"`
+
+	// Conf contains the server configuration reported by the "conf" command.
+	Conf map[string]string `json:"conf,omitempty"`
+
+	// ImOkay indicates if the server answered "imok" to the "ruok" command.
+	ImOkay bool `json:"imok"`
+
+	// CommandErrors contains the reason each failed command failed, typically because it isn't in the
+	// 4lw.commands.whitelist.
+	CommandErrors map[string]string `json:"command_errors,omitempty"`
+
+	// Session contains the result of the ConnectRequest, sent when neither "srvr" nor "stat" is available.
+	Session *Session `json:"session,omitempty"`
+
+	// SessionError contains the reason the ConnectRequest failed, if it did.
+	SessionError string `json:"session_error,omitempty"`
+}
+
+// Session is the result of establishing a session with a ConnectRequest.
+type Session struct {
+	// Established indicates if the server established the session (the session ID is not 0).
+	Established bool `json:"established"`
+
+	// ProtocolVersion is the protocol version of the server.
+	ProtocolVersion int32 `json:"protocol_version"`
+
+	// Timeout is the negotiated session timeout in milliseconds.
+	Timeout int32 `json:"timeout"`
+
+	// SessionID is the hex encoded session ID.
+	SessionID string `json:"session_id"`
+
+	// ReadOnly indicates if the server only serves read-only sessions.
+	ReadOnly bool `json:"read_only"`
+}
+
+// Probe sends the four letter word commands, then falls back to establishing a session with a ConnectRequest if
+// neither "srvr" nor "stat" is whitelisted. Each command and the session use their own connection.
+func Probe(dial func() (net.Conn, error)) (*Report, error) {
+	report := &Report{
+		CommandErrors: map[string]string{},
+	}
+
+	// (1) Four Letter Words
+
+	// The server is only known to be ZooKeeper once it refuses a command as not whitelisted, answers "imok" or
+	// its "stat" output parses, as other services answer any command with their banner or an error page.
+	identified := false
+
+	outputs := map[string]string{}
+	for _, command := range Commands {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		output, err := FourLetterWord(conn, command)
+		_ = conn.Close()
+
+		if err != nil {
+			identified = identified || errors.Is(err, ErrCommandDisabled)
+			report.CommandErrors[command] = err.Error()
+			continue
+		}
+		outputs[command] = output
+	}
+
+	for _, command := range []string{CommandStat, CommandServer} {
+		output, ok := outputs[command]
+		if !ok {
+			continue
+		}
+		stat, err := ParseStat(output)
+		if err != nil {
+			report.CommandErrors[command] = err.Error()
+			continue
+		}
+		report.Stat = stat
+		break
+	}
+	if output, ok := outputs[CommandConf]; ok {
+		report.Conf = ParseConf(output)
+	}
+	if output, ok := outputs[CommandAreYouOkay]; ok {
+		report.ImOkay = strings.TrimSpace(output) == responseImOkay
+		identified = identified || report.ImOkay
+	}
+
+	if report.Stat != nil {
+		return report, nil
+	}
+
+	// (2) ConnectRequest
+
+	var err error
+	report.Session, err = connect(dial)
+	if err != nil {
+		if !identified {
+			return nil, err
+		}
+		report.SessionError = err.Error()
+	}
+
+	return report, nil
+}
+
+// Makes a new connection and establishes a session, closing it again straight away.
+func connect(dial func() (net.Conn, error)) (*Session, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	// Best effort close of connection.
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.Write(EncodeConnectRequest()); err != nil {
+		return nil, err
+	}
+	resp, err := ReadConnectResponse(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.SessionID != 0 {
+		// Best effort close of the session, rather than leaving it to expire.
+		_, _ = conn.Write(EncodeCloseSession())
+	}
+
+	return &Session{
+		Established:     resp.SessionID != 0,
+		ProtocolVersion: resp.ProtocolVersion,
+		Timeout:         resp.Timeout,
+		SessionID:       fmt.Sprintf("0x%x", uint64(resp.SessionID)),
+		ReadOnly:        resp.ReadOnly,
+	}, nil
+}